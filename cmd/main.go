@@ -2,12 +2,14 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/DomineCore/coredog/internal/agent"
+	"github.com/DomineCore/coredog/internal/reporter"
 	"github.com/DomineCore/coredog/internal/webhook"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -61,7 +63,100 @@ func main() {
 		Long: "start a mutating admission webhook server to inject corefile volume.",
 	}
 
+	eventsCmd := cobra.Command{
+		Use:  "events",
+		Long: "manage coredump events recorded in the local outboxes.",
+	}
+
+	var sink, since, until, eventType string
+	var dryRun bool
+	replayCmd := cobra.Command{
+		Use: "replay",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sinceTime, err := parseTimeFlag(since)
+			if err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+			var untilTime time.Time
+			if until != "" {
+				if untilTime, err = parseTimeFlag(until); err != nil {
+					return fmt.Errorf("invalid --until: %w", err)
+				}
+			}
+			return agent.ReplayEvents(sink, sinceTime, untilTime, eventType, dryRun)
+		},
+		Long: "re-send events recorded in a sink outbox within a time range, e.g. after a CoreSight outage.",
+	}
+	replayCmd.Flags().StringVar(&sink, "sink", "coresight", "event sink to replay: coresight or kafka")
+	replayCmd.Flags().StringVar(&since, "since", "24h", "start of the range, RFC3339 time or duration ago (e.g. 2h)")
+	replayCmd.Flags().StringVar(&until, "until", "", "end of the range (exclusive), RFC3339 time or duration ago; empty means now")
+	replayCmd.Flags().StringVar(&eventType, "type", "", "only replay events of this type (e.g. coredog.coredump.uploaded)")
+	replayCmd.Flags().BoolVar(&dryRun, "dry-run", false, "list matching events without sending them")
+	eventsCmd.AddCommand(&replayCmd)
+
+	schemaCmd := cobra.Command{
+		Use:  "schema [event-type]",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				for _, t := range reporter.EventTypes() {
+					fmt.Printf("%s\t%s\n", t, reporter.DataSchemaURI(t))
+				}
+				return nil
+			}
+			schema, err := reporter.Schema(args[0])
+			if err != nil {
+				return fmt.Errorf("unknown event type %q", args[0])
+			}
+			fmt.Print(string(schema))
+			return nil
+		},
+		Long: "list CoreSight event types, or print the JSON schema of an event type.",
+	}
+	eventsCmd.AddCommand(&schemaCmd)
+
+	var gcDryRun, gcAllowUnscoped bool
+	gcCmd := cobra.Command{
+		Use: "gc",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return agent.RunGC(gcDryRun, gcAllowUnscoped)
+		},
+		Long: "delete stored core files that exceed the Retention policies, objects with a legal hold are kept.",
+	}
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "report objects that would be deleted without deleting them")
+	gcCmd.Flags().BoolVar(&gcAllowUnscoped, "allow-unscoped", false, "run even if the storage prefix is not scoped to this cluster (the bucket is not shared with other clusters)")
+
+	var helperOpts agent.CoreHelperOptions
+	coreHelperCmd := cobra.Command{
+		Use: "core-helper",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return agent.HandleCore(helperOpts, os.Stdin)
+		},
+		Long: "core_pattern pipe helper: record the cgroup of the crashing process and write the core read from stdin, e.g.\n" +
+			"  |/data/coredog-system/bin/coredog core-helper --pid %P --comm %e --hostname %h --time %t",
+	}
+	coreHelperCmd.Flags().IntVar(&helperOpts.PID, "pid", 0, "pid of the crashing process in the host pid namespace (%P)")
+	coreHelperCmd.Flags().StringVar(&helperOpts.Comm, "comm", "", "name of the crashing process (%e)")
+	coreHelperCmd.Flags().StringVar(&helperOpts.Hostname, "hostname", "", "hostname (%h)")
+	coreHelperCmd.Flags().StringVar(&helperOpts.Time, "time", "", "time of the crash in unix seconds (%t)")
+	coreHelperCmd.Flags().StringVar(&helperOpts.Dir, "dir", "/data/coredog-system/dumps", "host directory watched by the watcher")
+	coreHelperCmd.Flags().StringVar(&helperOpts.StateDir, "state-dir", "/data/coredog-system/state", "host path of the watcher state dir")
+	coreHelperCmd.Flags().StringVar(&helperOpts.ProcRoot, "proc-root", "/proc", "host proc filesystem")
+
 	root.AddCommand(&watcherBootstrap)
 	root.AddCommand(&webhookBootstrap)
-	root.Execute()
+	root.AddCommand(&eventsCmd)
+	root.AddCommand(&gcCmd)
+	root.AddCommand(&coreHelperCmd)
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// parseTimeFlag 解析时间参数：RFC3339 时间，或相对当前时间的 duration（如 2h 表示 2 小时前）
+func parseTimeFlag(v string) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, v)
 }
@@ -2,25 +2,29 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	cfgpkg "github.com/DomineCore/coredog/internal/config"
 	"github.com/DomineCore/coredog/internal/coreparser"
+	"github.com/DomineCore/coredog/internal/diskguard"
 	"github.com/DomineCore/coredog/internal/handler"
+	"github.com/DomineCore/coredog/internal/kube"
 	"github.com/DomineCore/coredog/internal/notice"
 	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/DomineCore/coredog/internal/quota"
 	"github.com/DomineCore/coredog/internal/reporter"
-	"github.com/DomineCore/coredog/internal/store"
 	"github.com/DomineCore/coredog/internal/watcher"
 	"github.com/sirupsen/logrus"
 )
 
 func getHostIP() string { return os.Getenv("HOST_IP") }
 
-func buildNotifyMessage(cfg *cfgpkg.Config, corefilePath, url string, pod podresolver.PodInfo) string {
+func buildNotifyMessage(cfg *cfgpkg.Config, cluster clusterIdentity, corefilePath, url string, pod podresolver.PodInfo, coreInfo *coreparser.CoreInfo) string {
 	msg := cfg.MessageTemplate
 	for k, v := range cfg.MessageLabels {
 		msg = strings.ReplaceAll(msg, "{"+k+"}", v)
@@ -42,26 +46,92 @@ func buildNotifyMessage(cfg *cfgpkg.Config, corefilePath, url string, pod podres
 	msg = strings.ReplaceAll(msg, "{pod.namespace}", pod.Namespace)
 	msg = strings.ReplaceAll(msg, "{pod.uid}", pod.UID)
 	msg = strings.ReplaceAll(msg, "{pod.node}", pod.NodeIP)
+	msg = strings.ReplaceAll(msg, "{pod.node_name}", pod.NodeName)
+	msg = strings.ReplaceAll(msg, "{pod.workload}", pod.Workload.Name)
+	msg = strings.ReplaceAll(msg, "{pod.workload_kind}", pod.Workload.Kind)
+	msg = strings.ReplaceAll(msg, "{pod.image}", pod.Image)
+	msg = strings.ReplaceAll(msg, "{pod.image_digest}", pod.ImageDigest)
+	msg = strings.ReplaceAll(msg, "{pod.restart_count}", strconv.Itoa(int(pod.RestartCount)))
+	msg = strings.ReplaceAll(msg, "{pod.termination_reason}", pod.LastTerminationReason)
+	msg = strings.ReplaceAll(msg, "{pod.exit_code}", strconv.Itoa(int(pod.LastExitCode)))
 	msg = strings.ReplaceAll(msg, "{host.ip}", getHostIP())
+	msg = strings.ReplaceAll(msg, "{cluster.name}", cluster.label())
+	msg = strings.ReplaceAll(msg, "{cluster.id}", cluster.ID)
+
+	var executable, signal string
+	if coreInfo != nil {
+		executable = coreInfo.ExecutablePath
+		signal = coreInfo.SignalName
+	}
+	msg = strings.ReplaceAll(msg, "{corefile.executable}", executable)
+	msg = strings.ReplaceAll(msg, "{corefile.signal}", signal)
 	return msg
 }
 
-func notify(cfg *cfgpkg.Config, corefilePath, url string, pod podresolver.PodInfo) {
-	for _, ch := range cfg.NoticeChannel {
-		if ch.Keyword != "" && !strings.Contains(corefilePath, ch.Keyword) {
-			continue
-		}
-		msg := buildNotifyMessage(cfg, corefilePath, url, pod)
-		switch ch.Chan {
-		case "wechat":
-			n := notice.NewWechatWebhookMsg(ch.Webhookurl)
-			n.Notice(msg)
-		case "slack":
-			n := notice.NewSlackWebhookMsg(ch.Webhookurl)
-			n.Notice(msg)
-		default:
-			logrus.Warnf("unsupported notice channel: %s", ch.Chan)
-		}
+// buildAlert 构建结构化告警，供 Alertmanager 等渠道使用
+func buildAlert(cluster clusterIdentity, corefilePath, url string, pod podresolver.PodInfo, coreInfo *coreparser.CoreInfo) notice.Alert {
+	node := pod.NodeName
+	if node == "" {
+		node = os.Getenv("NODE_NAME")
+	}
+	if node == "" {
+		node = pod.NodeIP
+	}
+	workload := workloadName(pod)
+	alert := notice.Alert{
+		Cluster:   cluster.label(),
+		ClusterID: cluster.ID,
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		Workload:  workload,
+		Container: pod.ContainerName,
+		Node:      node,
+		Image:     pod.Image,
+
+		WorkloadKind:          pod.Workload.Kind,
+		ImageDigest:           pod.ImageDigest,
+		RestartCount:          pod.RestartCount,
+		LastTerminationReason: pod.LastTerminationReason,
+		LastExitCode:          pod.LastExitCode,
+
+		FileName:    filepath.Base(corefilePath),
+		URL:         url,
+		Time:        time.Now(),
+		Labels:      pod.Labels,
+		Annotations: pod.Annotations,
+	}
+	if coreInfo != nil {
+		alert.Executable = coreInfo.ExecutablePath
+		alert.Signal = coreInfo.SignalName
+		alert.MD5 = coreInfo.MD5
+	}
+	return alert
+}
+
+func notify(cfg *cfgpkg.Config, router *notice.Router, dispatcher *notice.Dispatcher, cluster clusterIdentity, corefilePath, url string, pod podresolver.PodInfo, coreInfo *coreparser.CoreInfo) {
+	msg := buildNotifyMessage(cfg, cluster, corefilePath, url, pod, coreInfo)
+	alert := buildAlert(cluster, corefilePath, url, pod, coreInfo)
+	dispatchAlert(router, dispatcher, msg, alert, pod, corefilePath)
+}
+
+// dispatchAlert 按 Pod 指定的渠道或全局路由发送告警
+func dispatchAlert(router *notice.Router, dispatcher *notice.Dispatcher, msg string, alert notice.Alert, pod podresolver.PodInfo, corefilePath string) {
+	// 命中 NoticeRoutes 的静默规则时不发送，Pod 通过 annotation 指定的渠道同样被静默
+	if router.Muted(alert) {
+		logrus.Infof("notification for %s/%s muted by notice routes", pod.Namespace, pod.Name)
+		return
+	}
+	// Pod 通过 annotation 指定了通知渠道时优先使用，并 @ 指定的负责人；否则使用全局路由
+	channels := router.PodChannels(pod.NotifyChannels())
+	if len(channels) > 0 {
+		alert.Mentions = pod.NotifyMentions()
+		logrus.Infof("routing notification for %s/%s to pod-declared channels", pod.Namespace, pod.Name)
+	} else {
+		channels = router.Route(alert, corefilePath)
+	}
+
+	for _, ch := range channels {
+		dispatcher.Dispatch(ch, msg, alert)
 	}
 }
 
@@ -73,30 +143,104 @@ func Run() {
 	if err := w.Watch(wcfg.CorefileDir); err != nil {
 		logrus.Fatal(err)
 	}
-	storeClient, err := store.NewStore(
-		wcfg.StorageConfig.Protocol,
-		wcfg.StorageConfig.S3Region,
-		wcfg.StorageConfig.S3AccessKeyID,
-		wcfg.StorageConfig.S3SecretAccessKey,
-		wcfg.StorageConfig.S3Bucket,
-		wcfg.StorageConfig.S3Endpoint,
-		wcfg.StorageConfig.CFSMountPath,
-		wcfg.StorageConfig.StoreDir,
-		wcfg.StorageConfig.PresignedURLExpireSeconds,
-	)
+
+	// enableLookup 默认为 true，除非明确设置为 false
+	enableLookup := strings.ToLower(strings.TrimSpace(os.Getenv("KUBE_LOOKUP"))) != "false"
+
+	cluster := resolveCluster(wcfg, enableLookup)
+
+	storeClient, err := newStore(wcfg, cluster)
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	keyTemplate, err := parseKeyTemplate(wcfg)
+	if err != nil {
+		logrus.Fatalf("invalid StorageConfig.keyTemplate: %v", err)
+	}
+
+	// 初始化通知路由
+	var podAllowedChannels []string
+	if wcfg.PodNotify.Enabled {
+		podAllowedChannels = wcfg.PodNotify.AllowedChannels
+	}
+	router, err := notice.NewRouter(wcfg.NoticeChannel, wcfg.NoticeRoutes, podAllowedChannels)
+	if err != nil {
+		logrus.Fatalf("invalid notice routes: %v", err)
+	}
+
+	dispatcher := notice.NewDispatcher()
+	defer dispatcher.Close()
 
 	// 初始化 CoreSight reporter
 	var csReporter *reporter.Reporter
 	if wcfg.CoreSight.Enabled {
-		csReporter = reporter.NewReporterWithToken(wcfg.CoreSight.NatsURL, wcfg.CoreSight.Token)
+		csReporter, err = newCoreSightReporter(wcfg, cluster.eventSource(), false)
+		if err != nil {
+			logrus.Fatalf("failed to initialize CoreSight reporter: %v", err)
+		}
 		if csReporter != nil {
 			logrus.Infof("CoreSight integration enabled: %s", wcfg.CoreSight.NatsURL)
+			defer csReporter.Close()
+		}
+	}
+
+	// 初始化 Kafka sink
+	var kafkaReporter *reporter.Reporter
+	if wcfg.Kafka.Enabled {
+		kafkaReporter, err = newKafkaReporter(wcfg, cluster.eventSource(), false)
+		if err != nil {
+			logrus.Fatalf("failed to initialize Kafka sink: %v", err)
+		}
+		defer kafkaReporter.Close()
+	}
+
+	if enableLookup {
+		startPodCache(time.Duration(wcfg.PodCache.TombstoneTTLSeconds) * time.Second)
+		if wcfg.DirGCEnabled() {
+			go runDirGC(wcfg.CorefileDir,
+				time.Duration(wcfg.DirGC.IntervalSeconds)*time.Second,
+				time.Duration(wcfg.DirGC.MinAgeSeconds)*time.Second,
+				w.Unwatch)
+		}
+	}
+
+	// core-helper 在崩溃时记录的 cgroup，用于确定崩溃进程所在的容器
+	podresolver.SetCrashRecordDir(crashRecordDir(wcfg.StateDir))
+
+	// 初始化 CRI 解析器
+	var pidResolver *podresolver.PIDResolver
+	if wcfg.CRI.Enabled {
+		pidResolver, err = podresolver.NewPIDResolver(wcfg.CRI.Endpoint, wcfg.CRI.ProcRoot)
+		if err != nil {
+			logrus.Warnf("pid based pod resolving disabled: %v", err)
+		} else {
+			defer pidResolver.Close()
+			podresolver.SetPIDResolver(pidResolver)
 		}
 	}
 
+	// 上报时元数据不完整的 core dump 稍后使用相同的方式重新解析 Pod 信息
+	var podEnricher *enricher
+	if enableLookup {
+		podEnricher = newEnricher(func(corefilePath string) podresolver.PodInfo {
+			return resolvePod(pidResolver, corefilePath, true)
+		})
+		go podEnricher.run(nil)
+	}
+
+	// 初始化 Kubernetes Event 记录器（需要访问 Kubernetes API）
+	var kubeRecorder *kube.Recorder
+	if wcfg.KubeEventsEnabled() && enableLookup {
+		kubeRecorder = newKubeRecorder()
+		defer kubeRecorder.Shutdown()
+	}
+
+	// 初始化 CoreDump 资源写入器（需要访问 Kubernetes API 并安装 CRD）
+	var coreDumpWriter *kube.CoreDumpWriter
+	if wcfg.CoreDumpResource.Enabled && enableLookup {
+		coreDumpWriter = newCoreDumpWriter()
+	}
+
 	// 初始化自定义处理器
 	var customHandler *handler.CustomHandler
 	if wcfg.CustomHandler.Enabled {
@@ -111,51 +255,185 @@ func Run() {
 	}
 
 	ccfg := wcfg
-	for corefilePath := range receiver {
+	// 自定义处理器配置 skipCoreSight 时，不上报任何 CoreSight 事件（Kafka sink 不受影响）
+	skipCoreSight := customHandler != nil && wcfg.CustomHandler.SkipCoreSight
+	var sinks []*reporter.Reporter
+	if csReporter != nil && !skipCoreSight {
+		sinks = append(sinks, csReporter)
+	}
+	if kafkaReporter != nil {
+		sinks = append(sinks, kafkaReporter)
+	}
+
+	// 初始化上传配额
+	var limiter *quota.Limiter
+	if wcfg.Quota.Enabled {
+		limiter, err = quota.New(wcfg.Quota)
+		if err != nil {
+			logrus.Fatalf("invalid quota config: %v", err)
+		}
+		logrus.Infof("upload quota enabled with %d policies", len(wcfg.Quota.Policies))
+	}
+	if wcfg.Metrics.Addr != "" {
+		go serveMetrics(wcfg.Metrics.Addr)
+	}
+
+	// 待处理队列：按上传优先级处理，磁盘压力下优先处理最旧的 core，磁盘临界时由磁盘保护清理低优先级的 core
+	priority, err := uploadPriority(wcfg.CorefileDir, wcfg.StorageConfig.Priority)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	var guard *diskguard.Guard
+	queue := newCoreQueue(func() bool { return guard != nil && guard.Level() >= diskguard.Pressure }, priority)
+	if wcfg.DiskGuard.IsEnabled() {
+		// 尚未处理的 core 被清理时，以新的 coredump_id 上报，CoreDump 记录未上传的原因
+		newReclaimedCore := func(path string, size int64) *retainedCore {
+			pod := resolvePod(pidResolver, path, enableLookup)
+			if pod.NodeIP == "" {
+				pod.NodeIP = getHostIP()
+			}
+			events := newLifecycle(sinks, filepath.Base(path), pod, cluster, wcfg.PodMetadata.Annotations)
+			events.report(reporter.EventTypeDetected, &reporter.CoredumpDetectedData{
+				CoredumpRef: events.ref(),
+				FilePath:    path,
+				FileSize:    size,
+			})
+			err := errors.New("reclaimed by disk guard before upload")
+			coreDump := createCoreDump(coreDumpWriter, wcfg.CoreDumpOwnerReference(),
+				buildCoreDump(events, "", wcfg.StorageConfig.Protocol, err, pod, nil, size), pod)
+			return &retainedCore{events: events, coreDump: coreDump}
+		}
+		guard = startDiskGuard(wcfg, cluster, queue,
+			func(message string) {
+				dispatchDiskAlert(router, dispatcher, cluster, wcfg.CorefileDir, message)
+			},
+			func(files []diskguard.Reclaimed) {
+				for _, f := range files {
+					reportReclaimed(f, queue.release(f.Path), newReclaimedCore)
+				}
+			})
+	}
+	if wcfg.StorageConfig.Resume.IsEnabled() {
+		resumeUploads(storeClient, queue.pushResumed)
+	}
+	go func() {
+		for corefilePath := range receiver {
+			queue.push(corefilePath)
+		}
+	}()
+
+	for {
+		next := queue.pop()
+		corefilePath := next.path
+		_, filename := filepath.Split(corefilePath)
+
+		logrus.Debugf("resolving pod info from path: %s", corefilePath)
+		pod := resolvePod(pidResolver, corefilePath, enableLookup)
+		// core 文件写在本节点的 hostPath 上，Pod 已不存在时以本节点 IP 作为 node_ip
+		if pod.NodeIP == "" {
+			pod.NodeIP = getHostIP()
+		}
+
+		events := newLifecycle(sinks, filename, pod, cluster, wcfg.PodMetadata.Annotations)
+		// 续传上次未完成的上传：沿用原来的 coredump ID，已经上报过 Detected 事件并通过了配额检查
+		resumed := next.coredumpID != ""
+		if resumed {
+			events.base.CoredumpID = next.coredumpID
+			logrus.Infof("resuming upload of %s (coredump %s)", corefilePath, next.coredumpID)
+		}
+
+		var fileSize int64
+		if st, err := os.Stat(corefilePath); err == nil {
+			fileSize = st.Size()
+		}
+		if !resumed {
+			events.report(reporter.EventTypeDetected, &reporter.CoredumpDetectedData{
+				CoredumpRef: events.ref(),
+				FilePath:    corefilePath,
+				FileSize:    fileSize,
+			})
+		}
+
+		// 超出配额的 core 只上报元数据，本地文件保留，磁盘不足时由磁盘保护清理
+		if limiter != nil && !resumed {
+			if d := checkQuota(limiter, pod, fileSize); !d.Allowed {
+				coreDump := handleQuotaExceeded(ccfg, router, dispatcher, cluster, events, kubeRecorder, coreDumpWriter, corefilePath, pod, fileSize, d)
+				queue.retain(corefilePath, &retainedCore{events: events, coreDump: coreDump})
+				continue
+			}
+		}
+
 		// 解析 core 文件获取可执行文件路径
 		// 解析失败不影响通知发送，只影响 CoreSight 上报
 		coreInfo, err := coreparser.ParseCoreFile(corefilePath)
 		if err != nil {
 			logrus.Warnf("failed to parse core file %s: %v (will continue with notification but skip CoreSight reporting)", corefilePath, err)
 			coreInfo = nil // 设置为 nil 以标记解析失败
+			events.report(reporter.EventTypeParseFailed, &reporter.CoredumpParseFailedData{
+				CoredumpRef: events.ref(),
+				Error:       err.Error(),
+			})
 		}
 
-		url, err := storeClient.Upload(context.Background(), corefilePath)
+		key := storageKey(keyTemplate, cluster, events.base.CoredumpID, corefilePath, pod, coreInfo, time.Now())
+		url, err := upload(storeClient, keyTemplate, corefilePath, key, events.base.CoredumpID, wcfg.StorageConfig.Resume.RetryCount())
 		if err != nil {
 			logrus.Errorf("store a corefile error:%v", err)
+			events.report(reporter.EventTypeUploadFailed, &reporter.CoredumpUploadFailedData{
+				CoredumpRef: events.ref(),
+				Protocol:    wcfg.StorageConfig.Protocol,
+				Error:       err.Error(),
+			})
+			recordPodEvent(kubeRecorder, events, corefilePath, "", err, pod, coreInfo, fileSize)
+			createCoreDump(coreDumpWriter, wcfg.CoreDumpOwnerReference(),
+				buildCoreDump(events, "", wcfg.StorageConfig.Protocol, err, pod, coreInfo, fileSize), pod)
 			continue
 		}
 		logrus.Debugf("uploaded corefile to: %s, original path: %s", url, corefilePath)
+		if limiter != nil {
+			recordQuota(limiter, pod, fileSize)
+		}
+		recordPodEvent(kubeRecorder, events, corefilePath, url, nil, pod, coreInfo, fileSize)
+		coreDump := createCoreDump(coreDumpWriter, wcfg.CoreDumpOwnerReference(),
+			buildCoreDump(events, url, wcfg.StorageConfig.Protocol, nil, pod, coreInfo, fileSize), pod)
 
 		// 上传成功后，根据配置清理本地文件
+		localFile := "Retained"
 		if wcfg.StorageConfig.DeleteLocalCorefile {
 			if wcfg.Gc && wcfg.GcType == "truncate" {
 				if err := os.Truncate(corefilePath, 0); err != nil {
 					logrus.Errorf("failed to truncate corefile %s: %v", corefilePath, err)
 				} else {
 					logrus.Infof("truncated local corefile: %s", corefilePath)
+					localFile = "Truncated"
+					events.report(reporter.EventTypeDeletedLocal, &reporter.CoredumpDeletedLocalData{
+						CoredumpRef: events.ref(),
+						FilePath:    corefilePath,
+						Method:      "truncate",
+					})
 				}
 			} else {
 				if err := os.Remove(corefilePath); err != nil {
 					logrus.Errorf("failed to remove corefile %s: %v", corefilePath, err)
 				} else {
 					logrus.Infof("deleted local corefile: %s", corefilePath)
+					localFile = "Deleted"
+					events.report(reporter.EventTypeDeletedLocal, &reporter.CoredumpDeletedLocalData{
+						CoredumpRef: events.ref(),
+						FilePath:    corefilePath,
+						Method:      "rm",
+					})
 				}
 			}
 		}
 
-		// enableLookup 默认为 true，除非明确设置为 false
-		enableLookup := strings.ToLower(strings.TrimSpace(os.Getenv("KUBE_LOOKUP"))) != "false"
-		logrus.Debugf("resolving pod info from path: %s", corefilePath)
-		pod := podresolver.Resolve(corefilePath, enableLookup)
+		coreDumpStatus := kube.CoreDumpStatus{LocalFile: localFile}
 
-		// 判断是否跳过默认通知和 CoreSight 上报
+		// 判断是否跳过默认通知
 		skipNotify := false
-		skipCoreSight := false
 
 		// 执行自定义处理器
 		if customHandler != nil && coreInfo != nil {
-			_, filename := filepath.Split(corefilePath)
 			coredumpInfo := handler.CoredumpInfo{
 				FilePath:       corefilePath,
 				FileURL:        url,
@@ -163,98 +441,89 @@ func Run() {
 				MD5:            coreInfo.MD5,
 				FileSize:       coreInfo.FileSize,
 				ExecutablePath: coreInfo.ExecutablePath,
+				ClusterName:    cluster.Name,
+				ClusterID:      cluster.ID,
 			}
 			podInfo := handler.PodInfo{
-				Name:          pod.Name,
-				Namespace:     pod.Namespace,
-				UID:           pod.UID,
-				NodeIP:        pod.NodeIP,
-				Image:         pod.Image,
-				ContainerName: pod.ContainerName,
-				IsLegacyPath:  pod.IsLegacyPath,
+				Name:                  pod.Name,
+				Namespace:             pod.Namespace,
+				UID:                   pod.UID,
+				NodeIP:                pod.NodeIP,
+				NodeName:              pod.NodeName,
+				ServiceAccount:        pod.ServiceAccount,
+				Image:                 pod.Image,
+				ImageDigest:           pod.ImageDigest,
+				ContainerName:         pod.ContainerName,
+				WorkloadKind:          pod.Workload.Kind,
+				WorkloadName:          pod.Workload.Name,
+				RestartCount:          pod.RestartCount,
+				LastTerminationReason: pod.LastTerminationReason,
+				LastExitCode:          pod.LastExitCode,
+				Labels:                pod.Labels,
+				Annotations:           selectAnnotations(pod.Annotations, wcfg.PodMetadata.Annotations),
+				IsLegacyPath:          pod.IsLegacyPath,
+				ContainerMatch:        pod.Match.Method,
+				MatchConfidence:       pod.Match.Confidence,
+				ContainerCandidates:   pod.Match.Candidates,
+			}
+			started := time.Now()
+			handlerErr := customHandler.Execute(context.Background(), coredumpInfo, podInfo)
+			completed := &reporter.CoredumpHandlerCompletedData{
+				CoredumpRef: events.ref(),
+				Success:     handlerErr == nil,
+				DurationMs:  time.Since(started).Milliseconds(),
 			}
-			if err := customHandler.Execute(context.Background(), coredumpInfo, podInfo); err != nil {
-				logrus.Errorf("custom handler execution failed: %v", err)
+			coreDumpStatus.Handler = "Succeeded"
+			if handlerErr != nil {
+				logrus.Errorf("custom handler execution failed: %v", handlerErr)
+				completed.Error = handlerErr.Error()
+				coreDumpStatus.Handler = "Failed"
 			}
+			events.report(reporter.EventTypeHandlerCompleted, completed)
 
 			skipNotify = wcfg.CustomHandler.SkipDefaultNotify
-			skipCoreSight = wcfg.CustomHandler.SkipCoreSight
 		}
+		coreDump.update(coreDumpStatus)
 
-		// 发送通知（不依赖 coreInfo，即使解析失败也发送）
-		if !skipNotify {
-			notify(ccfg, corefilePath, url, pod)
+		// CoreDump 更新和自定义处理器完成后才允许磁盘保护清理保留的文件
+		if localFile == "Retained" {
+			queue.retain(corefilePath, &retainedCore{events: events, coreDump: coreDump})
 		}
 
-		// 跳过 CoreSight 上报
-		if skipCoreSight {
-			continue
+		// 发送通知（不依赖 coreInfo，即使解析失败也发送）
+		if !skipNotify {
+			notify(ccfg, router, dispatcher, cluster, corefilePath, url, pod, coreInfo)
 		}
 
-		// 上报事件到 CoreSight（需要 coreInfo 有效）
-		if csReporter != nil {
-			// 如果 coreInfo 为 nil（解析失败），跳过 CoreSight 上报
-			if coreInfo == nil {
-				logrus.Warnf("skipping CoreSight reporting for %s due to parsing failure", corefilePath)
-				continue
-			}
-
-			// 检查是否为旧路径格式，旧格式不上报到 CoreSight
+		// 上报上传事件：元数据不完整时也上报，并标明未解析的字段
+		if events.enabled() {
 			if pod.IsLegacyPath {
-				logrus.Warnf("detected legacy path format for corefile: %s. Please upgrade to the new path structure: /data/coredog-system/dumps/<namespace>/<pod-name>/<container-name>/core.xxx. Skipping CoreSight reporting.", corefilePath)
-				continue
+				logrus.Warnf("detected legacy path format for corefile: %s. Please upgrade to the new path structure: /data/coredog-system/dumps/<namespace>/<pod-name>/<container-name>/core.xxx.", corefilePath)
 			}
 
-			_, filename := filepath.Split(corefilePath)
-
-			// 验证必要字段，有异常则不上报
-			var validationErrors []string
-			if coreInfo.ExecutablePath == "" {
-				validationErrors = append(validationErrors, "executable_path is empty")
-			}
-			if coreInfo.MD5 == "" {
-				validationErrors = append(validationErrors, "md5 is empty")
-			}
-			if pod.Name == "" {
-				validationErrors = append(validationErrors, "pod_name is empty")
-			}
-			if strings.HasPrefix(pod.Name, "pod-") && len(pod.Name) == 12 {
-				// pod-xxxxxxxx 格式说明是从 admission-uid 生成的假名称
-				validationErrors = append(validationErrors, "pod_name is generated from admission-uid (pod not found)")
-			}
-			if pod.Namespace == "" {
-				validationErrors = append(validationErrors, "pod_namespace is empty")
-			}
-			if pod.Image == "" {
-				validationErrors = append(validationErrors, "image is empty")
+			unresolved := unresolvedFields(coreInfo, pod)
+			data := &reporter.CoredumpUploadedData{
+				CoredumpRef:  events.ref(),
+				FileURL:      url,
+				FileSize:     fileSize,
+				Image:        pod.Image,
+				Unresolved:   unresolved,
+				Completeness: completeness(unresolved),
+				LegacyPath:   pod.IsLegacyPath,
+				Pod:          events.podMetadata(pod),
 			}
-			if pod.NodeIP == "" {
-				validationErrors = append(validationErrors, "node_ip is empty")
+			if coreInfo != nil {
+				data.ExecutablePath = coreInfo.ExecutablePath
+				data.FileSize = coreInfo.FileSize
+				data.MD5 = coreInfo.MD5
 			}
-
-			if len(validationErrors) > 0 {
-				logrus.Errorf("skip reporting to CoreSight due to missing fields: %v, file=%s", validationErrors, corefilePath)
-				continue
-			}
-
-			data := &reporter.CoredumpUploadedData{
-				FileURL:        url,
-				FileName:       filename,
-				ExecutablePath: coreInfo.ExecutablePath,
-				FileSize:       coreInfo.FileSize,
-				MD5:            coreInfo.MD5,
-				Image:          pod.Image,
-				Timestamp:      time.Now().UTC().Format(time.RFC3339),
-				PodName:        pod.Name,
-				PodNamespace:   pod.Namespace,
-				NodeIP:         pod.NodeIP,
+			if len(unresolved) > 0 {
+				logrus.Warnf("reporting %s with unresolved metadata %v (completeness %.2f)", filename, unresolved, data.Completeness)
 			}
+			events.report(reporter.EventTypeUploaded, data)
 
-			if err := csReporter.ReportCoredumpUploaded(context.Background(), data); err != nil {
-				logrus.Errorf("failed to report coredump to CoreSight: %v", err)
-			} else {
-				logrus.Infof("CoreSight event reported: executable=%s, size=%d, md5=%s",
-					coreInfo.ExecutablePath, coreInfo.FileSize, coreInfo.MD5)
+			if podEnricher != nil {
+				podEnricher.add(events, corefilePath, coreInfo, pod)
 			}
 		}
 	}
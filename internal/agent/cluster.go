@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/DomineCore/coredog/internal/kube"
+	"github.com/DomineCore/coredog/internal/reporter"
+	"github.com/sirupsen/logrus"
+)
+
+// clusterIdentity core dump 所在集群的标识，用于区分共用存储、事件 sink 和通知渠道的多个集群
+type clusterIdentity struct {
+	Name string
+	ID   string
+}
+
+// resolveCluster 读取集群标识配置，未配置 ID 时使用 kube-system namespace 的 UID
+func resolveCluster(cfg *cfgpkg.Config, enableLookup bool) clusterIdentity {
+	c := clusterIdentity{Name: cfg.Cluster.Name, ID: cfg.Cluster.ID}
+	if c.ID == "" && enableLookup {
+		client, err := kube.Client()
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			c.ID, err = kube.ClusterID(ctx, client)
+			cancel()
+		}
+		if err != nil {
+			logrus.Warnf("failed to resolve cluster id: %v", err)
+		}
+	}
+	if c.label() != "" {
+		logrus.Infof("cluster identity: name=%q, id=%q", c.Name, c.ID)
+	}
+	return c
+}
+
+// label 集群的显示名称，未设置名称时使用 ID
+func (c clusterIdentity) label() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.ID
+}
+
+// eventSource 事件的 source 属性：coredog-agent/<集群>，没有集群标识时为 coredog-agent
+func (c clusterIdentity) eventSource() string {
+	if l := c.label(); l != "" {
+		return reporter.DefaultSource + "/" + url.PathEscape(l)
+	}
+	return reporter.DefaultSource
+}
+
+// storagePrefix 是否在存储路径中加上集群目录
+// 未设置 Cluster.storagePrefix 时，配置了集群名称或 ID 即开启；仅自动获取到 ID 时保持原有路径
+func storagePrefix(cfg *cfgpkg.Config) bool {
+	if cfg.Cluster.StoragePrefix != nil {
+		return *cfg.Cluster.StoragePrefix
+	}
+	return cfg.Cluster.Name != "" || cfg.Cluster.ID != ""
+}
+
+// storeDir 返回 core 文件的存储目录，开启 Cluster.storagePrefix 时加上集群目录
+func (c clusterIdentity) storeDir(cfg *cfgpkg.Config) string {
+	if !storagePrefix(cfg) || c.label() == "" {
+		return cfg.StorageConfig.StoreDir
+	}
+	return filepath.Join(cfg.StorageConfig.StoreDir, c.label())
+}
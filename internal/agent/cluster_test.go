@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"testing"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/DomineCore/coredog/internal/podresolver"
+)
+
+func TestClusterIdentity(t *testing.T) {
+	cfg := &cfgpkg.Config{}
+	cfg.StorageConfig.StoreDir = "corefiles"
+
+	named := clusterIdentity{Name: "prod eu-1", ID: "6b1c5a0e-0000-0000-0000-000000000001"}
+	idOnly := clusterIdentity{ID: "6b1c5a0e-0000-0000-0000-000000000001"}
+
+	if got := named.eventSource(); got != "coredog-agent/prod%20eu-1" {
+		t.Errorf("unexpected source %q", got)
+	}
+	if got := idOnly.eventSource(); got != "coredog-agent/6b1c5a0e-0000-0000-0000-000000000001" {
+		t.Errorf("unexpected source %q", got)
+	}
+	if got := (clusterIdentity{}).eventSource(); got != "coredog-agent" {
+		t.Errorf("unexpected source %q", got)
+	}
+
+	// 未配置集群时保持原有的存储路径，自动获取的集群 ID 不改变路径
+	if got := idOnly.storeDir(cfg); got != "corefiles" {
+		t.Errorf("unexpected store dir %q", got)
+	}
+	// 配置了集群名称时默认加上集群目录
+	cfg.Cluster.Name = "prod eu-1"
+	if got := named.storeDir(cfg); got != "corefiles/prod eu-1" {
+		t.Errorf("unexpected store dir %q", got)
+	}
+	if got := (clusterIdentity{}).storeDir(cfg); got != "corefiles" {
+		t.Errorf("unexpected store dir %q", got)
+	}
+	// 明确关闭时保持原有路径
+	disabled := false
+	cfg.Cluster.StoragePrefix = &disabled
+	if got := named.storeDir(cfg); got != "corefiles" {
+		t.Errorf("unexpected store dir %q", got)
+	}
+}
+
+func TestBuildNotifyMessageCluster(t *testing.T) {
+	cfg := &cfgpkg.Config{MessageTemplate: "[{cluster.name}] {pod.namespace}/{pod.name} ({cluster.id})"}
+	pod := podresolver.PodInfo{Name: "app-0", Namespace: "default"}
+
+	got := buildNotifyMessage(cfg, clusterIdentity{Name: "prod", ID: "uid-1"}, "/corefile/core.app.1", "", pod, nil)
+	if want := "[prod] default/app-0 (uid-1)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// 未设置名称时使用集群 ID
+	got = buildNotifyMessage(cfg, clusterIdentity{ID: "uid-1"}, "/corefile/core.app.1", "", pod, nil)
+	if want := "[uid-1] default/app-0 (uid-1)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	alert := buildAlert(clusterIdentity{Name: "prod", ID: "uid-1"}, "/corefile/core.app.1", "", pod, nil)
+	if alert.Cluster != "prod" || alert.ClusterID != "uid-1" {
+		t.Errorf("unexpected alert cluster %q/%q", alert.Cluster, alert.ClusterID)
+	}
+}
@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/DomineCore/coredog/internal/coreparser"
+	"github.com/DomineCore/coredog/internal/kube"
+	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newCoreDumpWriter 创建 CoreDump 资源写入器，无法创建 client 时返回 nil
+func newCoreDumpWriter() *kube.CoreDumpWriter {
+	client, err := kube.Dynamic()
+	if err != nil {
+		logrus.Warnf("CoreDump resources disabled: %v", err)
+		return nil
+	}
+	logrus.Info("recording CoreDump resources")
+	return kube.NewCoreDumpWriter(client)
+}
+
+// buildCoreDump 生成 CoreDump 资源，名称使用 coredump ID
+func buildCoreDump(events *lifecycle, url, protocol string, uploadErr error, pod podresolver.PodInfo, coreInfo *coreparser.CoreInfo, fileSize int64) *kube.CoreDump {
+	ref := events.ref()
+	cd := &kube.CoreDump{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ref.CoredumpID,
+			Namespace: pod.Namespace,
+		},
+		Spec: kube.CoreDumpSpec{
+			CoredumpID:  ref.CoredumpID,
+			Pod:         kube.CoreDumpPod{Name: pod.Name, UID: pod.UID},
+			Container:   pod.ContainerName,
+			Image:       pod.Image,
+			Node:        os.Getenv("NODE_NAME"),
+			NodeIP:      pod.NodeIP,
+			FileName:    ref.FileName,
+			Size:        fileSize,
+			CollectedAt: metav1.NewTime(time.Now().UTC()),
+		},
+	}
+	if pod.NameUnresolved {
+		// 占位名称不是真实的 Pod 名称
+		cd.Spec.Pod.Name = ""
+	}
+	if coreInfo != nil {
+		cd.Spec.Executable = coreInfo.ExecutablePath
+		cd.Spec.Signal = coreInfo.SignalName
+		cd.Spec.SignalNumber = coreInfo.Signal
+		if coreInfo.FileSize > 0 {
+			cd.Spec.Size = coreInfo.FileSize
+		}
+		cd.Spec.Digests = kube.CoreDumpDigests{MD5: coreInfo.MD5, SHA256: coreInfo.SHA256}
+		cd.Spec.Fingerprint = kube.Fingerprint(coreInfo.ExecutablePath, coreInfo.SignalName, pod.Image)
+	}
+
+	unresolved := unresolvedFields(coreInfo, pod)
+	cd.Status = kube.CoreDumpStatus{
+		Phase:        kube.CoreDumpPhaseUploaded,
+		Unresolved:   unresolved,
+		Completeness: completeness(unresolved),
+	}
+	var qerr *quotaExceeded
+	if errors.As(uploadErr, &qerr) {
+		cd.Status.Phase = kube.CoreDumpPhaseQuotaExceeded
+		cd.Status.Message = uploadErr.Error()
+		cd.Status.LocalFile = "Retained"
+	} else if uploadErr != nil {
+		cd.Status.Phase = kube.CoreDumpPhaseUploadFailed
+		cd.Status.Message = uploadErr.Error()
+		cd.Status.LocalFile = "Retained"
+	} else {
+		cd.Spec.Storage = kube.CoreDumpStorage{Protocol: protocol, URL: url}
+	}
+	return cd
+}
+
+// coreDumpResource 一次 core dump 处理过程中对应的 CoreDump 资源
+// 未启用、namespace 未知或创建失败时所有操作均为空操作
+type coreDumpResource struct {
+	writer    *kube.CoreDumpWriter
+	namespace string
+	name      string
+	initial   *kube.CoreDumpStatus // 创建时未能写入的初始状态，随下一次更新一起写入
+}
+
+// createCoreDump 创建 CoreDump 资源，同名资源已存在（续传上次上传失败的 core）时覆盖
+// ownerReference 只在 Pod 名称和 UID 都已解析时设置
+func createCoreDump(writer *kube.CoreDumpWriter, ownerReference bool, cd *kube.CoreDump, pod podresolver.PodInfo) *coreDumpResource {
+	if writer == nil {
+		return nil
+	}
+	if cd.Namespace == "" {
+		logrus.Debugf("skip CoreDump resource for %s: namespace not resolved", cd.Spec.FileName)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r := &coreDumpResource{writer: writer, namespace: cd.Namespace, name: cd.Name}
+	if err := writer.CreateOrReplace(ctx, cd, ownerReference && !pod.NameUnresolved); errors.Is(err, kube.ErrStatusNotSet) {
+		logrus.Warnf("%v, will retry with the next status update", err)
+		initial := cd.Status
+		r.initial = &initial
+		return r
+	} else if err != nil {
+		logrus.Warnf("failed to record CoreDump resource: %v", err)
+		return nil
+	}
+	logrus.Debugf("created CoreDump %s/%s", cd.Namespace, cd.Name)
+	return r
+}
+
+// update 更新 CoreDump 状态中非空的字段
+func (r *coreDumpResource) update(status kube.CoreDumpStatus) {
+	if r == nil {
+		return
+	}
+	if r.initial != nil {
+		status = mergeStatus(*r.initial, status)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := r.writer.UpdateStatus(ctx, r.namespace, r.name, status); err != nil {
+		logrus.Warnf("%v", err)
+		return
+	}
+	r.initial = nil
+}
+
+// mergeStatus 用 update 中非空的字段覆盖 base
+func mergeStatus(base, update kube.CoreDumpStatus) kube.CoreDumpStatus {
+	if update.Phase != "" {
+		base.Phase = update.Phase
+	}
+	if update.Message != "" {
+		base.Message = update.Message
+	}
+	if update.Handler != "" {
+		base.Handler = update.Handler
+	}
+	if update.LocalFile != "" {
+		base.LocalFile = update.LocalFile
+	}
+	if update.Unresolved != nil {
+		base.Unresolved = update.Unresolved
+	}
+	if update.Completeness != 0 {
+		base.Completeness = update.Completeness
+	}
+	return base
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DomineCore/coredog/internal/kube"
+	"github.com/DomineCore/coredog/internal/podresolver"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCreateCoreDumpStatusFailure(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{kube.CoreDumpGVR: "CoreDumpList"})
+	statusDown := true
+	client.PrependReactor("patch", "coredumps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if statusDown {
+			return true, nil, apierrors.NewServiceUnavailable("apiserver unavailable")
+		}
+		return false, nil, nil
+	})
+
+	cd := &kube.CoreDump{
+		ObjectMeta: metav1.ObjectMeta{Name: "dump-1", Namespace: "myns"},
+		Spec:       kube.CoreDumpSpec{CoredumpID: "dump-1", FileName: "core.1"},
+		Status:     kube.CoreDumpStatus{Phase: kube.CoreDumpPhaseUploaded, Completeness: 1},
+	}
+	r := createCoreDump(kube.NewCoreDumpWriter(client), false, cd, podresolver.PodInfo{})
+	if r == nil {
+		t.Fatal("created CoreDump should be returned when the status update fails")
+	}
+
+	// 初始状态随下一次更新写入
+	statusDown = false
+	r.update(kube.CoreDumpStatus{LocalFile: "Deleted"})
+	obj, err := client.Resource(kube.CoreDumpGVR).Namespace("myns").Get(context.Background(), "dump-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	localFile, _, _ := unstructured.NestedString(obj.Object, "status", "localFile")
+	if phase != kube.CoreDumpPhaseUploaded || localFile != "Deleted" {
+		t.Errorf("status = %v", obj.Object["status"])
+	}
+	if r.initial != nil {
+		t.Error("initial status should be cleared after a successful update")
+	}
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/sirupsen/logrus"
+)
+
+// crashRecordRetention 崩溃记录保留时间，每次写入新记录时清理过期记录
+const crashRecordRetention = 7 * 24 * time.Hour
+
+// CoreHelperOptions core_pattern 管道处理程序的参数，路径均为宿主机路径
+type CoreHelperOptions struct {
+	PID      int    // %P：宿主机 PID namespace 中的进程号
+	Comm     string // %e：进程名
+	Hostname string // %h
+	Time     string // %t：崩溃时间（Unix 秒）
+	Dir      string // core 文件写入目录，即 watcher 监听的 corefile 目录在宿主机上的路径
+	StateDir string // watcher StateDir 在宿主机上的路径，崩溃记录写入其中的 crashes/ 子目录
+	ProcRoot string // 宿主机 /proc
+}
+
+// crashRecordDir StateDir 下保存崩溃记录的目录
+func crashRecordDir(stateDir string) string {
+	return filepath.Join(stateDir, "crashes")
+}
+
+// HandleCore 作为 core_pattern 管道处理程序运行：先记录崩溃进程的 cgroup，再把 core 从 stdin 写入 Dir
+// 内核在 core 读完之前不会回收崩溃进程，因此必须先读取 /proc/<pid>/cgroup 再读取 core
+// 文件名与默认 core_pattern 相同（core.%e.%P.%h.%t），watcher 按文件名找到对应的崩溃记录
+func HandleCore(opts CoreHelperOptions, core io.Reader) error {
+	if opts.PID <= 0 {
+		return fmt.Errorf("invalid pid %d", opts.PID)
+	}
+	if opts.Time == "" {
+		opts.Time = fmt.Sprint(time.Now().Unix())
+	}
+	name := fmt.Sprintf("core.%s.%d.%s.%s", opts.Comm, opts.PID, opts.Hostname, opts.Time)
+
+	// 记录失败时仍然保存 core，watcher 退回到按路径解析
+	records := crashRecordDir(opts.StateDir)
+	if _, err := podresolver.RecordCrash(opts.ProcRoot, records, name, opts.PID, opts.Comm); err != nil {
+		logrus.Warnf("failed to record crash of pid %d: %v", opts.PID, err)
+	}
+	podresolver.PruneCrashRecords(records, crashRecordRetention)
+
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(opts.Dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create core file: %w", err)
+	}
+	if _, err := io.Copy(f, core); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write core file %s: %w", f.Name(), err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DomineCore/coredog/internal/podresolver"
+)
+
+func TestHandleCore(t *testing.T) {
+	const containerID = "3f4e5d6c7b8a99887766554433221100ffeeddccbbaa00112233445566778899"
+	procRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(procRoot, "4242"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cgroup := "0::/kubepods.slice/cri-containerd-" + containerID + ".scope\n"
+	if err := os.WriteFile(filepath.Join(procRoot, "4242", "cgroup"), []byte(cgroup), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := CoreHelperOptions{
+		PID:      4242,
+		Comm:     "myapp",
+		Hostname: "node-1",
+		Time:     "1700000000",
+		Dir:      filepath.Join(t.TempDir(), "dumps"),
+		StateDir: t.TempDir(),
+		ProcRoot: procRoot,
+	}
+	if err := HandleCore(opts, strings.NewReader("\x7fELF core")); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(opts.Dir, "core.myapp.4242.node-1.1700000000")
+	if data, err := os.ReadFile(path); err != nil || string(data) != "\x7fELF core" {
+		t.Fatalf("core file = %q, %v", data, err)
+	}
+	podresolver.SetCrashRecordDir(crashRecordDir(opts.StateDir))
+	defer podresolver.SetCrashRecordDir("")
+	rec, ok := podresolver.ReadCrashRecord(path)
+	if !ok || rec.ContainerID != containerID || rec.HostPID != 4242 {
+		t.Errorf("crash record = %+v, %v", rec, ok)
+	}
+
+	// 已存在的 core 不会被覆盖
+	if err := HandleCore(opts, strings.NewReader("other")); err == nil {
+		t.Error("expected error for existing core file")
+	}
+}
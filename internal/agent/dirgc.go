@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/sirupsen/logrus"
+)
+
+// podDirOwner 判断 namespace 下的 Pod 目录是否仍属于本节点上的 Pod，由 podresolver.PodCache 实现
+type podDirOwner interface {
+	OwnsDir(namespace, dir string) bool
+}
+
+// runDirGC 定期清理 corefileDir 下已不在本节点上的 Pod 的目录，依赖已同步的 Pod 缓存
+func runDirGC(corefileDir string, interval, minAge time.Duration, unwatch func(dir string)) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	// 首次清理等待 Pod 缓存同步
+	timer := time.NewTimer(time.Minute)
+	defer timer.Stop()
+	for range timer.C {
+		if c := podresolver.GetPodCache(); c != nil {
+			pruned := pruneDirs(corefileDir, c, minAge, time.Now(), unwatch)
+			if pruned > 0 {
+				logrus.Infof("removed %d core directories of pods no longer on this node", pruned)
+			}
+		} else {
+			logrus.Debug("pod cache not ready, skipped core directory cleanup")
+		}
+		timer.Reset(interval)
+	}
+}
+
+// pruneDirs 删除 root/<namespace>/<pod>/ 中不属于任何 Pod 的目录（包括 TTL 内已删除的 Pod），返回删除的 Pod 目录数
+// 仍有文件（未处理或未删除的 core）以及 minAge 内有变化的目录会被保留
+// 删除成功后才移除目录的 inotify 监听：检查之后又写入了 core 时删除失败，目录仍被监听，core 不会漏掉
+// namespace 目录在其中的 Pod 目录全部被删除后一并删除
+func pruneDirs(root string, owner podDirOwner, minAge time.Duration, now time.Time, unwatch func(dir string)) int {
+	namespaces, err := os.ReadDir(root)
+	if err != nil {
+		logrus.Warnf("failed to read %s: %v", root, err)
+		return 0
+	}
+	pruned := 0
+	for _, ns := range namespaces {
+		if !ns.IsDir() || strings.HasPrefix(ns.Name(), ".") {
+			continue
+		}
+		nsDir := filepath.Join(root, ns.Name())
+		pods, err := os.ReadDir(nsDir)
+		if err != nil {
+			logrus.Warnf("failed to read %s: %v", nsDir, err)
+			continue
+		}
+		removed := 0
+		for _, pod := range pods {
+			if !pod.IsDir() || owner.OwnsDir(ns.Name(), pod.Name()) {
+				continue
+			}
+			podDir := filepath.Join(nsDir, pod.Name())
+			if ok, reason := prunable(podDir, minAge, now); !ok {
+				logrus.Debugf("keeping %s: %s", podDir, reason)
+				continue
+			}
+			if err := removeEmptyDirs(podDir); err != nil {
+				logrus.Warnf("failed to remove %s: %v", podDir, err)
+				continue
+			}
+			if unwatch != nil {
+				unwatch(podDir)
+			}
+			logrus.Debugf("removed core directory %s", podDir)
+			removed++
+		}
+		pruned += removed
+		// 期间新建了 Pod 目录时删除失败，忽略即可
+		if removed > 0 && removed == len(pods) && os.Remove(nsDir) == nil && unwatch != nil {
+			unwatch(nsDir)
+		}
+	}
+	return pruned
+}
+
+// prunable 目录中只有空目录且 minAge 内没有变化时才能删除
+func prunable(dir string, minAge time.Duration, now time.Time) (bool, string) {
+	ok, reason := true, ""
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			ok, reason = false, err.Error()
+			return filepath.SkipAll
+		}
+		if !info.IsDir() {
+			ok, reason = false, "contains "+path
+			return filepath.SkipAll
+		}
+		if now.Sub(info.ModTime()) < minAge {
+			ok, reason = false, "modified at "+info.ModTime().Format(time.RFC3339)
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return ok, reason
+}
+
+// removeEmptyDirs 自底向上删除 dir 及其中的空目录，遇到文件（如刚写入的 core）时失败，不会删除文件
+func removeEmptyDirs(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			if err := removeEmptyDirs(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Remove(dir)
+}
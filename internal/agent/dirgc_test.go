@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// ownedDirs 以 namespace/dir 列出仍有 Pod 的目录
+type ownedDirs map[string]bool
+
+func (o ownedDirs) OwnsDir(namespace, dir string) bool {
+	return o[namespace+"/"+dir]
+}
+
+func TestPruneDirs(t *testing.T) {
+	root := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	mkdir := func(rel string, mtime time.Time) {
+		t.Helper()
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mkdir("default/app-0/app", old)
+	mkdir("default/pod-0a1b2c3d/app", old) // 仍在运行
+	mkdir("default/gone-1/app", old)
+	mkdir("default/gone-1/sidecar", old)
+	mkdir("default/pending-1/app", old) // 有未处理的 core
+	if err := os.WriteFile(filepath.Join(root, "default/pending-1/app/core.app.1"), []byte("core"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mkdir("default/new-1/app", time.Now()) // 刚创建
+	mkdir("batch/job-1/main", old)         // namespace 下的 Pod 全部已删除
+	for _, dir := range []string{"default/gone-1", "default/pending-1", "batch/job-1", "default/app-0", "default/pod-0a1b2c3d"} {
+		mkdir(dir, old)
+	}
+
+	var unwatched []string
+	owner := ownedDirs{"default/app-0": true, "default/pod-0a1b2c3d": true}
+	pruned := pruneDirs(root, owner, time.Hour, time.Now(), func(dir string) {
+		rel, _ := filepath.Rel(root, dir)
+		unwatched = append(unwatched, rel)
+		// 删除成功后才移除监听
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s unwatched before it was removed", rel)
+		}
+	})
+	if pruned != 2 {
+		t.Errorf("expected 2 pruned directories, got %d", pruned)
+	}
+
+	for rel, exists := range map[string]bool{
+		"default/app-0/app":                true,
+		"default/pod-0a1b2c3d/app":         true,
+		"default/pending-1/app/core.app.1": true,
+		"default/new-1/app":                true,
+		"default/gone-1":                   false,
+		"batch":                            false,
+	} {
+		_, err := os.Stat(filepath.Join(root, rel))
+		if (err == nil) != exists {
+			t.Errorf("%s: exists=%v, want %v", rel, err == nil, exists)
+		}
+	}
+
+	sort.Strings(unwatched)
+	want := []string{"batch", "batch/job-1", "default/gone-1"}
+	if len(unwatched) != len(want) {
+		t.Fatalf("unwatched %v, want %v", unwatched, want)
+	}
+	for i := range want {
+		if unwatched[i] != want[i] {
+			t.Errorf("unwatched %v, want %v", unwatched, want)
+		}
+	}
+}
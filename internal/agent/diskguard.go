@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/DomineCore/coredog/internal/diskguard"
+	"github.com/DomineCore/coredog/internal/kube"
+	"github.com/DomineCore/coredog/internal/notice"
+	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/DomineCore/coredog/internal/reporter"
+	"github.com/sirupsen/logrus"
+)
+
+// newDiskGuard 创建 CorefileDir 的磁盘保护，action 默认为 none（只告警）
+// alert 发送磁盘告警；onReclaim 在清理 core 文件后、发送清理告警前调用，用于上报生命周期事件
+func newDiskGuard(cfg *cfgpkg.Config, cluster clusterIdentity, queue *coreQueue, alert func(message string), onReclaim func(files []diskguard.Reclaimed)) *diskguard.Guard {
+	action := cfg.DiskGuard.Action
+	if action == "" {
+		action = diskguard.ActionNone
+	}
+	return &diskguard.Guard{
+		Dir:        cfg.CorefileDir,
+		Config:     cfg.DiskGuard,
+		Action:     action,
+		Candidates: queue.candidates,
+		Claim:      queue.claim,
+		OnReclaim: func(files []diskguard.Reclaimed) {
+			if onReclaim != nil {
+				onReclaim(files)
+			}
+			alert(buildReclaimMessage(cluster, files))
+		},
+		OnLevelChange: func(from, to diskguard.Level, usage diskguard.Usage) {
+			alert(buildDiskLevelMessage(cluster, cfg.CorefileDir, to, usage))
+		},
+	}
+}
+
+// dispatchDiskAlert 按通知路由发送磁盘告警，告警不属于任何 Pod，只能按集群和节点匹配路由
+func dispatchDiskAlert(router *notice.Router, dispatcher *notice.Dispatcher, cluster clusterIdentity, dir, message string) {
+	alert := notice.Alert{
+		Cluster:   cluster.label(),
+		ClusterID: cluster.ID,
+		Node:      nodeName(),
+		Time:      time.Now(),
+	}
+	dispatchAlert(router, dispatcher, message, alert, podresolver.PodInfo{}, dir)
+}
+
+// reclaimedLocalFile 清理方式对应的 CoreDump status.localFile
+func reclaimedLocalFile(action string) string {
+	if action == diskguard.ActionTruncate {
+		return "Truncated"
+	}
+	return "Deleted"
+}
+
+// reportReclaimed 上报被磁盘保护清理的 core：已处理的 core 沿用原有的 coredump_id 并更新 CoreDump 资源；
+// 尚未处理（等待上传）的 core 先上报 detected 并以错误创建 CoreDump，避免其静默消失
+func reportReclaimed(f diskguard.Reclaimed, rec *retainedCore, newCore func(path string, size int64) *retainedCore) {
+	if rec == nil {
+		rec = newCore(f.Path, f.Size)
+	}
+	rec.events.report(reporter.EventTypeDeletedLocal, &reporter.CoredumpDeletedLocalData{
+		CoredumpRef: rec.events.ref(),
+		FilePath:    f.Path,
+		Method:      f.Action,
+	})
+	rec.coreDump.update(kube.CoreDumpStatus{LocalFile: reclaimedLocalFile(f.Action)})
+}
+
+// diskAlertHeader 磁盘告警的公共部分：集群和节点
+func diskAlertHeader(b *strings.Builder, cluster clusterIdentity) {
+	if l := cluster.label(); l != "" {
+		fmt.Fprintf(b, "☸️ Cluster: `%s`\n", l)
+	}
+	fmt.Fprintf(b, "🖥️ Node: `%s`\n", nodeName())
+}
+
+// nodeName 本节点名称，未设置 NODE_NAME 时为节点 IP
+func nodeName() string {
+	if node := os.Getenv("NODE_NAME"); node != "" {
+		return node
+	}
+	return getHostIP()
+}
+
+// buildDiskLevelMessage 磁盘压力等级变化的告警内容
+func buildDiskLevelMessage(cluster clusterIdentity, dir string, level diskguard.Level, u diskguard.Usage) string {
+	var b strings.Builder
+	switch level {
+	case diskguard.Critical:
+		b.WriteString("🚨 **Core dump 目录磁盘空间严重不足**\n\n")
+	case diskguard.Pressure:
+		b.WriteString("⚠️ **Core dump 目录磁盘空间不足**\n\n")
+	default:
+		b.WriteString("✅ **Core dump 目录磁盘空间已恢复**\n\n")
+	}
+	diskAlertHeader(&b, cluster)
+	fmt.Fprintf(&b, "📂 目录: `%s`\n", dir)
+	fmt.Fprintf(&b, "💾 空闲空间: %.1f%% (%s / %s)\n", u.FreePercent(), formatSize(int64(u.FreeBytes)), formatSize(int64(u.TotalBytes)))
+	fmt.Fprintf(&b, "🗂️ 空闲 inode: %.1f%%\n", u.FreeInodesPercent())
+	switch level {
+	case diskguard.Critical:
+		b.WriteString("已开始清理低优先级的 core 文件")
+	case diskguard.Pressure:
+		b.WriteString("优先上传最早的 core 文件")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// buildReclaimMessage 磁盘保护清理 core 文件的告警内容
+func buildReclaimMessage(cluster clusterIdentity, files []diskguard.Reclaimed) string {
+	var b strings.Builder
+	b.WriteString("🧹 **磁盘空间不足，已清理未上传或已上传的 core 文件**\n\n")
+	diskAlertHeader(&b, cluster)
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	fmt.Fprintf(&b, "共 %d 个文件，释放 %s：\n", len(files), formatSize(total))
+	for _, f := range files {
+		fmt.Fprintf(&b, "- `%s` (%s, %s)\n", f.Path, f.Action, formatSize(f.Size))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// startDiskGuard 启动磁盘保护，返回的 Guard 供处理队列判断压力等级
+func startDiskGuard(cfg *cfgpkg.Config, cluster clusterIdentity, queue *coreQueue, alert func(message string), onReclaim func(files []diskguard.Reclaimed)) *diskguard.Guard {
+	g := newDiskGuard(cfg, cluster, queue, alert, onReclaim)
+	go g.Run(time.Duration(cfg.DiskGuard.IntervalSeconds)*time.Second, make(chan struct{}))
+	logrus.Infof("disk guard enabled for %s: pressure below %.1f%% space / %.1f%% inodes, critical below %.1f%% / %.1f%% (action %s)",
+		cfg.CorefileDir, cfg.DiskGuard.PressureFree(), cfg.DiskGuard.PressureFreeInodes(),
+		cfg.DiskGuard.CriticalFree(), cfg.DiskGuard.CriticalFreeInodes(), g.Action)
+	return g
+}
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DomineCore/coredog/internal/diskguard"
+	"github.com/DomineCore/coredog/internal/kube"
+	"github.com/DomineCore/coredog/internal/podresolver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestReportReclaimed(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{kube.CoreDumpGVR: "CoreDumpList"})
+	writer := kube.NewCoreDumpWriter(client)
+	pod := podresolver.PodInfo{Name: "app-0", Namespace: "myns"}
+	localFile := func(name string) string {
+		t.Helper()
+		obj, err := client.Resource(kube.CoreDumpGVR).Namespace("myns").Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, _, _ := unstructured.NestedString(obj.Object, "status", "localFile")
+		return v
+	}
+	newCore := func(name string) *retainedCore {
+		cd := &kube.CoreDump{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "myns"},
+			Spec:       kube.CoreDumpSpec{CoredumpID: name, FileName: "core." + name},
+			Status:     kube.CoreDumpStatus{LocalFile: "Retained"},
+		}
+		return &retainedCore{
+			events:   newLifecycle(nil, "core."+name, pod, clusterIdentity{}, nil),
+			coreDump: createCoreDump(writer, false, cd, pod),
+		}
+	}
+
+	// 已上传的 core：更新原有的 CoreDump
+	reportReclaimed(diskguard.Reclaimed{Path: "/corefile/core.dump-1", Action: diskguard.ActionTruncate, Size: 100},
+		newCore("dump-1"), func(string, int64) *retainedCore {
+			t.Fatal("unexpected new core dump for a processed core")
+			return nil
+		})
+	if got := localFile("dump-1"); got != "Truncated" {
+		t.Errorf("localFile = %q, want Truncated", got)
+	}
+
+	// 等待上传的 core：先创建 CoreDump 再更新
+	var created string
+	reportReclaimed(diskguard.Reclaimed{Path: "/corefile/core.dump-2", Action: diskguard.ActionRemove, Size: 100},
+		nil, func(path string, size int64) *retainedCore {
+			created = path
+			return newCore("dump-2")
+		})
+	if created != "/corefile/core.dump-2" {
+		t.Errorf("new core dump created for %q", created)
+	}
+	if got := localFile("dump-2"); got != "Deleted" {
+		t.Errorf("localFile = %q, want Deleted", got)
+	}
+}
@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DomineCore/coredog/internal/coreparser"
+	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/DomineCore/coredog/internal/reporter"
+	"github.com/sirupsen/logrus"
+)
+
+// maxEnrichJobs 等待补全元数据的 core dump 数上限，超过后新的 core dump 不再补全
+const maxEnrichJobs = 1000
+
+// enrichJob 一个等待重新解析 Pod 信息的 core dump
+type enrichJob struct {
+	events       *lifecycle
+	corefilePath string
+	coreInfo     *coreparser.CoreInfo
+	pod          podresolver.PodInfo
+	unresolved   []string
+	reportedAt   time.Time
+	step         int // 下一次使用的 enrichDelays 下标
+	due          time.Time
+}
+
+// enricher 在后台按 enrichDelays 重新解析元数据不完整的 core dump 的 Pod 信息，
+// 补全了任何字段时上报 coredog.coredump.enriched 事件。
+// 例如 Pod 在 core dump 时已被删除，之后可以从 informer 的 tombstone 缓存中找到。
+// 所有 core dump 由同一个 worker 按到期时间依次处理，等待中的任务数不超过 maxEnrichJobs
+type enricher struct {
+	resolve func(corefilePath string) podresolver.PodInfo // 与首次上报使用相同的解析方式
+	delays  []time.Duration
+
+	mu   sync.Mutex
+	jobs []*enrichJob
+	wake chan struct{}
+}
+
+func newEnricher(resolve func(corefilePath string) podresolver.PodInfo) *enricher {
+	return &enricher{resolve: resolve, delays: enrichDelays, wake: make(chan struct{}, 1)}
+}
+
+// add 元数据不完整时加入补全队列
+func (e *enricher) add(events *lifecycle, corefilePath string, coreInfo *coreparser.CoreInfo, pod podresolver.PodInfo) {
+	unresolved := unresolvedFields(coreInfo, pod)
+	if len(podUnresolved(unresolved)) == 0 || len(e.delays) == 0 {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.jobs) >= maxEnrichJobs {
+		logrus.Warnf("too many core dumps waiting for metadata enrichment, skipped %s", events.base.FileName)
+		return
+	}
+	now := time.Now()
+	e.jobs = append(e.jobs, &enrichJob{
+		events:       events,
+		corefilePath: corefilePath,
+		coreInfo:     coreInfo,
+		pod:          pod,
+		unresolved:   unresolved,
+		reportedAt:   now,
+		due:          now.Add(e.delays[0]),
+	})
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run 依次处理到期的任务，直到 stop 关闭
+func (e *enricher) run(stop <-chan struct{}) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		job, wait := e.next(time.Now())
+		if job != nil {
+			e.enrich(job)
+			continue
+		}
+
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-e.wake:
+			timer.Stop()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// next 取出一个已到期的任务；没有到期任务时返回距最早任务到期的时间
+func (e *enricher) next(now time.Time) (*enrichJob, time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	wait := time.Hour
+	for i, job := range e.jobs {
+		if !job.due.After(now) {
+			e.jobs = append(e.jobs[:i], e.jobs[i+1:]...)
+			return job, 0
+		}
+		wait = min(wait, job.due.Sub(now))
+	}
+	return nil, wait
+}
+
+// enrich 重新解析 Pod 信息，仍有未解析字段且还有重试机会时重新入队
+func (e *enricher) enrich(job *enrichJob) {
+	l := job.events
+	latest := e.resolve(job.corefilePath)
+	if latest.NodeIP == "" {
+		latest.NodeIP = job.pod.NodeIP
+	}
+	latestUnresolved := unresolvedFields(job.coreInfo, latest)
+	if resolved := newlyResolved(job.unresolved, latestUnresolved); len(resolved) > 0 {
+		logrus.Infof("resolved %v for %s after %v", resolved, l.base.FileName, e.delays[job.step])
+		l.setPod(latest)
+		l.report(reporter.EventTypeEnriched, &reporter.CoredumpEnrichedData{
+			CoredumpRef:  l.ref(),
+			Image:        latest.Image,
+			Resolved:     resolved,
+			Unresolved:   latestUnresolved,
+			Completeness: completeness(latestUnresolved),
+			Pod:          l.podMetadata(latest),
+		})
+		job.pod, job.unresolved = latest, latestUnresolved
+	}
+
+	job.step++
+	if len(podUnresolved(job.unresolved)) == 0 || job.step >= len(e.delays) {
+		return
+	}
+	job.due = job.reportedAt.Add(e.delays[job.step])
+	e.mu.Lock()
+	e.jobs = append(e.jobs, job)
+	e.mu.Unlock()
+}
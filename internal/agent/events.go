@@ -0,0 +1,327 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/DomineCore/coredog/internal/coreparser"
+	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/DomineCore/coredog/internal/reporter"
+	"github.com/sirupsen/logrus"
+)
+
+// 事件 sink 的名称，用于 events replay --sink
+const (
+	sinkCoreSight = "coresight"
+	sinkKafka     = "kafka"
+)
+
+// outboxPath 返回 sink 的 outbox 文件路径（位于 StateDir 下）
+func outboxPath(cfg *cfgpkg.Config, sink string) string {
+	return filepath.Join(cfg.StateDir, sink+"-outbox.jsonl")
+}
+
+// applyOutbox 设置 outbox 路径；一次性命令（disableRetry）与 agent 同时运行，只读打开 outbox
+func applyOutbox(opts *reporter.Options, cfg *cfgpkg.Config, sink string, outbox cfgpkg.OutboxConfig) {
+	if !outbox.IsEnabled() {
+		return
+	}
+	opts.OutboxPath = outboxPath(cfg, sink)
+	opts.OutboxRetention = time.Duration(outbox.RetentionHours) * time.Hour
+	opts.OutboxReadOnly = opts.DisableRetry
+}
+
+// newCoreSightReporter 根据配置创建 CoreSight reporter
+// disableRetry 为 true 时不启动后台重试，用于一次性命令
+// source 为事件的 source 属性，为空时使用 coredog-agent
+func newCoreSightReporter(cfg *cfgpkg.Config, source string, disableRetry bool) (*reporter.Reporter, error) {
+	opts := reporter.Options{
+		Source:        source,
+		URL:           cfg.CoreSight.NatsURL,
+		Token:         cfg.CoreSight.Token,
+		Subject:       cfg.CoreSight.Subject,
+		NKeySeed:      cfg.CoreSight.NKeySeed,
+		NKeySeedFile:  cfg.CoreSight.NKeySeedFile,
+		JetStream:     cfg.CoreSightJetStream(),
+		HTTPMode:      cfg.CoreSight.HTTPMode,
+		SigningSecret: cfg.CoreSight.SigningSecret,
+		BatchSize:     cfg.CoreSight.BatchSize,
+		BatchLinger:   time.Duration(cfg.CoreSight.BatchLingerMs) * time.Millisecond,
+		DisableRetry:  disableRetry,
+	}
+	applyOutbox(&opts, cfg, sinkCoreSight, cfg.CoreSight.Outbox)
+	return reporter.New(opts)
+}
+
+// newKafkaReporter 根据配置创建 Kafka sink
+func newKafkaReporter(cfg *cfgpkg.Config, source string, disableRetry bool) (*reporter.Reporter, error) {
+	kc := cfg.Kafka
+	transport, err := reporter.NewKafkaTransport(reporter.KafkaOptions{
+		Brokers:               kc.Brokers,
+		Topic:                 kc.Topic,
+		Key:                   kc.Key,
+		ClientID:              kc.ClientID,
+		Version:               kc.Version,
+		Idempotent:            cfg.KafkaIdempotent(),
+		SASLMechanism:         kc.SASL.Mechanism,
+		SASLUsername:          kc.SASL.Username,
+		SASLPassword:          kc.SASL.Password,
+		TLSEnabled:            kc.TLS.Enabled,
+		TLSCAFile:             kc.TLS.CAFile,
+		TLSCertFile:           kc.TLS.CertFile,
+		TLSKeyFile:            kc.TLS.KeyFile,
+		TLSInsecureSkipVerify: kc.TLS.InsecureSkipVerify,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	eventTypes := kc.EventTypes
+	if len(eventTypes) == 0 {
+		eventTypes = []string{reporter.EventTypeUploaded}
+	}
+	opts := reporter.Options{
+		Name:         "Kafka",
+		Source:       source,
+		EventTypes:   eventTypes,
+		BatchLinger:  time.Duration(kc.BatchLingerMs) * time.Millisecond,
+		DisableRetry: disableRetry,
+	}
+	applyOutbox(&opts, cfg, sinkKafka, kc.Outbox)
+
+	r, err := reporter.NewWithTransport(transport, opts)
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// lifecycle 把单个 core dump 的生命周期事件上报到所有 sink，所有事件共享同一个 coredump_id
+// 没有 sink 时不上报
+type lifecycle struct {
+	reporters      []*reporter.Reporter
+	mu             sync.Mutex // 保护 base 中的 Pod 信息，补全元数据和磁盘保护可能在其它 goroutine 中上报
+	base           reporter.CoredumpRef
+	annotationKeys []string // 事件中携带的 Pod annotation，见 PodMetadata.annotations
+}
+
+func newLifecycle(reporters []*reporter.Reporter, filename string, pod podresolver.PodInfo, cluster clusterIdentity, annotationKeys []string) *lifecycle {
+	return &lifecycle{
+		reporters:      reporters,
+		annotationKeys: annotationKeys,
+		base: reporter.CoredumpRef{
+			CoredumpID:   reporter.NewEventID(),
+			FileName:     filename,
+			PodName:      pod.Name,
+			PodNamespace: pod.Namespace,
+			Container:    pod.ContainerName,
+			NodeIP:       pod.NodeIP,
+			ClusterName:  cluster.Name,
+			ClusterID:    cluster.ID,
+		},
+	}
+}
+
+// ref 返回带当前时间戳的 core dump 标识
+func (l *lifecycle) ref() reporter.CoredumpRef {
+	l.mu.Lock()
+	ref := l.base
+	l.mu.Unlock()
+	ref.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	return ref
+}
+
+// setPod 更新事件中的 Pod 信息（补全元数据后）
+func (l *lifecycle) setPod(pod podresolver.PodInfo) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.base.PodName = pod.Name
+	l.base.PodNamespace = pod.Namespace
+	l.base.Container = pod.ContainerName
+	l.base.NodeIP = pod.NodeIP
+}
+
+// enabled 是否有任何 sink
+func (l *lifecycle) enabled() bool {
+	return len(l.reporters) > 0
+}
+
+// report 上报事件，失败只记录日志（启用 outbox 时事件会被保留并重试）
+func (l *lifecycle) report(eventType string, data interface{}) {
+	for _, r := range l.reporters {
+		if err := r.Report(context.Background(), eventType, l.base.FileName, data); err != nil {
+			logrus.Errorf("failed to report %s event: %v", eventType, err)
+		}
+	}
+}
+
+// selectAnnotations 按配置挑选 annotation，key 以 / 结尾时按前缀匹配
+func selectAnnotations(annotations map[string]string, keys []string) map[string]string {
+	selected := make(map[string]string)
+	for k, v := range annotations {
+		for _, key := range keys {
+			if k == key || (strings.HasSuffix(key, "/") && strings.HasPrefix(k, key)) {
+				selected[k] = v
+				break
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+	return selected
+}
+
+// podMetadata 生成事件中的 Pod 扩展元数据，Pod 未通过 Kubernetes 解析（没有 UID）时返回 nil
+func (l *lifecycle) podMetadata(pod podresolver.PodInfo) *reporter.PodMetadata {
+	if pod.UID == "" || pod.NameUnresolved {
+		return nil
+	}
+	return &reporter.PodMetadata{
+		UID:                   pod.UID,
+		NodeName:              pod.NodeName,
+		ServiceAccount:        pod.ServiceAccount,
+		WorkloadKind:          pod.Workload.Kind,
+		WorkloadName:          pod.Workload.Name,
+		ImageDigest:           pod.ImageDigest,
+		RestartCount:          pod.RestartCount,
+		LastTerminationReason: pod.LastTerminationReason,
+		LastExitCode:          pod.LastExitCode,
+		Labels:                pod.Labels,
+		Annotations:           selectAnnotations(pod.Annotations, l.annotationKeys),
+		ContainerMatch:        containerMatch(pod.Match),
+	}
+}
+
+// containerMatch 转换容器匹配结果，没有匹配信息时返回 nil
+func containerMatch(m podresolver.ContainerMatch) *reporter.ContainerMatch {
+	if m.Method == "" && !m.Ambiguous {
+		return nil
+	}
+	return &reporter.ContainerMatch{
+		Method:     m.Method,
+		Confidence: m.Confidence,
+		Ambiguous:  m.Ambiguous,
+		Candidates: m.Candidates,
+	}
+}
+
+// metadataFields 参与完整度计算的元数据字段
+var metadataFields = []string{"executable_path", "md5", "pod_name", "pod_namespace", "container", "image", "node_ip"}
+
+// enrichDelays 上报时元数据不完整的 core dump 重新解析 Pod 信息的时间点（相对上报时间）
+var enrichDelays = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute}
+
+// unresolvedFields 返回未能解析的元数据字段
+func unresolvedFields(coreInfo *coreparser.CoreInfo, pod podresolver.PodInfo) []string {
+	values := map[string]string{
+		"pod_name":      pod.Name,
+		"pod_namespace": pod.Namespace,
+		"container":     pod.ContainerName,
+		"image":         pod.Image,
+		"node_ip":       pod.NodeIP,
+	}
+	if pod.NameUnresolved {
+		values["pod_name"] = ""
+	}
+	if coreInfo != nil {
+		values["executable_path"] = coreInfo.ExecutablePath
+		values["md5"] = coreInfo.MD5
+	}
+
+	unresolved := []string{}
+	for _, f := range metadataFields {
+		if values[f] == "" {
+			unresolved = append(unresolved, f)
+		}
+	}
+	return unresolved
+}
+
+// completeness 已解析字段占比，保留两位小数
+func completeness(unresolved []string) float64 {
+	score := 1 - float64(len(unresolved))/float64(len(metadataFields))
+	return math.Round(score*100) / 100
+}
+
+// podUnresolved 只保留 Pod 相关的未解析字段（core 文件本身的字段无法通过重新解析 Pod 补全）
+func podUnresolved(unresolved []string) []string {
+	var fields []string
+	for _, f := range unresolved {
+		if f != "executable_path" && f != "md5" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// newlyResolved 返回 before 中未解析、after 中已解析的字段
+func newlyResolved(before, after []string) []string {
+	still := make(map[string]bool, len(after))
+	for _, f := range after {
+		still[f] = true
+	}
+	var resolved []string
+	for _, f := range before {
+		if !still[f] {
+			resolved = append(resolved, f)
+		}
+	}
+	return resolved
+}
+
+// ReplayEvents 重新发送 sink outbox 中 [since, until) 范围内的事件
+func ReplayEvents(sink string, since, until time.Time, eventType string, dryRun bool) error {
+	cfg := cfgpkg.Get()
+
+	var r *reporter.Reporter
+	var err error
+	switch sink {
+	case sinkCoreSight:
+		if !cfg.CoreSight.Enabled {
+			return fmt.Errorf("CoreSight is not enabled")
+		}
+		if !cfg.CoreSight.Outbox.IsEnabled() {
+			return fmt.Errorf("CoreSight outbox is not enabled")
+		}
+		r, err = newCoreSightReporter(cfg, "", true)
+		if err == nil && r == nil {
+			return fmt.Errorf("CoreSight URL is not configured")
+		}
+	case sinkKafka:
+		if !cfg.Kafka.Enabled {
+			return fmt.Errorf("Kafka sink is not enabled")
+		}
+		if !cfg.Kafka.Outbox.IsEnabled() {
+			return fmt.Errorf("Kafka outbox is not enabled")
+		}
+		r, err = newKafkaReporter(cfg, "", true)
+	default:
+		return fmt.Errorf("unknown sink %q (expect %s or %s)", sink, sinkCoreSight, sinkKafka)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	events, err := r.Replay(context.Background(), since, until, eventType, dryRun)
+	for _, event := range events {
+		logrus.Infof("event %s type=%s time=%s", event.ID, event.Type, event.Time)
+	}
+	if err != nil {
+		return fmt.Errorf("replayed %d events before failure: %w", len(events), err)
+	}
+	if dryRun {
+		logrus.Infof("%d events match, nothing sent (dry run)", len(events))
+	} else {
+		logrus.Infof("replayed %d events to %s", len(events), sink)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DomineCore/coredog/internal/coreparser"
+	"github.com/DomineCore/coredog/internal/podresolver"
+)
+
+func TestUnresolvedFields(t *testing.T) {
+	coreInfo := &coreparser.CoreInfo{ExecutablePath: "/app/bin/app", MD5: "d41d8cd98f00b204e9800998ecf8427e"}
+	full := podresolver.PodInfo{Name: "app-0", Namespace: "default", ContainerName: "app", Image: "app:v1", NodeIP: "10.0.0.1"}
+
+	tests := []struct {
+		name         string
+		coreInfo     *coreparser.CoreInfo
+		pod          podresolver.PodInfo
+		unresolved   []string
+		completeness float64
+	}{
+		{name: "complete", coreInfo: coreInfo, pod: full, unresolved: []string{}, completeness: 1},
+		{
+			// Pod 已被删除：只能从路径得到 namespace/pod/container，node_ip 来自本节点
+			name:         "terminated_pod",
+			coreInfo:     coreInfo,
+			pod:          podresolver.PodInfo{Name: "app-0", Namespace: "default", ContainerName: "app", NodeIP: "10.0.0.1"},
+			unresolved:   []string{"image"},
+			completeness: 0.86,
+		},
+		{
+			name:         "parse_failed_legacy_placeholder",
+			coreInfo:     nil,
+			pod:          podresolver.PodInfo{Name: "pod-0a1b2c3d", NameUnresolved: true, Namespace: "default", IsLegacyPath: true},
+			unresolved:   []string{"executable_path", "md5", "pod_name", "container", "image", "node_ip"},
+			completeness: 0.14,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unresolved := unresolvedFields(tt.coreInfo, tt.pod)
+			if !reflect.DeepEqual(unresolved, tt.unresolved) {
+				t.Errorf("unresolved = %v, want %v", unresolved, tt.unresolved)
+			}
+			if got := completeness(unresolved); got != tt.completeness {
+				t.Errorf("completeness = %v, want %v", got, tt.completeness)
+			}
+		})
+	}
+}
+
+func TestNewlyResolved(t *testing.T) {
+	got := newlyResolved([]string{"md5", "image", "node_ip"}, []string{"md5"})
+	if !reflect.DeepEqual(got, []string{"image", "node_ip"}) {
+		t.Errorf("newlyResolved = %v", got)
+	}
+	if got := newlyResolved([]string{"image"}, []string{"image"}); len(got) != 0 {
+		t.Errorf("expected nothing resolved, got %v", got)
+	}
+}
+
+func TestEnricher(t *testing.T) {
+	coreInfo := &coreparser.CoreInfo{ExecutablePath: "/app/bin/app", MD5: "d41d8cd98f00b204e9800998ecf8427e"}
+	partial := podresolver.PodInfo{Name: "app-0", Namespace: "default", ContainerName: "app", NodeIP: "10.0.0.1"}
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	done := make(chan struct{}, 4)
+	e := newEnricher(func(corefilePath string) podresolver.PodInfo {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[corefilePath]++
+		done <- struct{}{}
+		pod := partial
+		// 第二次解析时从缓存中找到了 Pod
+		if corefilePath == "/corefile/default/app-0/app/core.1" && calls[corefilePath] == 2 {
+			pod.Image = "app:v1"
+		}
+		return pod
+	})
+	e.delays = []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond}
+	stop := make(chan struct{})
+	defer close(stop)
+	go e.run(stop)
+
+	resolved := newLifecycle(nil, "core.1", partial, clusterIdentity{}, nil)
+	e.add(resolved, "/corefile/default/app-0/app/core.1", coreInfo, partial)
+	e.add(newLifecycle(nil, "core.2", partial, clusterIdentity{}, nil), "/corefile/default/app-0/app/core.2", coreInfo, partial)
+	// 元数据完整时不加入队列
+	e.add(newLifecycle(nil, "core.3", partial, clusterIdentity{}, nil), "/corefile/default/app-0/app/core.3", coreInfo,
+		podresolver.PodInfo{Name: "app-0", Namespace: "default", ContainerName: "app", Image: "app:v1", NodeIP: "10.0.0.1"})
+
+	// core.1 解析两次后补全，core.2 按 delays 解析三次
+	for i := 0; i < 5; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("enricher stalled after %d resolves", i)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]int{"/corefile/default/app-0/app/core.1": 2, "/corefile/default/app-0/app/core.2": 3}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("resolve calls = %v, want %v", calls, want)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.jobs) != 0 {
+		t.Errorf("%d jobs left in queue", len(e.jobs))
+	}
+}
+
+func TestEnricherBounded(t *testing.T) {
+	e := newEnricher(func(string) podresolver.PodInfo { return podresolver.PodInfo{} })
+	events := newLifecycle(nil, "core.1", podresolver.PodInfo{}, clusterIdentity{}, nil)
+	for i := 0; i < maxEnrichJobs+10; i++ {
+		e.add(events, "/corefile/core.1", nil, podresolver.PodInfo{})
+	}
+	if len(e.jobs) != maxEnrichJobs {
+		t.Errorf("queued %d jobs, want at most %d", len(e.jobs), maxEnrichJobs)
+	}
+}
+
+func TestBuildPodEventMessage(t *testing.T) {
+	pod := podresolver.PodInfo{Name: "app-0", Namespace: "default", ContainerName: "app"}
+	coreInfo := &coreparser.CoreInfo{ExecutablePath: "/app/bin/app", SignalName: "SIGSEGV", FileSize: 50 * 1024 * 1024}
+
+	got := buildPodEventMessage("/corefile/default/app-0/app/core.app.1", "https://bucket/core.app.1", nil, pod, coreInfo, 0)
+	want := "Container app: /app/bin/app dumped core (SIGSEGV), file core.app.1, size 50.0MiB, download: https://bucket/core.app.1"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	got = buildPodEventMessage("/corefile/default/app-0/app/core.app.1", "", errors.New("access denied"), pod, nil, 512)
+	want = "Container app: unknown executable dumped core, file core.app.1, size 512B, upload failed: access denied"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestSelectAnnotations(t *testing.T) {
+	annotations := map[string]string{
+		"coredog.io/notify-channel": "team-a",
+		"coredog.io/path":           "/corefile",
+		"team":                      "payments",
+		"kubectl.kubernetes.io/last-applied-configuration": "{}",
+	}
+	got := selectAnnotations(annotations, []string{"coredog.io/", "team"})
+	want := map[string]string{"coredog.io/notify-channel": "team-a", "coredog.io/path": "/corefile", "team": "payments"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := selectAnnotations(annotations, nil); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+}
@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DomineCore/coredog/internal/coreparser"
+	"github.com/DomineCore/coredog/internal/kube"
+	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/sirupsen/logrus"
+)
+
+// Kubernetes Event 上的 annotation
+const (
+	eventAnnotationCoredumpID  = "coredog.io/coredump-id"
+	eventAnnotationFileName    = "coredog.io/file-name"
+	eventAnnotationDownloadURL = "coredog.io/download-url"
+)
+
+// newKubeRecorder 创建 Kubernetes Event 记录器，无法创建 client 时返回 nil
+func newKubeRecorder() *kube.Recorder {
+	client, err := kube.Client()
+	if err != nil {
+		logrus.Warnf("kubernetes events disabled: %v", err)
+		return nil
+	}
+	logrus.Info("recording CoreDumped events on pods")
+	return kube.NewRecorder(client, os.Getenv("NODE_NAME"))
+}
+
+// formatSize 以人类可读的方式格式化文件大小
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// buildPodEventMessage 生成 CoreDumped 事件内容
+// uploadErr 非空时说明上传失败或超出配额未上传，下载地址替换为原因
+func buildPodEventMessage(corefilePath, url string, uploadErr error, pod podresolver.PodInfo, coreInfo *coreparser.CoreInfo, fileSize int64) string {
+	executable := "unknown executable"
+	signal := ""
+	if coreInfo != nil {
+		if coreInfo.ExecutablePath != "" {
+			executable = coreInfo.ExecutablePath
+		}
+		signal = coreInfo.SignalName
+		if coreInfo.FileSize > 0 {
+			fileSize = coreInfo.FileSize
+		}
+	}
+
+	var b strings.Builder
+	if pod.ContainerName != "" {
+		fmt.Fprintf(&b, "Container %s: ", pod.ContainerName)
+	}
+	fmt.Fprintf(&b, "%s dumped core", executable)
+	if signal != "" {
+		fmt.Fprintf(&b, " (%s)", signal)
+	}
+	fmt.Fprintf(&b, ", file %s, size %s", filepath.Base(corefilePath), formatSize(fileSize))
+	var qerr *quotaExceeded
+	if errors.As(uploadErr, &qerr) {
+		fmt.Fprintf(&b, ", not uploaded: %v", uploadErr)
+	} else if uploadErr != nil {
+		fmt.Fprintf(&b, ", upload failed: %v", uploadErr)
+	} else if url != "" {
+		fmt.Fprintf(&b, ", download: %s", url)
+	}
+	return b.String()
+}
+
+// recordPodEvent 在崩溃的 Pod 上记录 CoreDumped 事件
+// Pod 未解析出 UID 时跳过（kubectl describe 按 UID 关联事件）
+func recordPodEvent(recorder *kube.Recorder, events *lifecycle, corefilePath, url string, uploadErr error, pod podresolver.PodInfo, coreInfo *coreparser.CoreInfo, fileSize int64) {
+	if recorder == nil {
+		return
+	}
+	if pod.UID == "" || pod.NameUnresolved {
+		logrus.Debugf("skip kubernetes event for %s: pod not resolved", corefilePath)
+		return
+	}
+
+	annotations := map[string]string{
+		eventAnnotationCoredumpID: events.base.CoredumpID,
+		eventAnnotationFileName:   filepath.Base(corefilePath),
+	}
+	if url != "" {
+		annotations[eventAnnotationDownloadURL] = url
+	}
+	recorder.CoreDumped(kube.PodRef{Namespace: pod.Namespace, Name: pod.Name, UID: pod.UID},
+		buildPodEventMessage(corefilePath, url, uploadErr, pod, coreInfo, fileSize), annotations)
+}
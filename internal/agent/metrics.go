@@ -0,0 +1,18 @@
+package agent
+
+import (
+	"expvar"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// serveMetrics 在 addr 上以 expvar JSON 格式提供 agent 指标（/debug/vars），如配额的上传和拒绝计数
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	logrus.Infof("serving metrics on %s/debug/vars", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logrus.Errorf("metrics server stopped: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"os"
+	"time"
+
+	"github.com/DomineCore/coredog/internal/kube"
+	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/sirupsen/logrus"
+)
+
+// startPodCache 在后台启动本节点的 Pod 缓存，同步完成前 podresolver 直接查询 API
+func startPodCache(tombstoneTTL time.Duration) {
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		logrus.Warn("NODE_NAME is not set, pod cache disabled")
+		return
+	}
+	client, err := kube.Client()
+	if err != nil {
+		logrus.Warnf("pod cache disabled: %v", err)
+		return
+	}
+	c, err := podresolver.NewPodCache(client, nodeName, tombstoneTTL)
+	if err != nil {
+		logrus.Warnf("pod cache disabled: %v", err)
+		return
+	}
+	go func() {
+		if err := c.Start(make(chan struct{})); err != nil {
+			logrus.Warnf("pod cache disabled: %v", err)
+			return
+		}
+		podresolver.SetPodCache(c)
+		logrus.Infof("pod cache synced for node %s (tombstone ttl %v)", nodeName, tombstoneTTL)
+	}()
+}
@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/DomineCore/coredog/internal/quota"
+)
+
+// coreQueue 等待处理的 core 文件
+// 正常情况下按 priority（数值小的先处理，相同时按到达顺序）处理；oldestFirst 返回 true（磁盘压力）时优先处理修改时间最早的文件。
+// 同时记录已上传但保留在本地的文件，供磁盘保护清理
+type coreQueue struct {
+	mu          sync.Mutex
+	cond        *sync.Cond
+	pending     []pendingCore
+	inflight    string
+	retained    []string                 // 已处理、保留在本地的文件，按处理顺序
+	records     map[string]*retainedCore // 保留的文件的生命周期，磁盘保护清理后由 release 取出
+	oldestFirst func() bool
+	priority    func(path string, fi os.FileInfo) int // 为 nil 时按到达顺序
+}
+
+// pendingCore 等待处理的文件，修改时间和优先级在加入队列时计算一次，出队时不再访问文件系统
+type pendingCore struct {
+	path     string
+	modTime  time.Time // 无法 stat（已被删除）时为零值，磁盘压力下优先取出，由处理流程报错丢弃
+	priority int
+	// coredumpID 上次 agent 运行中未完成上传的 core 的 coredump ID，续传时不再作为新的 core dump 上报
+	coredumpID string
+}
+
+func newCoreQueue(oldestFirst func() bool, priority func(path string, fi os.FileInfo) int) *coreQueue {
+	q := &coreQueue{oldestFirst: oldestFirst, priority: priority, records: make(map[string]*retainedCore)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push 加入一个新的 core 文件
+func (q *coreQueue) push(path string) {
+	q.pushResumed(path, "")
+}
+
+// pushResumed 加入一个续传的 core 文件，在加锁之前 stat 文件并计算优先级
+func (q *coreQueue) pushResumed(path, coredumpID string) {
+	c := pendingCore{path: path, coredumpID: coredumpID}
+	fi, err := os.Stat(path)
+	if err != nil {
+		fi = nil
+	} else {
+		c.modTime = fi.ModTime()
+	}
+	if q.priority != nil {
+		c.priority = q.priority(path, fi)
+	}
+	q.mu.Lock()
+	q.pending = append(q.pending, c)
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// pop 阻塞直到有待处理的文件，取出的文件在下一次 pop 之前视为正在处理
+func (q *coreQueue) pop() pendingCore {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inflight = ""
+	for len(q.pending) == 0 {
+		q.cond.Wait()
+	}
+	i := 0
+	switch {
+	case q.oldestFirst != nil && q.oldestFirst():
+		i = oldestFile(q.pending)
+	case q.priority != nil:
+		i = highestPriority(q.pending)
+	}
+	c := q.pending[i]
+	q.pending = append(q.pending[:i], q.pending[i+1:]...)
+	q.inflight = c.path
+	return c
+}
+
+// retainedCore 保留在本地的 core 的生命周期事件和 CoreDump 资源，磁盘保护清理后用于上报
+type retainedCore struct {
+	events   *lifecycle
+	coreDump *coreDumpResource
+}
+
+// retain 记录处理完成但保留在本地的文件（已上传或超出配额），之后可以被磁盘保护清理
+func (q *coreQueue) retain(path string, rec *retainedCore) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.retained = append(q.retained, path)
+	q.records[path] = rec
+	if q.inflight == path {
+		q.inflight = ""
+	}
+}
+
+// release 取出被磁盘保护清理的文件的记录，文件尚未处理（等待上传）时返回 nil
+func (q *coreQueue) release(path string) *retainedCore {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	rec := q.records[path]
+	delete(q.records, path)
+	return rec
+}
+
+// claim 磁盘保护清理文件前将其移出队列，文件正在处理时返回 false
+func (q *coreQueue) claim(path string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if path == q.inflight {
+		return false
+	}
+	q.pending = withoutPending(q.pending, path)
+	q.retained = without(q.retained, path)
+	return true
+}
+
+// candidates 磁盘临界时可清理的文件，按优先级从低到高：
+// 已上传的文件（从旧到新），然后是等待上传的文件（从新到旧，让最旧的先上传）；正在处理的文件除外
+func (q *coreQueue) candidates() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	files := append([]string{}, q.retained...)
+	for i := len(q.pending) - 1; i >= 0; i-- {
+		files = append(files, q.pending[i].path)
+	}
+	return without(files, q.inflight)
+}
+
+// oldestFile 返回修改时间最早的文件下标，相同时取最早到达的
+func oldestFile(pending []pendingCore) int {
+	oldest := 0
+	for i, c := range pending {
+		if c.modTime.Before(pending[oldest].modTime) {
+			oldest = i
+		}
+	}
+	return oldest
+}
+
+// highestPriority 返回优先级数值最小的文件下标，相同时取最早到达的
+func highestPriority(pending []pendingCore) int {
+	best := 0
+	for i, c := range pending {
+		if c.priority < pending[best].priority {
+			best = i
+		}
+	}
+	return best
+}
+
+// 上传优先级，见 config.UploadPriority
+const (
+	priorityCritical = iota
+	prioritySmall
+	priorityNormal
+)
+
+// uploadPriority 按 StorageConfig.priority 计算 core 文件的上传优先级，namespace 取自 corefileDir 下的第一级目录
+// 无法 stat 的文件（fi 为 nil，已被删除）视为小文件，尽快由处理流程报错丢弃
+func uploadPriority(corefileDir string, cfg cfgpkg.UploadPriority) (func(path string, fi os.FileInfo) int, error) {
+	smallSize, err := quota.ParseSize(cfg.SmallCoreSize)
+	if err != nil {
+		return nil, fmt.Errorf("StorageConfig.priority.smallCoreSize: %w", err)
+	}
+	for _, pattern := range cfg.CriticalNamespaces {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("StorageConfig.priority.criticalNamespaces: invalid pattern %q", pattern)
+		}
+	}
+	return func(path string, fi os.FileInfo) int {
+		if ns := pathNamespace(corefileDir, path); ns != "" {
+			for _, pattern := range cfg.CriticalNamespaces {
+				if ok, _ := filepath.Match(pattern, ns); ok {
+					return priorityCritical
+				}
+			}
+		}
+		if smallSize <= 0 {
+			return priorityNormal
+		}
+		if fi == nil || fi.Size() <= smallSize {
+			return prioritySmall
+		}
+		return priorityNormal
+	}, nil
+}
+
+// pathNamespace core 文件所在的 namespace 目录（corefileDir/<namespace>/...），不在子目录中时为空
+func pathNamespace(corefileDir, path string) string {
+	rel, err := filepath.Rel(corefileDir, path)
+	if err != nil {
+		return ""
+	}
+	ns, _, ok := strings.Cut(filepath.ToSlash(rel), "/")
+	if !ok || ns == ".." {
+		return ""
+	}
+	return ns
+}
+
+func withoutPending(pending []pendingCore, path string) []pendingCore {
+	out := pending[:0]
+	for _, c := range pending {
+		if c.path != path {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func without(paths []string, path string) []string {
+	out := paths[:0]
+	for _, p := range paths {
+		if p != path {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+)
+
+func TestCoreQueue(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	var paths []string
+	for i, name := range []string{"core.b", "core.a", "core.c"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("core"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// core.a 最旧
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		if name == "core.a" {
+			mtime = base.Add(-time.Minute)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	pressure := false
+	q := newCoreQueue(func() bool { return pressure }, nil)
+	for _, p := range paths {
+		q.push(p)
+	}
+
+	// 正常情况下按到达顺序
+	if got := q.pop().path; got != paths[0] {
+		t.Errorf("expected %s, got %s", paths[0], got)
+	}
+	q.retain(paths[0], nil)
+
+	// 磁盘压力下优先处理最旧的文件
+	pressure = true
+	if got := q.pop().path; got != paths[1] {
+		t.Errorf("expected oldest %s, got %s", paths[1], got)
+	}
+
+	// 清理顺序：已上传的文件，然后是等待上传的文件（从新到旧），正在处理的文件除外
+	q.push(filepath.Join(dir, "core.d"))
+	want := []string{paths[0], filepath.Join(dir, "core.d"), paths[2]}
+	if got := q.candidates(); !reflect.DeepEqual(got, want) {
+		t.Errorf("candidates %v, want %v", got, want)
+	}
+	if q.claim(paths[1]) {
+		t.Error("claimed in-flight core")
+	}
+	if !q.claim(paths[2]) {
+		t.Error("failed to claim pending core")
+	}
+	// 被清理的文件不再处理
+	if got := q.pop().path; got != filepath.Join(dir, "core.d") {
+		t.Errorf("expected core.d, got %s", got)
+	}
+}
+
+func TestCoreQueuePriority(t *testing.T) {
+	dir := t.TempDir()
+	write := func(rel string, size int) string {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	huge := write("batch/etl-0/java/core.java.1", 4096)
+	small := write("batch/cron-0/app/core.app.2", 10)
+	critical := write("payments-prod/api-0/api/core.api.3", 4096)
+	small2 := write("default/web-0/web/core.web.4", 10)
+
+	priority, err := uploadPriority(dir, cfgpkg.UploadPriority{CriticalNamespaces: []string{"payments-*"}, SmallCoreSize: "1Ki"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := newCoreQueue(nil, priority)
+	for _, p := range []string{huge, small, critical, small2} {
+		q.push(p)
+	}
+	// 优先级在加入队列时计算，之后文件变化不影响出队顺序
+	if err := os.WriteFile(small2, make([]byte, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 关键 namespace，然后是小文件（按到达顺序），最后是大文件
+	for _, want := range []string{critical, small, small2, huge} {
+		if got := q.pop().path; got != want {
+			t.Errorf("pop = %s, want %s", got, want)
+		}
+	}
+
+	if _, err := uploadPriority(dir, cfgpkg.UploadPriority{SmallCoreSize: "small"}); err == nil {
+		t.Error("expected error for invalid smallCoreSize")
+	}
+	if ns := pathNamespace(dir, filepath.Join(dir, "core.1")); ns != "" {
+		t.Errorf("core in corefileDir root: namespace %q", ns)
+	}
+	if ns := pathNamespace(dir, "/elsewhere/ns/core.1"); ns != "" {
+		t.Errorf("core outside corefileDir: namespace %q", ns)
+	}
+}
@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/DomineCore/coredog/internal/coreparser"
+	"github.com/DomineCore/coredog/internal/kube"
+	"github.com/DomineCore/coredog/internal/notice"
+	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/DomineCore/coredog/internal/quota"
+	"github.com/DomineCore/coredog/internal/reporter"
+	"github.com/sirupsen/logrus"
+)
+
+// quotaExceeded 超出配额未上传，作为上传错误传给 CoreDump 资源和 Pod 事件
+type quotaExceeded struct {
+	decision quota.Decision
+	size     int64
+}
+
+func (e *quotaExceeded) Error() string {
+	d := e.decision
+	switch d.Reason {
+	case quota.ReasonCoreSize:
+		return fmt.Sprintf("%s exceeded: core size %s > %s", d.Reason, formatSize(e.size), formatSize(d.Limit))
+	case quota.ReasonCoresPerHour:
+		return fmt.Sprintf("%s exceeded: %d cores uploaded in the last hour (limit %d)", d.Reason, d.Used, d.Limit)
+	default:
+		return fmt.Sprintf("%s exceeded: %s uploaded in the last 24h, %s more would exceed %s",
+			d.Reason, formatSize(d.Used), formatSize(e.size), formatSize(d.Limit))
+	}
+}
+
+// checkQuota 检查 Pod 所属工作负载的配额，未设置任何限制时直接放行
+func checkQuota(limiter *quota.Limiter, pod podresolver.PodInfo, size int64) quota.Decision {
+	workload := workloadName(pod)
+	policy, err := limiter.Policy(pod.Namespace, workload, pod.Annotations)
+	if err != nil {
+		logrus.Warnf("ignoring invalid quota annotations on %s/%s: %v", pod.Namespace, pod.Name, err)
+	}
+	if !policy.Limited() {
+		return quota.Decision{Allowed: true, Policy: policy}
+	}
+	return limiter.Admit(pod.Namespace, workload, policy, size)
+}
+
+// recordQuota core 上传成功后计入工作负载的上传量
+func recordQuota(limiter *quota.Limiter, pod podresolver.PodInfo, size int64) {
+	limiter.Record(pod.Namespace, workloadName(pod), size)
+}
+
+// handleQuotaExceeded 超出配额的 core 只上报元数据：不计算摘要、不上传、不执行自定义处理器，本地文件保留
+// 配额告警按通知路由发送，超出频率配额时每个窗口只发送一次；返回创建的 CoreDump 资源，磁盘保护清理本地文件后更新
+func handleQuotaExceeded(cfg *cfgpkg.Config, router *notice.Router, dispatcher *notice.Dispatcher, cluster clusterIdentity,
+	events *lifecycle, recorder *kube.Recorder, writer *kube.CoreDumpWriter,
+	corefilePath string, pod podresolver.PodInfo, fileSize int64, d quota.Decision) *coreDumpResource {
+	coreInfo, err := coreparser.ParseMetadata(corefilePath)
+	if err != nil {
+		logrus.Warnf("failed to read metadata of %s: %v", corefilePath, err)
+		coreInfo = nil
+	}
+	qerr := &quotaExceeded{decision: d, size: fileSize}
+	logrus.Warnf("not uploading %s of %s/%s: %v (%d cores, %s rejected for %s)",
+		corefilePath, pod.Namespace, workloadName(pod), qerr, d.Rejected, formatSize(d.RejectedBytes), d.Reason)
+
+	data := &reporter.CoredumpQuotaExceededData{
+		CoredumpRef:   events.ref(),
+		FilePath:      corefilePath,
+		FileSize:      fileSize,
+		Reason:        d.Reason,
+		Limit:         d.Limit,
+		Used:          d.Used,
+		Rejected:      d.Rejected,
+		RejectedBytes: d.RejectedBytes,
+		Image:         pod.Image,
+		Pod:           events.podMetadata(pod),
+	}
+	if coreInfo != nil {
+		data.ExecutablePath = coreInfo.ExecutablePath
+		data.Signal = coreInfo.SignalName
+	}
+	events.report(reporter.EventTypeQuotaExceeded, data)
+
+	recordPodEvent(recorder, events, corefilePath, "", qerr, pod, coreInfo, fileSize)
+	coreDump := createCoreDump(writer, cfg.CoreDumpOwnerReference(),
+		buildCoreDump(events, "", cfg.StorageConfig.Protocol, qerr, pod, coreInfo, fileSize), pod)
+
+	if d.Notify {
+		alert := buildAlert(cluster, corefilePath, "", pod, coreInfo)
+		dispatchAlert(router, dispatcher, buildQuotaMessage(cluster, corefilePath, pod, coreInfo, fileSize, qerr), alert, pod, corefilePath)
+	}
+	return coreDump
+}
+
+// buildQuotaMessage 配额告警的内容
+func buildQuotaMessage(cluster clusterIdentity, corefilePath string, pod podresolver.PodInfo, coreInfo *coreparser.CoreInfo, fileSize int64, qerr *quotaExceeded) string {
+	d := qerr.decision
+	var b strings.Builder
+	b.WriteString("🚫 **Core 文件超出配额，未上传**\n\n")
+	if l := cluster.label(); l != "" {
+		fmt.Fprintf(&b, "☸️ Cluster: `%s`\n", l)
+	}
+	fmt.Fprintf(&b, "📦 Pod: `%s/%s`\n", pod.Namespace, pod.Name)
+	fmt.Fprintf(&b, "🏷️ 工作负载: `%s`\n", workloadName(pod))
+	fmt.Fprintf(&b, "📄 文件: `%s` (%s)\n", filepath.Base(corefilePath), formatSize(fileSize))
+	if coreInfo != nil && coreInfo.ExecutablePath != "" {
+		fmt.Fprintf(&b, "🔧 可执行文件: `%s`", coreInfo.ExecutablePath)
+		if coreInfo.SignalName != "" {
+			fmt.Fprintf(&b, " (%s)", coreInfo.SignalName)
+		}
+		b.WriteString("\n")
+	}
+	switch d.Reason {
+	case quota.ReasonCoreSize:
+		fmt.Fprintf(&b, "⛔ 超出单个 core 大小上限 %s\n", formatSize(d.Limit))
+	case quota.ReasonCoresPerHour:
+		fmt.Fprintf(&b, "⛔ 最近一小时已上传 %d 个 core，上限 %d\n", d.Used, d.Limit)
+	case quota.ReasonBytesPerDay:
+		fmt.Fprintf(&b, "⛔ 最近 24 小时已上传 %s，上限 %s\n", formatSize(d.Used), formatSize(d.Limit))
+	}
+	fmt.Fprintf(&b, "📊 累计因此未上传: %d 个 core，共 %s\n", d.Rejected, formatSize(d.RejectedBytes))
+	if d.Reason != quota.ReasonCoreSize {
+		b.WriteString("本窗口内后续超出配额的 core 不再重复告警")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,8 @@
+package agent
+
+import "github.com/DomineCore/coredog/internal/podresolver"
+
+// resolvePod 解析 core 文件对应的 Pod，启用 CRI 时先使用 core-helper 记录的容器 ID 查询容器运行时
+func resolvePod(pidResolver *podresolver.PIDResolver, corefilePath string, enableLookup bool) podresolver.PodInfo {
+	return podresolver.ResolveCrash(pidResolver, corefilePath, enableLookup)
+}
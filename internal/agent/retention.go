@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/DomineCore/coredog/internal/retention"
+	"github.com/DomineCore/coredog/internal/store"
+	"github.com/sirupsen/logrus"
+)
+
+// RunGC 按 Retention 配置删除存储中过期的 core 文件（coredog gc），dryRun 时只输出将要删除的对象
+// 开启断点续传时同时放弃超过 abortAfterHours 仍未完成的分片上传
+// 开启 Cluster.storagePrefix 时只处理本集群目录下的对象；否则需要 allowUnscoped 确认 bucket 只由本集群使用
+func RunGC(dryRun, allowUnscoped bool) error {
+	cfg := cfgpkg.Get()
+	if !cfg.StorageConfig.Enabled {
+		return fmt.Errorf("storage is not enabled")
+	}
+	enableLookup := strings.ToLower(strings.TrimSpace(os.Getenv("KUBE_LOOKUP"))) != "false"
+	cluster := resolveCluster(cfg, enableLookup)
+
+	client, err := newStore(cfg, cluster)
+	if err != nil {
+		return err
+	}
+	manager, ok := client.(store.Manager)
+	if !ok {
+		return fmt.Errorf("storage protocol %q does not support listing objects", cfg.StorageConfig.Protocol)
+	}
+	tmpl, err := store.ParseKeyTemplate(cfg.StorageConfig.KeyTemplate)
+	if err != nil {
+		return fmt.Errorf("invalid StorageConfig.keyTemplate: %w", err)
+	}
+	warnUnattributedPolicies(cfg.Retention, tmpl)
+
+	c := &retention.Collector{
+		Store:         manager,
+		Template:      tmpl,
+		Config:        cfg.Retention,
+		DryRun:        dryRun,
+		Scoped:        storagePrefix(cfg) && cluster.label() != "",
+		AllowUnscoped: allowUnscoped,
+	}
+	result, err := c.Run(context.Background())
+	if errors.Is(err, retention.ErrUnscoped) {
+		return fmt.Errorf("%w: set Cluster.name to store core files under a cluster directory, or pass --allow-unscoped if the bucket is not shared with other clusters", err)
+	}
+	if result != nil {
+		action := "deleted"
+		if dryRun {
+			action = "would delete"
+		}
+		logrus.Infof("retention: scanned %d objects (%d bytes), %s %d objects (%d bytes), %d expired objects kept by legal hold",
+			result.Scanned, result.ScannedBytes, action, len(result.Deleted), result.DeletedBytes, len(result.Held))
+	}
+	// 与保留策略使用相同的范围：只清理本集群目录（或确认不共用的 bucket）下未完成的分片上传
+	if err == nil && !dryRun && cfg.StorageConfig.Resume.IsEnabled() {
+		abortStaleUploads(client, cfg.StorageConfig.Resume.AbortAfter())
+	}
+	return err
+}
+
+// warnUnattributedPolicies key 模板中缺少 namespace 或工作负载时，对应的策略不会生效
+func warnUnattributedPolicies(cfg cfgpkg.RetentionConfig, tmpl *store.KeyTemplate) {
+	if len(cfg.Namespaces) > 0 && !tmpl.Uses("namespace") {
+		logrus.Warn("retention: StorageConfig.keyTemplate has no {namespace}, namespace policies are ignored")
+	}
+	perWorkload := cfg.Default.MaxPerWorkload > 0
+	for _, ns := range cfg.Namespaces {
+		perWorkload = perWorkload || ns.MaxPerWorkload > 0
+	}
+	if perWorkload && !tmpl.Uses("workload") && !tmpl.Uses("pod") {
+		logrus.Warn("retention: StorageConfig.keyTemplate has no {workload} or {pod}, maxPerWorkload is ignored")
+	}
+}
@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/DomineCore/coredog/internal/coreparser"
+	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/DomineCore/coredog/internal/quota"
+	"github.com/DomineCore/coredog/internal/store"
+	"github.com/sirupsen/logrus"
+)
+
+// newStore 按 StorageConfig 创建 Store，存储目录见 clusterIdentity.storeDir
+func newStore(cfg *cfgpkg.Config, cluster clusterIdentity) (store.Store, error) {
+	opts, err := uploadOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return store.NewStore(
+		cfg.StorageConfig.Protocol,
+		cfg.StorageConfig.S3Region,
+		cfg.StorageConfig.S3AccessKeyID,
+		cfg.StorageConfig.S3SecretAccessKey,
+		cfg.StorageConfig.S3Bucket,
+		cfg.StorageConfig.S3Endpoint,
+		cfg.StorageConfig.CFSMountPath,
+		cluster.storeDir(cfg),
+		cfg.StorageConfig.PresignedURLExpireSeconds,
+		cfg.StorageConfig.IfNoneMatch,
+		opts,
+	)
+}
+
+// uploadOptions StorageConfig 中的分片大小、并发数、带宽上限和断点续传状态目录，带宽限制由本 agent 的所有上传共享
+func uploadOptions(cfg *cfgpkg.Config) (store.UploadOptions, error) {
+	partSize, err := quota.ParseSize(cfg.StorageConfig.PartSize)
+	if err != nil {
+		return store.UploadOptions{}, fmt.Errorf("StorageConfig.partSize: %w", err)
+	}
+	bandwidth, err := quota.ParseSize(cfg.StorageConfig.MaxBandwidth)
+	if err != nil {
+		return store.UploadOptions{}, fmt.Errorf("StorageConfig.maxBandwidth: %w", err)
+	}
+	if bandwidth > 0 {
+		logrus.Infof("upload bandwidth limited to %s/s", formatSize(bandwidth))
+	}
+	opts := store.UploadOptions{
+		PartSize:    partSize,
+		Concurrency: cfg.StorageConfig.Concurrency,
+		Limiter:     store.NewRateLimiter(bandwidth),
+	}
+	if cfg.StorageConfig.Resume.IsEnabled() {
+		opts.StateDir = filepath.Join(cfg.StateDir, "uploads")
+	}
+	return opts, nil
+}
+
+// parseKeyTemplate 解析 StorageConfig.keyTemplate
+// 开启 ifNoneMatch 时 key 必须每个 core 都不同，否则同名 core 的上传会一直因目标已存在而失败
+func parseKeyTemplate(cfg *cfgpkg.Config) (*store.KeyTemplate, error) {
+	tmpl, err := store.ParseKeyTemplate(cfg.StorageConfig.KeyTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl.Unique() {
+		return tmpl, nil
+	}
+	if cfg.StorageConfig.IfNoneMatch {
+		return nil, fmt.Errorf("StorageConfig.ifNoneMatch requires {md5}, {sha256} or {coredump_id} in the key template")
+	}
+	logrus.Warn("StorageConfig.keyTemplate contains no {md5}, {sha256} or {coredump_id}, core files with the same name overwrite each other")
+	return tmpl, nil
+}
+
+// storageKey 按 StorageConfig.keyTemplate 生成 core 文件在 StoreDir 下的对象 key
+// core 解析失败时摘要在这里单独计算，无法读取文件时以 coredump ID 代替，避免不同的 core 都渲染为 unknown
+func storageKey(tmpl *store.KeyTemplate, cluster clusterIdentity, coredumpID, corefilePath string, pod podresolver.PodInfo, coreInfo *coreparser.CoreInfo, now time.Time) string {
+	node := pod.NodeName
+	if node == "" {
+		node = os.Getenv("NODE_NAME")
+	}
+	now = now.UTC()
+	vars := map[string]string{
+		"cluster":     cluster.label(),
+		"namespace":   pod.Namespace,
+		"pod":         pod.Name,
+		"workload":    workloadName(pod),
+		"container":   pod.ContainerName,
+		"node":        node,
+		"date":        now.Format("2006-01-02"),
+		"year":        now.Format("2006"),
+		"month":       now.Format("01"),
+		"day":         now.Format("02"),
+		"coredump_id": coredumpID,
+		"filename":    filepath.Base(corefilePath),
+	}
+	if coreInfo != nil {
+		vars["md5"] = coreInfo.MD5
+		vars["sha256"] = coreInfo.SHA256
+		if coreInfo.ExecutablePath != "" {
+			vars["executable"] = filepath.Base(coreInfo.ExecutablePath)
+		}
+	}
+	if (tmpl.Uses("md5") && vars["md5"] == "") || (tmpl.Uses("sha256") && vars["sha256"] == "") {
+		md5Sum, sha256Sum, err := coreparser.Digests(corefilePath)
+		if err != nil {
+			logrus.Warnf("failed to calculate digests of %s for the storage key, using coredump id instead: %v", corefilePath, err)
+			md5Sum, sha256Sum = coredumpID, coredumpID
+		}
+		vars["md5"], vars["sha256"] = md5Sum, sha256Sum
+	}
+	return tmpl.Render(vars)
+}
+
+// workloadName Pod 所属工作负载的名称，未解析出 owner 时根据 Pod 名称推测
+func workloadName(pod podresolver.PodInfo) string {
+	if pod.Workload.Name != "" {
+		return pod.Workload.Name
+	}
+	return podresolver.GuessWorkloadName(pod.Name)
+}
+
+// retryBackoff 上传失败后第 n 次重试前等待 n × retryBackoff
+var retryBackoff = 5 * time.Second
+
+// upload 上传 core 文件，失败后最多重试 retries 次（开启断点续传时跳过已上传的分片，coredumpID 记录在续传状态中）
+// 开启 ifNoneMatch 时目标对象已存在：key 中包含文件摘要说明内容相同，视为上传成功；否则返回错误，保留本地文件
+func upload(client store.Store, tmpl *store.KeyTemplate, corefilePath, key, coredumpID string, retries int) (string, error) {
+	ctx := store.WithCoredumpID(context.Background(), coredumpID)
+	url, err := client.Upload(ctx, corefilePath, key)
+	for attempt := 1; attempt <= retries && err != nil && !errors.Is(err, store.ErrObjectExists); attempt++ {
+		logrus.Warnf("failed to upload %s, retrying (%d/%d): %v", corefilePath, attempt, retries, err)
+		time.Sleep(time.Duration(attempt) * retryBackoff)
+		url, err = client.Upload(ctx, corefilePath, key)
+	}
+	if errors.Is(err, store.ErrObjectExists) && tmpl.ContentAddressed() {
+		logrus.Infof("identical core file already stored at %s, skipped upload of %s", key, corefilePath)
+		return url, nil
+	}
+	return url, err
+}
+
+// resumeUploads 把上次未完成的上传连同其 coredump ID 重新放入队列，本地文件已删除或已修改的上传被放弃
+// watcher 启动时不会扫描已有的文件，未完成的上传只能从续传状态中恢复
+func resumeUploads(client store.Store, push func(path, coredumpID string)) {
+	r, ok := client.(store.Resumer)
+	if !ok {
+		return
+	}
+	pending := r.PendingUploads(context.Background())
+	for _, p := range pending {
+		push(p.Path, p.CoredumpID)
+	}
+	if len(pending) > 0 {
+		logrus.Infof("resuming %d interrupted uploads", len(pending))
+	}
+}
+
+// abortStaleUploads 放弃 StoreDir 下超过 maxAge 仍未完成的分片上传，释放其占用的存储
+// 由 coredog gc 在每个集群执行一次，而不是在每个节点的 agent 中执行，避免放弃其他节点正在续传的上传
+func abortStaleUploads(client store.Store, maxAge time.Duration) {
+	r, ok := client.(store.Resumer)
+	if !ok || maxAge <= 0 {
+		return
+	}
+	n, err := r.AbortStaleUploads(context.Background(), time.Now().Add(-maxAge))
+	if err != nil {
+		logrus.Warnf("failed to clean up incomplete multipart uploads: %v", err)
+	} else if n > 0 {
+		logrus.Infof("aborted %d incomplete multipart uploads older than %s", n, maxAge)
+	}
+}
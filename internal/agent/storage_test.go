@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/DomineCore/coredog/internal/coreparser"
+	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/DomineCore/coredog/internal/store"
+	"github.com/pkg/errors"
+)
+
+func TestStorageKey(t *testing.T) {
+	tmpl, err := store.ParseKeyTemplate("{cluster}/{namespace}/{pod}/{container}/{date}/{md5}-{filename}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := podresolver.PodInfo{Name: "app-0", Namespace: "default", ContainerName: "app"}
+	coreInfo := &coreparser.CoreInfo{MD5: "d41d8cd98f00b204e9800998ecf8427e"}
+	now := time.Date(2026, 10, 18, 23, 30, 0, 0, time.FixedZone("UTC+8", 8*3600))
+
+	got := storageKey(tmpl, clusterIdentity{Name: "prod"}, "id-1", "/corefile/core.app.1", pod, coreInfo, now)
+	if want := "prod/default/app-0/app/2026-10-18/d41d8cd98f00b204e9800998ecf8427e-core.app.1"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	// 解析失败时单独计算摘要
+	path := filepath.Join(t.TempDir(), "core.app.1")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got = storageKey(tmpl, clusterIdentity{}, "id-1", path, podresolver.PodInfo{}, nil, now)
+	if want := "unknown/unknown/unknown/unknown/2026-10-18/d41d8cd98f00b204e9800998ecf8427e-core.app.1"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	// 无法读取文件时以 coredump ID 代替，不同的 core 不会得到相同的 key
+	got = storageKey(tmpl, clusterIdentity{}, "id-2", filepath.Join(t.TempDir(), "core.app.1"), podresolver.PodInfo{}, nil, now)
+	if want := "unknown/unknown/unknown/unknown/2026-10-18/id-2-core.app.1"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestParseKeyTemplateIfNoneMatch(t *testing.T) {
+	cfg := &cfgpkg.Config{}
+	if _, err := parseKeyTemplate(cfg); err != nil {
+		t.Errorf("default template: %v", err)
+	}
+	// 只有文件名时同名 core 的上传会一直失败
+	cfg.StorageConfig.IfNoneMatch = true
+	if _, err := parseKeyTemplate(cfg); err == nil {
+		t.Error("expected error for ifNoneMatch without a unique variable")
+	}
+	cfg.StorageConfig.KeyTemplate = "{namespace}/{coredump_id}-{filename}"
+	if _, err := parseKeyTemplate(cfg); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// existingStore 目标对象总是已存在的 Store
+type existingStore struct{}
+
+func (existingStore) Upload(_ context.Context, _, key string) (string, error) {
+	return "https://bucket/" + key, errors.Wrap(store.ErrObjectExists, key)
+}
+
+func TestUploadExistingObject(t *testing.T) {
+	// key 中包含摘要：内容相同，视为上传成功
+	digest, _ := store.ParseKeyTemplate("{md5}-{filename}")
+	url, err := upload(existingStore{}, digest, "/corefile/core.app.1", "k", "dump-1", 0)
+	if err != nil || url != "https://bucket/k" {
+		t.Errorf("got (%q, %v)", url, err)
+	}
+
+	// 只有文件名：可能是不同的 core，不能当作成功
+	byName, _ := store.ParseKeyTemplate("{filename}")
+	if _, err := upload(existingStore{}, byName, "/corefile/core.app.1", "k", "dump-1", 2); !errors.Is(err, store.ErrObjectExists) {
+		t.Errorf("expected ErrObjectExists, got %v", err)
+	}
+}
+
+// flakyStore 前 failures 次上传失败的 Store
+type flakyStore struct {
+	failures int
+	calls    int
+}
+
+func (s *flakyStore) Upload(_ context.Context, _, key string) (string, error) {
+	s.calls++
+	if s.calls <= s.failures {
+		return "", errors.New("connection reset by peer")
+	}
+	return "https://bucket/" + key, nil
+}
+
+func TestUploadRetries(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = time.Millisecond
+	tmpl, _ := store.ParseKeyTemplate("{filename}")
+
+	s := &flakyStore{failures: 2}
+	if url, err := upload(s, tmpl, "/corefile/core.app.1", "k", "dump-1", 2); err != nil || url != "https://bucket/k" || s.calls != 3 {
+		t.Errorf("got (%q, %v) after %d calls", url, err, s.calls)
+	}
+	s = &flakyStore{failures: 3}
+	if _, err := upload(s, tmpl, "/corefile/core.app.1", "k", "dump-1", 2); err == nil || s.calls != 3 {
+		t.Errorf("expected failure after 3 calls, got %v after %d calls", err, s.calls)
+	}
+}
+
+// resumingStore 记录上传时携带的 coredump ID，并返回上次未完成的上传
+type resumingStore struct {
+	pending    []store.PendingUpload
+	coredumpID string
+	aborts     int
+}
+
+func (s *resumingStore) Upload(ctx context.Context, _, key string) (string, error) {
+	s.coredumpID = store.CoredumpIDFrom(ctx)
+	return "https://bucket/" + key, nil
+}
+
+func (s *resumingStore) PendingUploads(context.Context) []store.PendingUpload { return s.pending }
+
+func (s *resumingStore) AbortStaleUploads(context.Context, time.Time) (int, error) {
+	s.aborts++
+	return 0, nil
+}
+
+func TestResumeUploads(t *testing.T) {
+	s := &resumingStore{pending: []store.PendingUpload{
+		{Path: "/corefile/core.app.1", CoredumpID: "dump-1"},
+		{Path: "/corefile/core.app.2"}, // 旧版本的续传状态没有 coredump ID
+	}}
+	q := newCoreQueue(nil, nil)
+	resumeUploads(s, q.pushResumed)
+	for _, want := range s.pending {
+		if got := q.pop(); got.path != want.Path || got.coredumpID != want.CoredumpID {
+			t.Errorf("pop = %+v, want %+v", got, want)
+		}
+	}
+
+	// 上传时把 coredump ID 传给 store，记录在续传状态中
+	tmpl, err := store.ParseKeyTemplate("{filename}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upload(s, tmpl, "/corefile/core.app.1", "k", "dump-1", 0); err != nil || s.coredumpID != "dump-1" {
+		t.Errorf("upload: coredump id %q, %v", s.coredumpID, err)
+	}
+}
+
+func TestAbortStaleUploads(t *testing.T) {
+	s := &resumingStore{}
+	abortStaleUploads(s, 72*time.Hour)
+	// abortAfterHours: 0 不清理
+	abortStaleUploads(s, 0)
+	if s.aborts != 1 {
+		t.Errorf("AbortStaleUploads called %d times, want 1", s.aborts)
+	}
+}
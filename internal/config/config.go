@@ -1,9 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -25,29 +28,153 @@ type Config struct {
 		S3Endpoint                string `yaml:"S3Endpoint"`
 		CFSMountPath              string `yaml:"CFSMountPath"`
 		StoreDir                  string `yaml:"StoreDir"`
+		KeyTemplate               string `yaml:"keyTemplate" env-default:"{filename}"` // StoreDir 下的对象 key 模板，变量见 store.KeyTemplate
+		IfNoneMatch               bool   `yaml:"ifNoneMatch"`                          // 不覆盖已存在的对象（S3 If-None-Match: *；CFS 目标文件存在时不写入）
 		PresignedURLExpireSeconds int    `yaml:"PresignedURLExpireSeconds"`
 		DeleteLocalCorefile       bool   `yaml:"deleteLocalCorefile"`
+		PartSize                  string `yaml:"partSize" env-default:"10Mi"` // S3 分片大小，不小于 5Mi
+		Concurrency               int    `yaml:"concurrency" env-default:"3"` // S3 同时上传的分片数
+		MaxBandwidth              string `yaml:"maxBandwidth"`                // 本节点所有上传共享的带宽上限（字节/秒），如 50Mi，为空表示不限制
+
+		Priority UploadPriority `yaml:"priority"`
+		Resume   UploadResume   `yaml:"resume"`
 	} `yaml:"StorageConfig"`
 	Gc          bool   `yaml:"gc" env-default:"false"`
 	GcType      string `yaml:"gc_type" env-default:"rm"`
 	CorefileDir string `yaml:"CorefileDir"`
+	// StateDir 本地状态目录（hostPath），用于保存 CoreSight outbox 等需要跨重启保留的数据
+	// 不能位于 CorefileDir 下，否则会被 watcher 当作 core 文件处理
+	StateDir string `yaml:"StateDir" env:"COREDOG_STATE_DIR"`
+
+	// Cluster 集群标识，多个集群共用存储、CoreSight 和通知渠道时用于区分 core dump 的来源
+	Cluster struct {
+		Name string `yaml:"name" env:"CLUSTER_NAME"`
+		// ID 为空时使用 kube-system namespace 的 UID（需要 watcher.kubeLookup）
+		ID string `yaml:"id" env:"CLUSTER_ID"`
+		// StoragePrefix 为 true 时 core 文件存储在 StoreDir/<集群名称，未设置时为 ID>/ 下
+		// 未设置时，只要配置了 name 或 id 就开启，避免多个集群在共用的 bucket 中写入同一目录
+		StoragePrefix *bool `yaml:"storagePrefix"`
+	} `yaml:"Cluster"`
+
+	// Retention 已上传 core 文件的保留策略，由 `coredog gc` 执行（chart 中的 CronJob）
+	Retention RetentionConfig `yaml:"Retention"`
+
+	// Quota 按 namespace/工作负载限制上传的 core 大小和频率，超出时只上报元数据，不上传 core 文件
+	Quota QuotaConfig `yaml:"Quota"`
+
+	// Metrics agent 指标的监听地址（如 :9102），为空时不监听；指标以 expvar JSON 格式在 /debug/vars 提供
+	Metrics struct {
+		Addr string `yaml:"addr" env:"METRICS_ADDR"`
+	} `yaml:"Metrics"`
 
 	// Notice configuration (merged from controller)
-	NoticeChannel []struct {
-		Chan       string `yaml:"chan"`
-		Webhookurl string `yaml:"webhookurl"`
-		Keyword    string `yaml:"keyword"`
-	} `yaml:"NoticeChannel"`
+	NoticeChannel []NoticeChannel `yaml:"NoticeChannel"`
+	NoticeRoutes  []NoticeRoute   `yaml:"NoticeRoutes"`
+
+	// PodNotify 允许工作负载通过 Pod annotation 指定通知渠道
+	// coredog.io/notify-channel 中的渠道必须在 allowedChannels 中，否则回退到全局渠道
+	PodNotify struct {
+		Enabled         bool     `yaml:"enabled"`
+		AllowedChannels []string `yaml:"allowedChannels"`
+	} `yaml:"PodNotify"`
 	MessageTemplate string            `yaml:"messageTemplate"`
 	MessageLabels   map[string]string `yaml:"messageLabels"`
 
+	// KubeEvents 在崩溃的 Pod 上记录 Kubernetes Event（reason CoreDumped），需要 watcher.kubeLookup
+	KubeEvents struct {
+		Enabled *bool `yaml:"enabled"` // 未设置时开启
+	} `yaml:"KubeEvents"`
+
+	// PodCache 本节点 Pod 的 informer 缓存，需要 watcher.kubeLookup 和 NODE_NAME 环境变量
+	PodCache struct {
+		// TombstoneTTLSeconds Pod 删除后在缓存中保留的时间，使崩溃后立即被删除的 Pod 产生的 core 文件仍能解析
+		TombstoneTTLSeconds int `yaml:"tombstoneTTLSeconds" env-default:"600"`
+	} `yaml:"PodCache"`
+
+	// DiskGuard 监控 CorefileDir 所在文件系统的空闲空间和 inode，避免大量 core 在上传完成前写满节点磁盘
+	DiskGuard DiskGuardConfig `yaml:"DiskGuard"`
+
+	// DirGC 定期删除本节点上已不存在的 Pod 的 core 目录（CorefileDir/<namespace>/<pod>/...）并移除其 inotify 监听
+	// 依赖 Pod 缓存（watcher.kubeLookup）；仍有文件的目录不会被删除
+	DirGC struct {
+		Enabled         *bool `yaml:"enabled"` // 未设置时开启
+		IntervalSeconds int   `yaml:"intervalSeconds" env-default:"3600"`
+		// MinAgeSeconds 目录最近一次变化后至少经过的时间，避免删除刚创建、Pod 缓存中尚未出现的 Pod 的目录
+		MinAgeSeconds int `yaml:"minAgeSeconds" env-default:"3600"`
+	} `yaml:"DirGC"`
+
+	// CRI 通过 core-helper 崩溃记录中的容器 ID → CRI 运行时解析 Pod，不依赖 core 文件的目录结构
+	// 需要挂载运行时 socket，宿主机 /proc 用于在运行中的容器文件系统中匹配可执行文件；失败时退回到按路径解析
+	CRI struct {
+		Enabled  bool   `yaml:"enabled" env:"CRI_ENABLED"`
+		Endpoint string `yaml:"endpoint" env:"CRI_ENDPOINT" env-default:"unix:///run/containerd/containerd.sock"` // CRI-O: unix:///var/run/crio/crio.sock
+		ProcRoot string `yaml:"procRoot" env:"PROC_ROOT" env-default:"/host/proc"`
+	} `yaml:"CRI"`
+
+	// PodMetadata 事件和自定义处理器中携带的 Pod 元数据
+	PodMetadata struct {
+		// Annotations 需要携带的 Pod annotation，以 / 结尾表示按前缀匹配；labels 总是全部携带
+		Annotations []string `yaml:"annotations" env-default:"coredog.io/"`
+	} `yaml:"PodMetadata"`
+
+	// CoreDumpResource 为每个收集到的 core dump 创建 CoreDump 自定义资源（coredog.io/v1alpha1），需要 watcher.kubeLookup 并先安装 CRD
+	CoreDumpResource struct {
+		Enabled bool `yaml:"enabled" env-default:"false"`
+		// OwnerReference 为 true 时 CoreDump 的 owner 设为崩溃的 Pod，Pod 删除后 CoreDump 会被垃圾回收
+		OwnerReference *bool `yaml:"ownerReference"` // 未设置时为 true
+	} `yaml:"CoreDumpResource"`
+
 	// CoreSight integration configuration
+	// natsUrl 按 scheme 选择传输方式：nats:// 使用 NATS/JetStream，http(s):// 使用 HTTP API
 	CoreSight struct {
-		Enabled bool   `yaml:"enabled" env-default:"false"`
-		NatsURL string `yaml:"natsUrl" env:"CORESIGHT_NATS_URL"`
-		Token   string `yaml:"token" env:"CORESIGHT_TOKEN"`
+		Enabled      bool   `yaml:"enabled" env-default:"false"`
+		NatsURL      string `yaml:"natsUrl" env:"CORESIGHT_NATS_URL"`
+		Token        string `yaml:"token" env:"CORESIGHT_TOKEN"`
+		Subject      string `yaml:"subject" env:"CORESIGHT_NATS_SUBJECT" env-default:"coredog.events"`
+		NKeySeed     string `yaml:"nkeySeed" env:"CORESIGHT_NATS_NKEY_SEED"`
+		NKeySeedFile string `yaml:"nkeySeedFile" env:"CORESIGHT_NATS_NKEY_SEED_FILE"`
+		JetStream    *bool  `yaml:"jetstream"` // 未设置时开启，环境变量 CORESIGHT_NATS_JETSTREAM 优先，见 load
+
+		// 以下字段仅 http(s):// 时使用
+		// httpMode: legacy（默认，兼容旧版 CoreSight）、structured、binary、batch
+		HTTPMode      string `yaml:"httpMode" env:"CORESIGHT_HTTP_MODE" env-default:"legacy"`
+		SigningSecret string `yaml:"signingSecret" env:"CORESIGHT_SIGNING_SECRET"` // HMAC-SHA256 请求签名密钥
+		BatchSize     int    `yaml:"batchSize" env-default:"20"`                   // batch 模式单个请求的事件数上限
+		BatchLingerMs int    `yaml:"batchLingerMs" env-default:"1000"`             // batch 模式实时事件攒批的最长等待时间
+
+		Outbox OutboxConfig `yaml:"outbox"`
 	} `yaml:"CoreSight"`
 
+	// Kafka 事件 sink，与 CoreSight 相互独立，可同时启用
+	// 消息体为事件 data（与 CoreSight 相同的 JSON），CloudEvents 属性放在 ce_* 消息头中
+	Kafka struct {
+		Enabled    bool     `yaml:"enabled"`
+		Brokers    []string `yaml:"brokers" env:"KAFKA_BROKERS" env-separator:","`
+		Topic      string   `yaml:"topic" env:"KAFKA_TOPIC" env-default:"coredog.events"`
+		Key        string   `yaml:"key" env-default:"{coredump_id}"` // 消息 key 模板，{字段} 取自事件 data
+		ClientID   string   `yaml:"clientId" env-default:"coredog-agent"`
+		Version    string   `yaml:"version" env-default:"2.1.0"` // Kafka 协议版本
+		Idempotent *bool    `yaml:"idempotent"`                  // 幂等生产者，未设置时开启
+		// EventTypes 发布的事件类型，为空时只发布 coredog.coredump.uploaded
+		EventTypes    []string     `yaml:"eventTypes"`
+		BatchLingerMs int          `yaml:"batchLingerMs" env-default:"1000"` // 实时事件攒批的最长等待时间
+		Outbox        OutboxConfig `yaml:"outbox"`
+
+		SASL struct {
+			Mechanism string `yaml:"mechanism"` // PLAIN、SCRAM-SHA-256、SCRAM-SHA-512，为空表示不启用
+			Username  string `yaml:"username" env:"KAFKA_SASL_USERNAME"`
+			Password  string `yaml:"password" env:"KAFKA_SASL_PASSWORD"`
+		} `yaml:"sasl"`
+
+		TLS struct {
+			Enabled            bool   `yaml:"enabled"`
+			CAFile             string `yaml:"caFile"`
+			CertFile           string `yaml:"certFile"`
+			KeyFile            string `yaml:"keyFile"`
+			InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+		} `yaml:"tls"`
+	} `yaml:"Kafka"`
+
 	// CustomHandler configuration for executing custom scripts
 	CustomHandler struct {
 		Enabled           bool   `yaml:"enabled"`
@@ -58,19 +185,263 @@ type Config struct {
 	} `yaml:"CustomHandler"`
 }
 
+// OutboxConfig 事件发送前先写入 StateDir 下的本地 outbox，发送失败后按指数退避重试，
+// 可通过 `coredog events replay` 重新发送某个时间范围内的事件
+type OutboxConfig struct {
+	Enabled        *bool `yaml:"enabled"`                          // 未设置时开启
+	RetentionHours int   `yaml:"retentionHours" env-default:"168"` // 已投递事件保留时间
+}
+
+// IsEnabled 是否启用 outbox，未设置时开启
+func (o OutboxConfig) IsEnabled() bool {
+	return valueOr(o.Enabled, true)
+}
+
+// UploadPriority 上传调度优先级：关键 namespace 的 core 最先上传，其次是不超过 smallCoreSize 的 core，最后是其余的 core，
+// 同一优先级内按到达顺序；磁盘压力下改为优先上传最旧的 core（见 DiskGuard）
+type UploadPriority struct {
+	CriticalNamespaces []string `yaml:"criticalNamespaces"`              // 支持通配符（*、?）
+	SmallCoreSize      string   `yaml:"smallCoreSize" env-default:"1Gi"` // 为 0 时不按大小区分
+}
+
+// UploadResume S3 分片上传的断点续传：上传 ID 和已完成分片的 ETag 保存在 StateDir/uploads/ 下，
+// 上传失败或 agent 重启后跳过已上传的分片继续上传；超过 abortAfterHours 仍未完成的分片上传由 coredog gc 放弃
+type UploadResume struct {
+	Enabled         *bool `yaml:"enabled"`         // 未设置时开启
+	Retries         *int  `yaml:"retries"`         // 上传失败后立即续传的次数，未设置时为 2
+	AbortAfterHours *int  `yaml:"abortAfterHours"` // 未设置时为 72，为 0 时不清理未完成的分片上传
+}
+
+// IsEnabled 是否开启断点续传，默认开启
+func (r UploadResume) IsEnabled() bool {
+	return valueOr(r.Enabled, true)
+}
+
+// RetryCount 上传失败后立即续传的次数
+func (r UploadResume) RetryCount() int {
+	return valueOr(r.Retries, 2)
+}
+
+// AbortAfter 放弃未完成的分片上传前等待的时间，为 0 时不清理
+func (r UploadResume) AbortAfter() time.Duration {
+	return time.Duration(valueOr(r.AbortAfterHours, 72)) * time.Hour
+}
+
+// DiskGuardConfig 磁盘保护配置，阈值为空闲量占总量的百分比
+// 阈值未设置时使用默认值（pressure 15%/10% inode，critical 5%/3% inode），显式设置为 0 表示不检查该阈值
+// 低于 pressure 阈值时优先上传最旧的 core 并发送告警；低于 critical 阈值时按 action 清理低优先级的 core：
+// 先清理已上传但保留在本地的 core（从旧到新），再清理等待上传的 core（从新到旧），正在处理的 core 不会被清理
+// 降级需要空闲量回到阈值加 hysteresisPercent 以上，避免在阈值附近反复告警
+type DiskGuardConfig struct {
+	Enabled                   *bool    `yaml:"enabled"` // 未设置时开启
+	IntervalSeconds           int      `yaml:"intervalSeconds" env-default:"10"`
+	PressureFreePercent       *float64 `yaml:"pressureFreePercent"`
+	CriticalFreePercent       *float64 `yaml:"criticalFreePercent"`
+	PressureFreeInodesPercent *float64 `yaml:"pressureFreeInodesPercent"`
+	CriticalFreeInodesPercent *float64 `yaml:"criticalFreeInodesPercent"`
+	HysteresisPercent         *float64 `yaml:"hysteresisPercent"`         // 未设置时为 2
+	Action                    string   `yaml:"action" env-default:"none"` // truncate、rm 或 none（只告警）
+}
+
+// IsEnabled 是否开启磁盘保护，默认开启
+func (c DiskGuardConfig) IsEnabled() bool {
+	return valueOr(c.Enabled, true)
+}
+
+// PressureFree 空闲空间的 pressure 阈值（%），0 表示不检查
+func (c DiskGuardConfig) PressureFree() float64 {
+	return valueOr(c.PressureFreePercent, 15)
+}
+
+// CriticalFree 空闲空间的 critical 阈值（%），0 表示不检查
+func (c DiskGuardConfig) CriticalFree() float64 {
+	return valueOr(c.CriticalFreePercent, 5)
+}
+
+// PressureFreeInodes 空闲 inode 的 pressure 阈值（%），0 表示不检查
+func (c DiskGuardConfig) PressureFreeInodes() float64 {
+	return valueOr(c.PressureFreeInodesPercent, 10)
+}
+
+// CriticalFreeInodes 空闲 inode 的 critical 阈值（%），0 表示不检查
+func (c DiskGuardConfig) CriticalFreeInodes() float64 {
+	return valueOr(c.CriticalFreeInodesPercent, 3)
+}
+
+// Hysteresis 降级所需的额外空闲量（%）
+func (c DiskGuardConfig) Hysteresis() float64 {
+	return valueOr(c.HysteresisPercent, 2)
+}
+
+// RetentionConfig core 文件保留策略
+// 对象的 namespace 和工作负载从 key 中解析（StorageConfig.keyTemplate 需包含 {namespace}、{workload} 或 {pod}），
+// 无法解析的对象只受 default 中的 maxAgeDays 和 maxTotalBytes 约束
+type RetentionConfig struct {
+	LegalHoldTag string               `yaml:"legalHoldTag" env-default:"legal-hold"` // 带此标签的对象不会被删除（CFS 为 .<文件名>.<tag> 标记文件）
+	Default      RetentionPolicy      `yaml:"default"`                               // 全局策略，maxTotalBytes 限制 StoreDir 的总大小
+	Namespaces   []NamespaceRetention `yaml:"namespaces"`                            // 按 namespace 覆盖，按顺序匹配第一条
+}
+
+// RetentionPolicy 保留策略，0 表示不限制
+type RetentionPolicy struct {
+	MaxAgeDays     int   `yaml:"maxAgeDays"`     // 超过天数的对象被删除
+	MaxPerWorkload int   `yaml:"maxPerWorkload"` // 每个工作负载只保留最新的 N 个
+	MaxTotalBytes  int64 `yaml:"maxTotalBytes"`  // 总大小上限，超出时从最旧的开始删除
+}
+
+// NamespaceRetention namespace 级别的保留策略
+// maxAgeDays 和 maxPerWorkload 为 0 时继承 default；maxTotalBytes 只限制该 namespace 的总大小
+type NamespaceRetention struct {
+	Namespace      string `yaml:"namespace"` // 支持通配符（*、?）
+	MaxAgeDays     int    `yaml:"maxAgeDays"`
+	MaxPerWorkload int    `yaml:"maxPerWorkload"`
+	MaxTotalBytes  int64  `yaml:"maxTotalBytes"`
+}
+
+// QuotaConfig core 上传配额
+// 大小支持 Kubernetes 数量格式（如 512Mi、8Gi）或字节数，为空或 0 表示不限制
+type QuotaConfig struct {
+	Enabled  bool        `yaml:"enabled"`
+	Default  QuotaPolicy `yaml:"default"`
+	Policies []QuotaRule `yaml:"policies"` // 按 namespace/工作负载覆盖，按顺序匹配第一条，未设置的字段继承 default
+	// AllowAnnotationIncrease 为 false 时 Pod annotation 只能收紧配置中的限制，不能放宽
+	AllowAnnotationIncrease bool `yaml:"allowAnnotationIncrease"`
+}
+
+// QuotaPolicy 配额策略
+type QuotaPolicy struct {
+	MaxCoreSize     string `yaml:"maxCoreSize"`     // 单个 core 的大小上限，超出时只上报元数据
+	MaxCoresPerHour int    `yaml:"maxCoresPerHour"` // 最近一小时内上传的 core 数上限
+	MaxBytesPerDay  string `yaml:"maxBytesPerDay"`  // 最近 24 小时内上传的总大小上限
+}
+
+// QuotaRule namespace/工作负载级别的配额策略，namespace 和 workload 支持通配符（*、?），为空表示匹配全部
+type QuotaRule struct {
+	Namespace       string `yaml:"namespace"`
+	Workload        string `yaml:"workload"`
+	MaxCoreSize     string `yaml:"maxCoreSize"`
+	MaxCoresPerHour int    `yaml:"maxCoresPerHour"`
+	MaxBytesPerDay  string `yaml:"maxBytesPerDay"`
+}
+
+// NoticeChannel 通知渠道配置
+// chan 取值: wechat, slack, teams, webhook, alertmanager
+type NoticeChannel struct {
+	Name       string `yaml:"name"` // 渠道名称，供 NoticeRoutes 引用
+	Chan       string `yaml:"chan"`
+	Webhookurl string `yaml:"webhookurl"`
+	Keyword    string `yaml:"keyword"`
+
+	// 以下字段仅 chan=webhook 时使用
+	Method         string            `yaml:"method"`         // HTTP 方法，默认 POST
+	Headers        map[string]string `yaml:"headers"`        // 自定义请求头
+	HeadersFromEnv map[string]string `yaml:"headersFromEnv"` // 请求头 -> 环境变量名，用于从 Secret 注入鉴权信息
+	BodyTemplate   string            `yaml:"bodyTemplate"`   // JSON 请求体模板，{content} 替换为转义后的消息内容
+
+	// 以下字段仅 chan=alertmanager 时使用（headers/headersFromEnv 同样生效）
+	ResolveAfterSeconds int    `yaml:"resolveAfterSeconds"` // 告警自动恢复时间（endsAt），默认 3600
+	GeneratorURL        string `yaml:"generatorURL"`        // 告警来源链接模板（如 Grafana 面板），支持 {cluster}、{namespace}、{pod}、{workload}、{node}，为空时不设置
+
+	// RateLimit 渠道级令牌桶限流，超出后在每个 digest 周期内汇总为一条消息
+	RateLimit NoticeRateLimit `yaml:"rateLimit"`
+}
+
+// NoticeRateLimit 通知限流配置，perMinute 为 0 表示不限流
+type NoticeRateLimit struct {
+	PerMinute             int `yaml:"perMinute"`             // 每分钟允许发送的消息数
+	Burst                 int `yaml:"burst"`                 // 突发容量，默认等于 perMinute
+	DigestIntervalSeconds int `yaml:"digestIntervalSeconds"` // 汇总消息发送周期，默认 60
+}
+
+// NoticeRoute 通知路由规则
+// 规则按顺序匹配，命中后发送到 channels 指定的渠道；continue=true 时继续匹配后续规则，
+// mute=true 时丢弃该告警。没有任何规则命中时回退到所有渠道（按 keyword 过滤）
+type NoticeRoute struct {
+	Match    NoticeRouteMatch `yaml:"match"`
+	Channels []string         `yaml:"channels"`
+	Continue bool             `yaml:"continue"`
+	Mute     bool             `yaml:"mute"`
+}
+
+// NoticeRouteMatch 路由匹配条件，所有非空条件都满足才算命中
+// 列表中的模式满足任意一个即可；模式默认为通配符（*、?），以 ~ 开头时按正则匹配
+type NoticeRouteMatch struct {
+	Namespaces  []string          `yaml:"namespaces"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+	Containers  []string          `yaml:"containers"`
+	Executables []string          `yaml:"executables"`
+	Signals     []string          `yaml:"signals"`
+	Images      []string          `yaml:"images"`
+}
+
 func Get() *Config {
 	onceCfg.Do(func() {
-		cfg = &Config{}
 		cfgPath := os.Getenv("CONFIG_PATH")
 		if cfgPath == "" {
 			cfgPath = defaultCfgPath
 		}
-		if err := cleanenv.ReadConfig(cfgPath, cfg); err != nil {
+		var err error
+		if cfg, err = load(cfgPath); err != nil {
 			log.Fatal(err)
 		}
-		if cfg.CorefileDir == "" {
-			cfg.CorefileDir = "/corefile"
-		}
 	})
 	return cfg
 }
+
+// load 读取配置文件，环境变量覆盖文件中的值
+func load(path string) (*Config, error) {
+	c := &Config{}
+	if err := cleanenv.ReadConfig(path, c); err != nil {
+		return nil, err
+	}
+	if c.CorefileDir == "" {
+		c.CorefileDir = "/corefile"
+	}
+	if c.StateDir == "" {
+		c.StateDir = "/var/lib/coredog"
+	}
+	// cleanenv 不支持指针字段，环境变量在这里处理，与其他字段一样覆盖 YAML
+	if v := os.Getenv("CORESIGHT_NATS_JETSTREAM"); v != "" {
+		jetstream, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CORESIGHT_NATS_JETSTREAM %q: %w", v, err)
+		}
+		c.CoreSight.JetStream = &jetstream
+	}
+	return c, nil
+}
+
+// 默认开启的开关，以及默认值不为 0 但 0 有特殊含义的数值使用指针，未设置时为 nil，由访问方法返回默认值
+// 这些字段不能使用 env-default：cleanenv 会用默认值覆盖 YAML 中显式设置的 false 和 0
+func valueOr[T any](p *T, def T) T {
+	if p == nil {
+		return def
+	}
+	return *p
+}
+
+// KubeEventsEnabled 是否在崩溃的 Pod 上记录 Kubernetes Event，默认开启
+func (c *Config) KubeEventsEnabled() bool {
+	return valueOr(c.KubeEvents.Enabled, true)
+}
+
+// DirGCEnabled 是否清理已不在本节点上的 Pod 的 core 目录，默认开启
+func (c *Config) DirGCEnabled() bool {
+	return valueOr(c.DirGC.Enabled, true)
+}
+
+// CoreSightJetStream NATS 传输是否使用 JetStream，未设置时开启
+func (c *Config) CoreSightJetStream() bool {
+	return valueOr(c.CoreSight.JetStream, true)
+}
+
+// KafkaIdempotent 是否使用幂等生产者，未设置时开启
+func (c *Config) KafkaIdempotent() bool {
+	return valueOr(c.Kafka.Idempotent, true)
+}
+
+// CoreDumpOwnerReference CoreDump 是否以崩溃的 Pod 为 owner，未设置时为 true
+func (c *Config) CoreDumpOwnerReference() bool {
+	return valueOr(c.CoreDumpResource.OwnerReference, true)
+}
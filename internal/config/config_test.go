@@ -0,0 +1,119 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfig 把 YAML 写入临时文件并加载
+func writeConfig(t *testing.T, content string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "coredog.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c := writeConfig(t, "CorefileDir: /cores\n")
+	if !c.KubeEventsEnabled() {
+		t.Error("KubeEvents should be enabled by default")
+	}
+	if !c.DirGCEnabled() {
+		t.Error("DirGC should be enabled by default")
+	}
+	if g := c.DiskGuard; !g.IsEnabled() || g.PressureFree() != 15 || g.CriticalFree() != 5 ||
+		g.PressureFreeInodes() != 10 || g.CriticalFreeInodes() != 3 || g.Hysteresis() != 2 || g.Action != "none" {
+		t.Errorf("unexpected DiskGuard defaults: %+v", g)
+	}
+	if r := c.StorageConfig.Resume; !r.IsEnabled() || r.RetryCount() != 2 || r.AbortAfter() != 72*time.Hour {
+		t.Errorf("unexpected resume defaults: %+v", r)
+	}
+	if !c.CoreSight.Outbox.IsEnabled() || !c.Kafka.Outbox.IsEnabled() {
+		t.Error("outbox should be enabled by default")
+	}
+	if !c.CoreDumpOwnerReference() {
+		t.Error("CoreDumpResource.ownerReference should default to true")
+	}
+	if !c.KafkaIdempotent() {
+		t.Error("Kafka.idempotent should default to true")
+	}
+	if !c.CoreSightJetStream() {
+		t.Error("CoreSight.jetstream should default to true")
+	}
+}
+
+// 显式设置为 false 或 0 的值不能被默认值覆盖
+func TestLoadDisabled(t *testing.T) {
+	c := writeConfig(t, `
+KubeEvents:
+  enabled: false
+DirGC:
+  enabled: false
+DiskGuard:
+  enabled: false
+  criticalFreePercent: 0
+  criticalFreeInodesPercent: 0
+  hysteresisPercent: 0
+StorageConfig:
+  resume:
+    enabled: false
+    retries: 0
+    abortAfterHours: 0
+CoreSight:
+  jetstream: false
+  outbox:
+    enabled: false
+Kafka:
+  idempotent: false
+  outbox:
+    enabled: false
+CoreDumpResource:
+  ownerReference: false
+`)
+	if c.KubeEventsEnabled() {
+		t.Error("KubeEvents.enabled: false was ignored")
+	}
+	if c.DirGCEnabled() {
+		t.Error("DirGC.enabled: false was ignored")
+	}
+	if g := c.DiskGuard; g.IsEnabled() || g.CriticalFree() != 0 || g.CriticalFreeInodes() != 0 || g.Hysteresis() != 0 || g.PressureFree() != 15 {
+		t.Errorf("DiskGuard zero values were replaced by defaults: enabled %v, critical %v/%v, hysteresis %v",
+			g.IsEnabled(), g.CriticalFree(), g.CriticalFreeInodes(), g.Hysteresis())
+	}
+	if r := c.StorageConfig.Resume; r.IsEnabled() || r.RetryCount() != 0 || r.AbortAfter() != 0 {
+		t.Errorf("resume zero values were replaced by defaults: enabled %v, retries %d, abort after %s",
+			r.IsEnabled(), r.RetryCount(), r.AbortAfter())
+	}
+	if c.CoreSight.Outbox.IsEnabled() || c.Kafka.Outbox.IsEnabled() {
+		t.Error("outbox.enabled: false was ignored")
+	}
+	if c.CoreDumpOwnerReference() {
+		t.Error("CoreDumpResource.ownerReference: false was ignored")
+	}
+	if c.KafkaIdempotent() {
+		t.Error("Kafka.idempotent: false was ignored")
+	}
+	if c.CoreSightJetStream() {
+		t.Error("CoreSight.jetstream: false was ignored")
+	}
+}
+
+// 环境变量覆盖 YAML 中的 jetstream
+func TestLoadJetStreamEnv(t *testing.T) {
+	t.Setenv("CORESIGHT_NATS_JETSTREAM", "false")
+	if c := writeConfig(t, "CorefileDir: /cores\n"); c.CoreSightJetStream() {
+		t.Error("CORESIGHT_NATS_JETSTREAM=false was ignored")
+	}
+	t.Setenv("CORESIGHT_NATS_JETSTREAM", "true")
+	if c := writeConfig(t, "CoreSight:\n  jetstream: false\n"); !c.CoreSightJetStream() {
+		t.Error("CORESIGHT_NATS_JETSTREAM should override YAML")
+	}
+}
@@ -0,0 +1,212 @@
+package coreparser
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ntFile       = 0x46494c45 // NT_FILE：进程映射的文件列表
+	ntGNUBuildID = 3          // NT_GNU_BUILD_ID
+
+	// 读取可执行文件首个映射页时的上限，ELF 头、程序头和 build-id note 通常都在第一页内
+	maxHeaderRead = 64 * 1024
+)
+
+// fileMapping NT_FILE 中的一条映射
+type fileMapping struct {
+	Start, End uint64
+	Offset     uint64 // 文件内偏移（字节）
+	Path       string
+}
+
+// parseNTFile 解析 NT_FILE note
+// 格式：count、page_size，之后是 count 组 (start, end, file_ofs)，最后是 count 个以 NUL 结尾的文件名
+// 字段长度与 core 的位数一致，file_ofs 以 page_size 为单位
+func parseNTFile(notes []elfNote, order binary.ByteOrder, class elf.Class) []fileMapping {
+	word := 8
+	if class == elf.ELFCLASS32 {
+		word = 4
+	}
+	readWord := func(b []byte) uint64 {
+		if word == 4 {
+			return uint64(order.Uint32(b))
+		}
+		return order.Uint64(b)
+	}
+
+	for _, n := range notes {
+		if n.Name != "CORE" || n.Type != ntFile || len(n.Desc) < 2*word {
+			continue
+		}
+		// count 来自 core 文件，先与实际长度比较再参与乘法，避免溢出
+		entries := n.Desc[2*word:]
+		rawCount := readWord(n.Desc)
+		if rawCount == 0 || rawCount > uint64(len(entries)/(3*word)) {
+			return nil
+		}
+		count := int(rawCount)
+		pageSize := readWord(n.Desc[word:])
+		names := bytes.Split(entries[count*3*word:], []byte{0})
+		if len(names) < count {
+			return nil
+		}
+
+		mappings := make([]fileMapping, 0, count)
+		for i := 0; i < count; i++ {
+			e := entries[i*3*word:]
+			mappings = append(mappings, fileMapping{
+				Start:  readWord(e),
+				End:    readWord(e[word:]),
+				Offset: readWord(e[2*word:]) * pageSize,
+				Path:   string(names[i]),
+			})
+		}
+		return mappings
+	}
+	return nil
+}
+
+// findExecutable 在映射中找到可执行文件的路径
+// 优先使用 psargs 的第一个参数（绝对路径且确实被映射），否则使用文件名以进程名（comm）开头的映射
+func findExecutable(mappings []fileMapping, psarg0, comm string) string {
+	if strings.HasPrefix(psarg0, "/") {
+		for _, m := range mappings {
+			if m.Path == psarg0 {
+				return m.Path
+			}
+		}
+	}
+	if comm == "" {
+		return ""
+	}
+	for _, m := range mappings {
+		if m.Offset == 0 && strings.HasPrefix(filepath.Base(m.Path), comm) && !strings.Contains(filepath.Base(m.Path), ".so") {
+			return m.Path
+		}
+	}
+	return ""
+}
+
+// gnuBuildID 从 note 中找到 GNU build-id
+func gnuBuildID(notes []elfNote) string {
+	for _, n := range notes {
+		if n.Name == "GNU" && n.Type == ntGNUBuildID && len(n.Desc) > 0 {
+			return hex.EncodeToString(n.Desc)
+		}
+	}
+	return ""
+}
+
+// buildIDFromHeader 从可执行文件的首个映射页解析 build-id
+// core 默认会转储文件映射的第一页（coredump_filter bit 4），其中包含 ELF 头、程序头以及 build-id note
+func buildIDFromHeader(page []byte) string {
+	if len(page) < 52 || !bytes.HasPrefix(page, []byte(elf.ELFMAG)) {
+		return ""
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if elf.Data(page[elf.EI_DATA]) == elf.ELFDATA2MSB {
+		order = binary.BigEndian
+	}
+
+	var phoff, phentsize, phnum uint64
+	is64 := elf.Class(page[elf.EI_CLASS]) == elf.ELFCLASS64
+	minPhentsize := uint64(32) // Elf32_Phdr
+	if is64 {
+		minPhentsize = 56 // Elf64_Phdr
+		if len(page) < 64 {
+			return ""
+		}
+		phoff = order.Uint64(page[32:40])
+		phentsize = uint64(order.Uint16(page[54:56]))
+		phnum = uint64(order.Uint16(page[56:58]))
+	} else {
+		phoff = uint64(order.Uint32(page[28:32]))
+		phentsize = uint64(order.Uint16(page[42:44]))
+		phnum = uint64(order.Uint16(page[44:46]))
+	}
+
+	// 头中的偏移和长度都来自 core 文件，比较时写成 x > size || n > size-x 的形式，避免 uint64 相加溢出
+	size := uint64(len(page))
+	if phentsize < minPhentsize || phoff > size {
+		return ""
+	}
+	for i := uint64(0); i < phnum; i++ {
+		// i < 65536 且 phentsize < 65536，乘积不会溢出
+		off := i * phentsize
+		if off > size-phoff || phentsize > size-phoff-off {
+			break
+		}
+		off += phoff
+		ph := page[off : off+phentsize]
+		if elf.ProgType(order.Uint32(ph[0:4])) != elf.PT_NOTE {
+			continue
+		}
+		var noteOff, noteSize uint64
+		if is64 {
+			noteOff, noteSize = order.Uint64(ph[8:16]), order.Uint64(ph[32:40])
+		} else {
+			noteOff, noteSize = uint64(order.Uint32(ph[4:8])), uint64(order.Uint32(ph[16:20]))
+		}
+		if noteOff > size || noteSize > size-noteOff {
+			continue
+		}
+		if id := gnuBuildID(parseNotes(page[noteOff:noteOff+noteSize], order)); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// buildIDFromCore 读取 core 中可执行文件首个映射页，解析其 build-id
+func buildIDFromCore(f *elf.File, mappings []fileMapping, executable string) string {
+	for _, m := range mappings {
+		if m.Path != executable || m.Offset != 0 {
+			continue
+		}
+		for _, prog := range f.Progs {
+			if prog.Type != elf.PT_LOAD || prog.Vaddr != m.Start || prog.Filesz == 0 {
+				continue
+			}
+			size := prog.Filesz
+			if size > maxHeaderRead {
+				size = maxHeaderRead
+			}
+			page := make([]byte, size)
+			if _, err := io.ReadFull(prog.Open(), page); err != nil {
+				return ""
+			}
+			return buildIDFromHeader(page)
+		}
+	}
+	return ""
+}
+
+// BuildIDOfFile 读取 ELF 文件的 GNU build-id，用于与 core 中记录的 build-id 比较
+func BuildIDOfFile(path string) (string, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+		data, err := io.ReadAll(prog.Open())
+		if err != nil {
+			return "", err
+		}
+		if id := gnuBuildID(parseNotes(data, f.ByteOrder)); id != "" {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("no GNU build-id in %s", path)
+}
@@ -0,0 +1,226 @@
+package coreparser
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testBuildID = "8f3a1c2b4d5e6f708192a3b4c5d6e7f801234567"
+
+func le64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, v)
+	return b
+}
+
+// writeELF64 生成最小的 ELF64 文件，segments 依次写在程序头之后
+func writeELF64(typ uint16, segments []elfSegment) []byte {
+	const ehdrSize, phdrSize = 64, 56
+	le := binary.LittleEndian
+	var buf bytes.Buffer
+	ident := [16]byte{0x7f, 'E', 'L', 'F', 2, 1, 1}
+	buf.Write(ident[:])
+	binary.Write(&buf, le, typ)                   // e_type
+	binary.Write(&buf, le, uint16(62))            // e_machine = EM_X86_64
+	binary.Write(&buf, le, uint32(1))             // e_version
+	binary.Write(&buf, le, uint64(0))             // e_entry
+	binary.Write(&buf, le, uint64(ehdrSize))      // e_phoff
+	binary.Write(&buf, le, uint64(0))             // e_shoff
+	binary.Write(&buf, le, uint32(0))             // e_flags
+	binary.Write(&buf, le, uint16(ehdrSize))      // e_ehsize
+	binary.Write(&buf, le, uint16(phdrSize))      // e_phentsize
+	binary.Write(&buf, le, uint16(len(segments))) // e_phnum
+	binary.Write(&buf, le, uint16(64))            // e_shentsize
+	binary.Write(&buf, le, uint16(0))             // e_shnum
+	binary.Write(&buf, le, uint16(0))             // e_shstrndx
+
+	offset := uint64(ehdrSize + phdrSize*len(segments))
+	for _, seg := range segments {
+		binary.Write(&buf, le, seg.typ)               // p_type
+		binary.Write(&buf, le, uint32(0))             // p_flags
+		binary.Write(&buf, le, offset)                // p_offset
+		binary.Write(&buf, le, seg.vaddr)             // p_vaddr
+		binary.Write(&buf, le, uint64(0))             // p_paddr
+		binary.Write(&buf, le, uint64(len(seg.data))) // p_filesz
+		binary.Write(&buf, le, uint64(len(seg.data))) // p_memsz
+		binary.Write(&buf, le, uint64(4))             // p_align
+		offset += uint64(len(seg.data))
+	}
+	for _, seg := range segments {
+		buf.Write(seg.data)
+	}
+	return buf.Bytes()
+}
+
+type elfSegment struct {
+	typ   uint32
+	vaddr uint64
+	data  []byte
+}
+
+// buildExecutableHeader 生成带 GNU build-id note 的可执行文件首页
+func buildExecutableHeader(t *testing.T, buildID string) []byte {
+	t.Helper()
+	id, err := hex.DecodeString(buildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeELF64(2, []elfSegment{{typ: 4, data: buildNote("GNU", ntGNUBuildID, id)}})
+}
+
+// buildNTFile 编码 64 位 NT_FILE note，offset 以页为单位
+func buildNTFile(mappings []fileMapping) []byte {
+	var buf bytes.Buffer
+	buf.Write(le64(uint64(len(mappings))))
+	buf.Write(le64(4096))
+	for _, m := range mappings {
+		buf.Write(le64(m.Start))
+		buf.Write(le64(m.End))
+		buf.Write(le64(m.Offset))
+	}
+	for _, m := range mappings {
+		buf.WriteString(m.Path)
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
+
+func TestReadProcessInfoBuildID(t *testing.T) {
+	const base = 0x400000
+	notes := bytes.Join([][]byte{
+		buildNote("CORE", ntPrpsinfo, buildPrpsinfo64(4242, "myapp", "/opt/myapp/bin/myapp --serve")),
+		buildNote("CORE", ntFile, buildNTFile([]fileMapping{
+			{Start: 0x7f0000000000, End: 0x7f0000001000, Offset: 0, Path: "/usr/lib/libc.so.6"},
+			{Start: base, End: base + 0x1000, Offset: 0, Path: "/opt/myapp/bin/myapp"},
+			{Start: base + 0x1000, End: base + 0x2000, Offset: 1, Path: "/opt/myapp/bin/myapp"},
+		})),
+	}, nil)
+	core := writeELF64(4, []elfSegment{
+		{typ: 4, data: notes},
+		{typ: 1, vaddr: base, data: buildExecutableHeader(t, testBuildID)},
+	})
+	path := filepath.Join(t.TempDir(), "core.myapp.4242")
+	if err := os.WriteFile(path, core, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	proc, err := ReadProcessInfo(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if proc.Executable != "/opt/myapp/bin/myapp" || proc.BuildID != testBuildID {
+		t.Errorf("unexpected process info %+v", proc)
+	}
+
+	info := &CoreInfo{}
+	if err := parseELFNotes(path, info); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.BuildID != testBuildID {
+		t.Errorf("expected build-id %s, got %q", testBuildID, info.BuildID)
+	}
+}
+
+func TestFindExecutable(t *testing.T) {
+	mappings := []fileMapping{
+		{Path: "/usr/lib/x86_64-linux-gnu/libmyapp.so.1"},
+		{Path: "/app/myapp-server"},
+	}
+	tests := []struct {
+		name, psarg0, comm, want string
+	}{
+		{"psargs path", "/app/myapp-server", "myapp-server", "/app/myapp-server"},
+		{"relative psargs falls back to comm", "./myapp-server", "myapp-server", "/app/myapp-server"},
+		{"shared library is skipped", "", "libmyapp", ""},
+		{"unknown", "", "other", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findExecutable(mappings, tt.psarg0, tt.comm); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildIDOfFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myapp")
+	if err := os.WriteFile(path, buildExecutableHeader(t, testBuildID), 0755); err != nil {
+		t.Fatal(err)
+	}
+	got, err := BuildIDOfFile(path)
+	if err != nil || got != testBuildID {
+		t.Errorf("got (%q, %v), want %s", got, err, testBuildID)
+	}
+
+	path = filepath.Join(t.TempDir(), "stripped")
+	if err := os.WriteFile(path, writeELF64(2, nil), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BuildIDOfFile(path); err == nil {
+		t.Error("expected error for binary without build-id")
+	}
+}
+
+// TestHostileNoteHeaders core 文件中的数量、偏移和长度可被任意构造，解析时不应 panic
+func TestHostileNoteHeaders(t *testing.T) {
+	ntFileNotes := func(count uint64) []elfNote {
+		desc := append(le64(count), le64(4096)...)
+		desc = append(desc, make([]byte, 3*8)...)
+		return []elfNote{{Name: "CORE", Type: ntFile, Desc: desc}}
+	}
+	for _, count := range []uint64{0, 2, 1 << 61, 1<<63 + 1, ^uint64(0)} {
+		if got := parseNTFile(ntFileNotes(count), binary.LittleEndian, elf.ELFCLASS64); got != nil {
+			t.Errorf("count %#x: expected no mappings, got %+v", count, got)
+		}
+	}
+
+	header := func(phoff uint64, phentsize uint16, noteOff, noteSize uint64) []byte {
+		page := buildExecutableHeader(t, testBuildID)
+		binary.LittleEndian.PutUint64(page[32:40], phoff)
+		binary.LittleEndian.PutUint16(page[54:56], phentsize)
+		binary.LittleEndian.PutUint64(page[64+8:64+16], noteOff)
+		binary.LittleEndian.PutUint64(page[64+32:64+40], noteSize)
+		return page
+	}
+	tests := []struct {
+		name string
+		page []byte
+	}{
+		{"phoff wraps", header(^uint64(0)-10, 56, 120, 36)},
+		{"phoff past end", header(1<<40, 56, 120, 36)},
+		{"phentsize too small", header(64, 1, 120, 36)},
+		{"note offset wraps", header(64, 56, ^uint64(0)-1, 36)},
+		{"note size wraps", header(64, 56, 120, ^uint64(0)-100)},
+		{"note name size too large", append(header(64, 56, 120, 36)[:120], 0xff, 0xff, 0xff, 0xff, 4, 0, 0, 0, 3, 0, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildIDFromHeader(tt.page); got != "" {
+				t.Errorf("expected no build-id, got %q", got)
+			}
+		})
+	}
+}
+
+func FuzzBuildIDFromHeader(f *testing.F) {
+	f.Add(writeELF64(2, []elfSegment{{typ: 4, data: buildNote("GNU", ntGNUBuildID, []byte{1, 2, 3, 4})}}))
+	f.Fuzz(func(t *testing.T, page []byte) {
+		buildIDFromHeader(page)
+	})
+}
+
+func FuzzParseNTFile(f *testing.F) {
+	f.Add(buildNTFile([]fileMapping{{Start: 0x400000, End: 0x401000, Path: "/app/myapp"}}))
+	f.Fuzz(func(t *testing.T, desc []byte) {
+		notes := []elfNote{{Name: "CORE", Type: ntFile, Desc: desc}}
+		parseNTFile(notes, binary.LittleEndian, elf.ELFCLASS64)
+		parseNTFile(notes, binary.LittleEndian, elf.ELFCLASS32)
+		parseNotes(desc, binary.LittleEndian)
+	})
+}
@@ -0,0 +1,224 @@
+package coreparser
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// core 文件 PT_NOTE 段中的 note 类型（见 linux/include/uapi/linux/elf.h）
+const (
+	ntPrstatus = 1
+	ntPrpsinfo = 3
+	ntSiginfo  = 0x53494749
+)
+
+// elfNote 是 PT_NOTE 段中的一条 note 记录
+type elfNote struct {
+	Name string
+	Type uint32
+	Desc []byte
+}
+
+// signalNames Linux 信号编号到名称的映射（x86/arm 通用部分）
+var signalNames = map[int]string{
+	1: "SIGHUP", 2: "SIGINT", 3: "SIGQUIT", 4: "SIGILL", 5: "SIGTRAP",
+	6: "SIGABRT", 7: "SIGBUS", 8: "SIGFPE", 9: "SIGKILL", 10: "SIGUSR1",
+	11: "SIGSEGV", 12: "SIGUSR2", 13: "SIGPIPE", 14: "SIGALRM", 15: "SIGTERM",
+	16: "SIGSTKFLT", 17: "SIGCHLD", 18: "SIGCONT", 19: "SIGSTOP", 20: "SIGTSTP",
+	21: "SIGTTIN", 22: "SIGTTOU", 23: "SIGURG", 24: "SIGXCPU", 25: "SIGXFSZ",
+	26: "SIGVTALRM", 27: "SIGPROF", 28: "SIGWINCH", 29: "SIGIO", 30: "SIGPWR",
+	31: "SIGSYS",
+}
+
+// SignalName 返回信号编号对应的名称，未知信号返回 "SIG<n>"
+func SignalName(signo int) string {
+	if signo <= 0 {
+		return ""
+	}
+	if name, ok := signalNames[signo]; ok {
+		return name
+	}
+	return fmt.Sprintf("SIG%d", signo)
+}
+
+// readCoreNotes 读取 core 文件所有 PT_NOTE 段中的 note
+// 只读取程序头和 note 段，不会遍历整个 core 文件
+func readCoreNotes(f *elf.File) ([]elfNote, error) {
+	if f.Type != elf.ET_CORE {
+		return nil, fmt.Errorf("not a core file: %s", f.Type)
+	}
+
+	var notes []elfNote
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+		data, err := io.ReadAll(prog.Open())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PT_NOTE segment: %w", err)
+		}
+		notes = append(notes, parseNotes(data, f.ByteOrder)...)
+	}
+	return notes, nil
+}
+
+// parseNotes 解析 note 段数据
+// 每条 note 格式：namesz(4) descsz(4) type(4) name(对齐到 4) desc(对齐到 4)
+func parseNotes(data []byte, order binary.ByteOrder) []elfNote {
+	var notes []elfNote
+	for len(data) >= 12 {
+		// 长度来自 core 文件，先与剩余数据比较再转换为 int
+		namesz32, descsz32 := order.Uint32(data[0:4]), order.Uint32(data[4:8])
+		typ := order.Uint32(data[8:12])
+		data = data[12:]
+		if uint64(namesz32) > uint64(len(data)) || uint64(descsz32) > uint64(len(data)) {
+			break
+		}
+		namesz, descsz := int(namesz32), int(descsz32)
+
+		nameEnd := align4(namesz)
+		if nameEnd > len(data) {
+			break
+		}
+		name := string(data[:namesz])
+		if n := len(name); n > 0 && name[n-1] == 0 {
+			name = name[:n-1]
+		}
+		data = data[nameEnd:]
+
+		descEnd := align4(descsz)
+		if descsz > len(data) {
+			break
+		}
+		notes = append(notes, elfNote{Name: name, Type: typ, Desc: data[:descsz]})
+		if descEnd > len(data) {
+			break
+		}
+		data = data[descEnd:]
+	}
+	return notes
+}
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// parseSignal 从 note 中提取导致 core dump 的信号
+// 优先使用 NT_SIGINFO（siginfo_t.si_signo），其次使用 NT_PRSTATUS（pr_info.si_signo）
+func parseSignal(notes []elfNote, order binary.ByteOrder) int {
+	for _, typ := range []uint32{ntSiginfo, ntPrstatus} {
+		for _, n := range notes {
+			if n.Name != "CORE" || n.Type != typ || len(n.Desc) < 4 {
+				continue
+			}
+			if signo := int(int32(order.Uint32(n.Desc[0:4]))); signo > 0 {
+				return signo
+			}
+		}
+	}
+	return 0
+}
+
+// ProcessInfo 崩溃进程信息，来自 NT_PRPSINFO
+type ProcessInfo struct {
+	// PID 崩溃进程在其所在 PID namespace 中的进程号
+	// 容器内的进程通常与宿主机上的进程号不同，只有共享宿主机 PID namespace 时才能直接用于 /proc 查询
+	PID  int
+	Name string // 进程名（comm），最长 15 个字符
+	// Executable 可执行文件在容器文件系统中的路径（NT_FILE 映射），未知时为空
+	Executable string
+	// BuildID 可执行文件的 GNU build-id（十六进制），取自 core 中转储的可执行文件首页，未知时为空
+	BuildID string
+	psargs  string // 命令行（pr_psargs），最长 80 个字符
+}
+
+// parsePrpsinfo 解析 NT_PRPSINFO（struct elf_prpsinfo）中的 pid、fname 和 psargs
+// 64 位：pr_flag 为 8 字节、uid/gid 为 4 字节，pr_pid 位于偏移 24，pr_fname 位于偏移 40
+// 32 位：pr_flag 为 4 字节、uid/gid 为 2 字节，pr_pid 位于偏移 12，pr_fname 位于偏移 28
+// pr_psargs 紧跟在 16 字节的 pr_fname 之后
+func parsePrpsinfo(notes []elfNote, order binary.ByteOrder, class elf.Class) (ProcessInfo, bool) {
+	pidOff, fnameOff := 24, 40
+	if class == elf.ELFCLASS32 {
+		pidOff, fnameOff = 12, 28
+	}
+	for _, n := range notes {
+		if n.Name != "CORE" || n.Type != ntPrpsinfo || len(n.Desc) < fnameOff+16 {
+			continue
+		}
+		return ProcessInfo{
+			PID:    int(int32(order.Uint32(n.Desc[pidOff : pidOff+4]))),
+			Name:   cString(n.Desc[fnameOff : fnameOff+16]),
+			psargs: cString(n.Desc[fnameOff+16:]),
+		}, true
+	}
+	return ProcessInfo{}, false
+}
+
+// cString 截取以 NUL 结尾的字符串
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// readProcess 解析崩溃进程信息，并从 NT_FILE 和可执行文件首页中补充可执行文件路径与 build-id
+func readProcess(f *elf.File, notes []elfNote) (ProcessInfo, bool) {
+	proc, ok := parsePrpsinfo(notes, f.ByteOrder, f.Class)
+	if !ok {
+		return proc, false
+	}
+	mappings := parseNTFile(notes, f.ByteOrder, f.Class)
+	psarg0, _, _ := strings.Cut(proc.psargs, " ")
+	proc.Executable = findExecutable(mappings, psarg0, proc.Name)
+	if proc.Executable != "" {
+		proc.BuildID = buildIDFromCore(f, mappings, proc.Executable)
+	}
+	return proc, true
+}
+
+// ReadProcessInfo 只读取 core 文件的 note 段获取崩溃进程信息，不计算摘要，适合在处理前快速调用
+func ReadProcessInfo(corefilePath string) (ProcessInfo, error) {
+	f, err := elf.Open(corefilePath)
+	if err != nil {
+		return ProcessInfo{}, err
+	}
+	defer f.Close()
+
+	notes, err := readCoreNotes(f)
+	if err != nil {
+		return ProcessInfo{}, err
+	}
+	proc, ok := readProcess(f, notes)
+	if !ok {
+		return ProcessInfo{}, fmt.Errorf("no NT_PRPSINFO note in %s", corefilePath)
+	}
+	return proc, nil
+}
+
+// parseELFNotes 使用 Go 原生 ELF 解析器补充 core 文件信息（信号等）
+// 解析失败不影响其它字段，仅返回错误供调用方记录
+func parseELFNotes(corefilePath string, info *CoreInfo) error {
+	f, err := elf.Open(corefilePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	notes, err := readCoreNotes(f)
+	if err != nil {
+		return err
+	}
+
+	info.Signal = parseSignal(notes, f.ByteOrder)
+	info.SignalName = SignalName(info.Signal)
+	if proc, ok := readProcess(f, notes); ok {
+		info.PID = proc.PID
+		info.BuildID = proc.BuildID
+	}
+	return nil
+}
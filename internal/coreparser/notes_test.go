@@ -0,0 +1,157 @@
+package coreparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildNote 按 ELF note 格式编码一条 note
+func buildNote(name string, typ uint32, desc []byte) []byte {
+	var buf bytes.Buffer
+	nameBytes := append([]byte(name), 0)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(nameBytes)))
+	binary.Write(&buf, binary.LittleEndian, uint32(len(desc)))
+	binary.Write(&buf, binary.LittleEndian, typ)
+	buf.Write(nameBytes)
+	buf.Write(make([]byte, align4(len(nameBytes))-len(nameBytes)))
+	buf.Write(desc)
+	buf.Write(make([]byte, align4(len(desc))-len(desc)))
+	return buf.Bytes()
+}
+
+// writeFakeCore 生成一个只包含单个 PT_NOTE 段的最小 ELF64 core 文件
+func writeFakeCore(t *testing.T, notes ...[]byte) string {
+	t.Helper()
+	noteData := bytes.Join(notes, nil)
+
+	const ehdrSize, phdrSize = 64, 56
+	var buf bytes.Buffer
+	ident := [16]byte{0x7f, 'E', 'L', 'F', 2, 1, 1}
+	buf.Write(ident[:])
+	le := binary.LittleEndian
+	binary.Write(&buf, le, uint16(4))        // e_type = ET_CORE
+	binary.Write(&buf, le, uint16(62))       // e_machine = EM_X86_64
+	binary.Write(&buf, le, uint32(1))        // e_version
+	binary.Write(&buf, le, uint64(0))        // e_entry
+	binary.Write(&buf, le, uint64(ehdrSize)) // e_phoff
+	binary.Write(&buf, le, uint64(0))        // e_shoff
+	binary.Write(&buf, le, uint32(0))        // e_flags
+	binary.Write(&buf, le, uint16(ehdrSize)) // e_ehsize
+	binary.Write(&buf, le, uint16(phdrSize)) // e_phentsize
+	binary.Write(&buf, le, uint16(1))        // e_phnum
+	binary.Write(&buf, le, uint16(64))       // e_shentsize
+	binary.Write(&buf, le, uint16(0))        // e_shnum
+	binary.Write(&buf, le, uint16(0))        // e_shstrndx
+
+	binary.Write(&buf, le, uint32(4))                 // p_type = PT_NOTE
+	binary.Write(&buf, le, uint32(0))                 // p_flags
+	binary.Write(&buf, le, uint64(ehdrSize+phdrSize)) // p_offset
+	binary.Write(&buf, le, uint64(0))                 // p_vaddr
+	binary.Write(&buf, le, uint64(0))                 // p_paddr
+	binary.Write(&buf, le, uint64(len(noteData)))     // p_filesz
+	binary.Write(&buf, le, uint64(len(noteData)))     // p_memsz
+	binary.Write(&buf, le, uint64(4))                 // p_align
+	buf.Write(noteData)
+
+	path := filepath.Join(t.TempDir(), "core.test.1")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func le32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+func TestParseELFNotesSignal(t *testing.T) {
+	tests := []struct {
+		name     string
+		notes    [][]byte
+		expected int
+	}{
+		{
+			name:     "prstatus",
+			notes:    [][]byte{buildNote("CORE", ntPrstatus, append(le32(11), make([]byte, 32)...))},
+			expected: 11,
+		},
+		{
+			name: "siginfo_preferred",
+			notes: [][]byte{
+				buildNote("CORE", ntPrstatus, append(le32(11), make([]byte, 32)...)),
+				buildNote("CORE", ntSiginfo, append(le32(6), make([]byte, 124)...)),
+			},
+			expected: 6,
+		},
+		{
+			name:     "ignore_non_core_owner",
+			notes:    [][]byte{buildNote("LINUX", ntPrstatus, le32(11))},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &CoreInfo{}
+			if err := parseELFNotes(writeFakeCore(t, tt.notes...), info); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Signal != tt.expected {
+				t.Errorf("expected signal %d, got %d", tt.expected, info.Signal)
+			}
+			if info.SignalName != SignalName(tt.expected) {
+				t.Errorf("expected signal name %q, got %q", SignalName(tt.expected), info.SignalName)
+			}
+		})
+	}
+}
+
+func TestSignalName(t *testing.T) {
+	tests := map[int]string{0: "", 11: "SIGSEGV", 6: "SIGABRT", 64: "SIG64"}
+	for signo, expected := range tests {
+		if got := SignalName(signo); got != expected {
+			t.Errorf("SignalName(%d) = %q, want %q", signo, got, expected)
+		}
+	}
+}
+
+// buildPrpsinfo64 编码 64 位 struct elf_prpsinfo
+func buildPrpsinfo64(pid uint32, fname, psargs string) []byte {
+	desc := make([]byte, 136)
+	copy(desc[24:28], le32(pid))
+	copy(desc[40:56], fname)
+	copy(desc[56:136], psargs)
+	return desc
+}
+
+func TestReadProcessInfo(t *testing.T) {
+	path := writeFakeCore(t,
+		buildNote("CORE", ntPrstatus, append(le32(6), make([]byte, 32)...)),
+		buildNote("CORE", ntPrpsinfo, buildPrpsinfo64(4242, "myapp", "/opt/myapp/bin/myapp --serve")),
+	)
+
+	proc, err := ReadProcessInfo(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if proc.PID != 4242 || proc.Name != "myapp" {
+		t.Errorf("unexpected process info %+v", proc)
+	}
+
+	info := &CoreInfo{}
+	if err := parseELFNotes(path, info); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.PID != 4242 {
+		t.Errorf("expected pid 4242, got %d", info.PID)
+	}
+
+	if _, err := ReadProcessInfo(writeFakeCore(t, buildNote("CORE", ntPrstatus, le32(11)))); err == nil {
+		t.Error("expected error for core without NT_PRPSINFO")
+	}
+}
@@ -2,6 +2,7 @@ package coreparser
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
@@ -9,6 +10,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/sirupsen/logrus"
 )
 
 // md5Semaphore 用于限制 MD5 计算的并发度，防止大规模 coredump 时给系统造成性能影响
@@ -21,6 +24,11 @@ type CoreInfo struct {
 	ProcessName    string // 进程名称
 	FileSize       int64  // core 文件大小
 	MD5            string // core 文件 MD5
+	SHA256         string // core 文件 SHA-256，与 MD5 在同一次读取中计算
+	Signal         int    // 导致 core dump 的信号编号，未知时为 0
+	SignalName     string // 信号名称，如 SIGSEGV
+	PID            int    // 崩溃进程的 PID（NT_PRPSINFO，进程所在 PID namespace 中的进程号），未知时为 0
+	BuildID        string // 可执行文件的 GNU build-id，未知时为空
 }
 
 // SetMD5Concurrency 设置 MD5 计算的最大并发数
@@ -48,11 +56,12 @@ func ParseCoreFile(corefilePath string) (*CoreInfo, error) {
 	info.FileSize = fi.Size()
 
 	// 2. 计算 MD5（必须成功，带并发限制）
-	md5Hash, err := calculateMD5(corefilePath)
+	md5Hash, sha256Hash, err := calculateDigests(corefilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate MD5: %w", err)
 	}
 	info.MD5 = md5Hash
+	info.SHA256 = sha256Hash
 
 	// 3. 使用 file 命令获取可执行文件路径
 	if err := parseWithFileCommand(corefilePath, info); err != nil {
@@ -62,28 +71,57 @@ func ParseCoreFile(corefilePath string) (*CoreInfo, error) {
 	// 4. 从路径提取进程名
 	info.ProcessName = GetProcessNameFromPath(info.ExecutablePath)
 
+	// 5. 从 ELF notes 中解析信号（尽力而为，失败不影响其它字段）
+	if err := parseELFNotes(corefilePath, info); err != nil {
+		logrus.Debugf("failed to parse ELF notes of %s: %v", corefilePath, err)
+	}
+
+	return info, nil
+}
+
+// ParseMetadata 只读取 core 文件的 note 段获取可执行文件、信号等元数据，不计算摘要
+// 用于不上传的 core（如超出配额），避免读取整个文件
+func ParseMetadata(corefilePath string) (*CoreInfo, error) {
+	fi, err := os.Stat(corefilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat core file: %w", err)
+	}
+	info := &CoreInfo{FileSize: fi.Size()}
+	if err := parseELFNotes(corefilePath, info); err != nil {
+		return nil, fmt.Errorf("failed to parse ELF notes: %w", err)
+	}
+	if proc, err := ReadProcessInfo(corefilePath); err == nil {
+		info.ExecutablePath = proc.Executable
+		info.ProcessName = GetProcessNameFromPath(proc.Executable)
+	}
 	return info, nil
 }
 
-// calculateMD5 计算文件的 MD5 哈希
+// Digests 计算文件的 MD5 和 SHA-256，用于 ParseCoreFile 失败时仍需要摘要的场景（如对象 key）
+func Digests(filePath string) (md5Sum, sha256Sum string, err error) {
+	return calculateDigests(filePath)
+}
+
+// calculateDigests 计算文件的 MD5 和 SHA-256 哈希（只读取一次文件）
 // 使用信号量限制并发度，防止大量 coredump 同时计算 MD5 导致系统负载过高
-func calculateMD5(filePath string) (string, error) {
+func calculateDigests(filePath string) (string, string, error) {
 	// 获取信号量，限制并发
 	md5Semaphore <- struct{}{}
 	defer func() { <-md5Semaphore }()
 
 	file, err := os.Open(filePath)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	defer file.Close()
 
-	hash := md5.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", err
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), file); err != nil {
+		return "", "", err
 	}
 
-	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+	return fmt.Sprintf("%x", md5Hash.Sum(nil)), fmt.Sprintf("%x", sha256Hash.Sum(nil)), nil
 }
 
 // parseWithFileCommand 使用 file 命令获取 core 文件信息
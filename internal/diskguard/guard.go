@@ -0,0 +1,246 @@
+package diskguard
+
+import (
+	"math"
+	"os"
+	"sync/atomic"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Usage 文件系统的使用情况
+type Usage struct {
+	TotalBytes  uint64
+	FreeBytes   uint64
+	TotalInodes uint64
+	FreeInodes  uint64
+}
+
+// FreePercent 空闲空间占比
+func (u Usage) FreePercent() float64 {
+	return percent(u.FreeBytes, u.TotalBytes)
+}
+
+// FreeInodesPercent 空闲 inode 占比，文件系统不限制 inode（总量为 0）时为 100
+func (u Usage) FreeInodesPercent() float64 {
+	return percent(u.FreeInodes, u.TotalInodes)
+}
+
+func percent(free, total uint64) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(free) * 100 / float64(total)
+}
+
+// StatFS 获取 path 所在文件系统的使用情况，测试中可替换为 fake
+type StatFS func(path string) (Usage, error)
+
+// Level 磁盘压力等级
+type Level int32
+
+const (
+	Normal Level = iota
+	Pressure
+	Critical
+)
+
+func (l Level) String() string {
+	switch l {
+	case Pressure:
+		return "pressure"
+	case Critical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// 清理方式，与 gc_type 相同
+const (
+	ActionTruncate = "truncate"
+	ActionRemove   = "rm"
+	ActionNone     = "none"
+)
+
+// Reclaimed 被清理的 core 文件
+type Reclaimed struct {
+	Path   string
+	Action string // truncate 或 rm
+	Size   int64
+}
+
+// Guard 定期检查 Dir 所在文件系统，磁盘临界时清理低优先级的 core 文件
+type Guard struct {
+	Dir    string
+	Config cfgpkg.DiskGuardConfig
+	Action string // truncate、rm 或 none
+	StatFS StatFS // 为 nil 时使用 Statfs
+
+	// Candidates 返回可以清理的 core 文件，按优先级从低到高排列
+	Candidates func() []string
+	// Claim 清理前调用，返回 false 时跳过该文件（例如已开始处理），为 nil 时不检查
+	Claim func(path string) bool
+	// OnReclaim 一次检查中清理了 core 文件后调用
+	OnReclaim func(files []Reclaimed)
+	// OnLevelChange 压力等级变化时调用
+	OnLevelChange func(from, to Level, usage Usage)
+
+	level atomic.Int32
+}
+
+// Level 最近一次检查的压力等级
+func (g *Guard) Level() Level {
+	return Level(g.level.Load())
+}
+
+// Run 每隔 interval 检查一次，直到 stop 关闭
+func (g *Guard) Run(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		g.Check()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Check 检查一次磁盘使用情况，临界时清理 core 文件，返回检查后的压力等级
+func (g *Guard) Check() Level {
+	statfs := g.StatFS
+	if statfs == nil {
+		statfs = Statfs
+	}
+	usage, err := statfs(g.Dir)
+	if err != nil {
+		logrus.Warnf("disk guard: failed to stat %s: %v", g.Dir, err)
+		return g.Level()
+	}
+	level := g.classify(usage)
+	g.setLevel(level, usage)
+	if level != Critical {
+		return level
+	}
+
+	reclaimed := g.reclaim(usage)
+	if len(reclaimed) == 0 {
+		return level
+	}
+	if g.OnReclaim != nil {
+		g.OnReclaim(reclaimed)
+	}
+	if usage, err = statfs(g.Dir); err != nil {
+		return level
+	}
+	level = g.classify(usage)
+	g.setLevel(level, usage)
+	return level
+}
+
+// classify 按空闲空间和空闲 inode 中更严重的一个确定压力等级
+// 只有空闲量回到阈值加 Hysteresis() 以上才降级，避免在阈值附近反复切换
+func (g *Guard) classify(u Usage) Level {
+	level := g.classifyWithMargin(u, 0)
+	if current := g.Level(); level < current {
+		level = min(current, g.classifyWithMargin(u, g.Config.Hysteresis()))
+	}
+	return level
+}
+
+// classifyWithMargin 把各阈值提高 margin 后确定压力等级，未设置（为 0）的阈值不检查
+func (g *Guard) classifyWithMargin(u Usage, margin float64) Level {
+	level := Normal
+	check := func(free, pressure, critical float64) {
+		switch {
+		case critical > 0 && free < critical+margin:
+			level = Critical
+		case pressure > 0 && free < pressure+margin && level < Pressure:
+			level = Pressure
+		}
+	}
+	check(u.FreePercent(), g.Config.PressureFree(), g.Config.CriticalFree())
+	check(u.FreeInodesPercent(), g.Config.PressureFreeInodes(), g.Config.CriticalFreeInodes())
+	return level
+}
+
+func (g *Guard) setLevel(level Level, u Usage) {
+	from := Level(g.level.Swap(int32(level)))
+	if from == level {
+		return
+	}
+	logrus.Warnf("disk guard: %s is now %s (free %.1f%% space, %.1f%% inodes)", g.Dir, level, u.FreePercent(), u.FreeInodesPercent())
+	if g.OnLevelChange != nil {
+		g.OnLevelChange(from, level, u)
+	}
+}
+
+// reclaim 按优先级从低到高清理 core 文件，直到空闲量回到 critical 阈值以上
+// truncate 不释放 inode，只有 rm 能缓解 inode 不足
+func (g *Guard) reclaim(u Usage) []Reclaimed {
+	if g.Action != ActionTruncate && g.Action != ActionRemove {
+		logrus.Warnf("disk guard: %s is critical but action is %q, not reclaiming core files", g.Dir, g.Action)
+		return nil
+	}
+	needBytes := deficit(u.FreeBytes, u.TotalBytes, g.Config.CriticalFree())
+	needInodes := deficit(u.FreeInodes, u.TotalInodes, g.Config.CriticalFreeInodes())
+	if g.Action == ActionTruncate {
+		needInodes = 0
+	}
+	if g.Candidates == nil {
+		return nil
+	}
+
+	var reclaimed []Reclaimed
+	for _, path := range g.Candidates() {
+		if needBytes <= 0 && needInodes <= 0 {
+			break
+		}
+		fi, err := os.Stat(path)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		size := fi.Size()
+		if g.Action == ActionTruncate && size == 0 {
+			continue
+		}
+		if g.Claim != nil && !g.Claim(path) {
+			continue
+		}
+		if g.Action == ActionTruncate {
+			err = os.Truncate(path, 0)
+		} else {
+			err = os.Remove(path)
+		}
+		if err != nil {
+			logrus.Errorf("disk guard: failed to %s %s: %v", g.Action, path, err)
+			continue
+		}
+		logrus.Warnf("disk guard: %s %s (%d bytes) to free disk space", g.Action, path, size)
+		needBytes -= size
+		if g.Action == ActionRemove {
+			needInodes--
+		}
+		reclaimed = append(reclaimed, Reclaimed{Path: path, Action: g.Action, Size: size})
+	}
+	if needBytes > 0 || needInodes > 0 {
+		logrus.Warnf("disk guard: %s is still critical after reclaiming %d core files", g.Dir, len(reclaimed))
+	}
+	return reclaimed
+}
+
+// deficit 空闲量距离 critical 阈值的差额
+func deficit(free, total uint64, criticalPercent float64) int64 {
+	if criticalPercent <= 0 || total == 0 {
+		return 0
+	}
+	want := int64(math.Ceil(float64(total) * criticalPercent / 100))
+	return want - int64(free)
+}
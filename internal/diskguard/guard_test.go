@@ -0,0 +1,174 @@
+package diskguard
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+)
+
+// fakeDisk 容量固定的磁盘，已用空间为 dir 中文件大小之和加上 used
+type fakeDisk struct {
+	dir         string
+	total       uint64
+	used        uint64
+	totalInodes uint64
+}
+
+func (d *fakeDisk) statfs(path string) (Usage, error) {
+	used, inodes := d.used, uint64(0)
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return Usage{}, err
+	}
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil {
+			return Usage{}, err
+		}
+		used += uint64(fi.Size())
+		inodes++
+	}
+	return Usage{TotalBytes: d.total, FreeBytes: d.total - used, TotalInodes: d.totalInodes, FreeInodes: d.totalInodes - inodes}, nil
+}
+
+func threshold(v float64) *float64 { return &v }
+
+var testConfig = cfgpkg.DiskGuardConfig{
+	PressureFreePercent:       threshold(20),
+	CriticalFreePercent:       threshold(10),
+	PressureFreeInodesPercent: threshold(20),
+	CriticalFreeInodesPercent: threshold(10),
+	HysteresisPercent:         threshold(0),
+}
+
+func writeCores(t *testing.T, dir string, sizes ...int) []string {
+	t.Helper()
+	var paths []string
+	for i, size := range sizes {
+		path := filepath.Join(dir, "core."+string(rune('a'+i)))
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestGuardLevels(t *testing.T) {
+	dir := t.TempDir()
+	disk := &fakeDisk{dir: dir, total: 1000, totalInodes: 100}
+	var changes []Level
+	g := &Guard{
+		Dir:           dir,
+		Config:        testConfig,
+		Action:        ActionNone,
+		StatFS:        disk.statfs,
+		OnLevelChange: func(from, to Level, _ Usage) { changes = append(changes, to) },
+	}
+
+	if got := g.Check(); got != Normal {
+		t.Fatalf("expected normal, got %s", got)
+	}
+	disk.used = 850
+	if got := g.Check(); got != Pressure {
+		t.Fatalf("expected pressure, got %s", got)
+	}
+	// action none：临界时不清理
+	paths := writeCores(t, dir, 100)
+	if got := g.Check(); got != Critical {
+		t.Fatalf("expected critical, got %s", got)
+	}
+	if _, err := os.Stat(paths[0]); err != nil {
+		t.Errorf("core removed with action none: %v", err)
+	}
+	disk.used = 0
+	g.Check()
+	if want := []Level{Pressure, Critical, Normal}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("level changes %v, want %v", changes, want)
+	}
+
+	// inode 不足
+	disk.totalInodes = 1
+	if got := g.Check(); got != Critical {
+		t.Errorf("expected critical on inodes, got %s", got)
+	}
+}
+
+func TestGuardHysteresis(t *testing.T) {
+	dir := t.TempDir()
+	disk := &fakeDisk{dir: dir, total: 1000}
+	cfg := testConfig
+	cfg.HysteresisPercent = threshold(5)
+	var changes []Level
+	g := &Guard{
+		Dir:           dir,
+		Config:        cfg,
+		Action:        ActionNone,
+		StatFS:        disk.statfs,
+		OnLevelChange: func(from, to Level, _ Usage) { changes = append(changes, to) },
+	}
+
+	// 空闲 8% 为 critical，11% 仍在回差内；16% 降为 pressure，22%、15% 仍为 pressure；26% 恢复
+	for _, used := range []uint64{920, 890, 920, 840, 780, 850, 740} {
+		disk.used = used
+		g.Check()
+	}
+	if want := []Level{Critical, Pressure, Normal}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("level changes %v, want %v", changes, want)
+	}
+}
+
+func TestGuardReclaim(t *testing.T) {
+	for _, action := range []string{ActionTruncate, ActionRemove} {
+		t.Run(action, func(t *testing.T) {
+			dir := t.TempDir()
+			disk := &fakeDisk{dir: dir, total: 1000, used: 500, totalInodes: 100}
+			// 优先级从低到高：c、b、a；a 正在处理
+			paths := writeCores(t, dir, 200, 150, 100)
+			var reclaimed []Reclaimed
+			g := &Guard{
+				Dir:        dir,
+				Config:     testConfig,
+				Action:     action,
+				StatFS:     disk.statfs,
+				Candidates: func() []string { return []string{paths[2], paths[1], paths[0]} },
+				Claim:      func(path string) bool { return path != paths[0] },
+				OnReclaim:  func(files []Reclaimed) { reclaimed = files },
+			}
+
+			// 空闲 50，临界阈值 100：清理 c（100）后空闲 150，仍处于 pressure
+			if got := g.Check(); got != Pressure {
+				t.Fatalf("expected pressure after reclaim, got %s", got)
+			}
+			want := []Reclaimed{{Path: paths[2], Action: action, Size: 100}}
+			if !reflect.DeepEqual(reclaimed, want) {
+				t.Errorf("reclaimed %+v, want %+v", reclaimed, want)
+			}
+			fi, err := os.Stat(paths[2])
+			switch action {
+			case ActionTruncate:
+				if err != nil || fi.Size() != 0 {
+					t.Errorf("expected truncated file, got (%v, %v)", fi, err)
+				}
+			case ActionRemove:
+				if !os.IsNotExist(err) {
+					t.Errorf("expected removed file, got %v", err)
+				}
+			}
+
+			// 需要更多空间时跳过正在处理的文件
+			disk.used = 560
+			reclaimed = nil
+			g.Check()
+			if len(reclaimed) != 1 || reclaimed[0].Path != paths[1] {
+				t.Errorf("expected %s to be reclaimed, got %+v", paths[1], reclaimed)
+			}
+			if _, err := os.Stat(paths[0]); err != nil {
+				t.Errorf("in-flight core touched: %v", err)
+			}
+		})
+	}
+}
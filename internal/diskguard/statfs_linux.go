@@ -0,0 +1,19 @@
+//go:build linux
+
+package diskguard
+
+import "syscall"
+
+// Statfs 通过 statfs(2) 获取 path 所在文件系统的使用情况，空闲量为非特权用户可用的部分
+func Statfs(path string) (Usage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return Usage{}, err
+	}
+	return Usage{
+		TotalBytes:  st.Blocks * uint64(st.Bsize),
+		FreeBytes:   st.Bavail * uint64(st.Bsize),
+		TotalInodes: st.Files,
+		FreeInodes:  st.Ffree,
+	}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package diskguard
+
+import "errors"
+
+// Statfs 仅支持 Linux
+func Statfs(path string) (Usage, error) {
+	return Usage{}, errors.New("statfs is only supported on linux")
+}
@@ -2,10 +2,12 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -19,17 +21,32 @@ type CoredumpInfo struct {
 	MD5            string
 	FileSize       int64
 	ExecutablePath string
+	ClusterName    string
+	ClusterID      string // 默认为 kube-system namespace 的 UID
 }
 
 // PodInfo contains information about the pod
 type PodInfo struct {
-	Name          string
-	Namespace     string
-	UID           string
-	NodeIP        string
-	Image         string
-	ContainerName string
-	IsLegacyPath  bool // 标记是否来自旧路径格式
+	Name                  string
+	Namespace             string
+	UID                   string
+	NodeIP                string
+	NodeName              string
+	ServiceAccount        string
+	Image                 string
+	ImageDigest           string
+	ContainerName         string
+	WorkloadKind          string
+	WorkloadName          string
+	RestartCount          int32
+	LastTerminationReason string
+	LastExitCode          int32
+	Labels                map[string]string
+	Annotations           map[string]string // 只包含 PodMetadata.annotations 选择的 annotation
+	IsLegacyPath          bool              // 标记是否来自旧路径格式
+	ContainerMatch        string            // 容器匹配方式，如 cgroup、build-id、name
+	MatchConfidence       float64           // 容器匹配置信度，0~1
+	ContainerCandidates   []string          // 无法确定容器时的候选容器名
 }
 
 // CustomHandler executes user-defined scripts for coredump processing
@@ -88,6 +105,22 @@ func (h *CustomHandler) Execute(ctx context.Context, coredump CoredumpInfo, pod
 		fmt.Sprintf("POD_NODE_IP=%s", pod.NodeIP),
 		fmt.Sprintf("POD_IMAGE=%s", pod.Image),
 		fmt.Sprintf("POD_CONTAINER=%s", pod.ContainerName),
+		fmt.Sprintf("POD_NODE_NAME=%s", pod.NodeName),
+		fmt.Sprintf("POD_SERVICE_ACCOUNT=%s", pod.ServiceAccount),
+		fmt.Sprintf("POD_IMAGE_DIGEST=%s", pod.ImageDigest),
+		fmt.Sprintf("POD_WORKLOAD_KIND=%s", pod.WorkloadKind),
+		fmt.Sprintf("POD_WORKLOAD_NAME=%s", pod.WorkloadName),
+		fmt.Sprintf("POD_RESTART_COUNT=%d", pod.RestartCount),
+		fmt.Sprintf("POD_LAST_TERMINATION_REASON=%s", pod.LastTerminationReason),
+		fmt.Sprintf("POD_LAST_EXIT_CODE=%d", pod.LastExitCode),
+		fmt.Sprintf("POD_LABELS=%s", jsonMap(pod.Labels)),
+		fmt.Sprintf("POD_ANNOTATIONS=%s", jsonMap(pod.Annotations)),
+		fmt.Sprintf("POD_CONTAINER_MATCH=%s", pod.ContainerMatch),
+		fmt.Sprintf("POD_CONTAINER_MATCH_CONFIDENCE=%.2f", pod.MatchConfidence),
+		fmt.Sprintf("POD_CONTAINER_CANDIDATES=%s", strings.Join(pod.ContainerCandidates, ",")),
+		// Cluster info
+		fmt.Sprintf("CLUSTER_NAME=%s", coredump.ClusterName),
+		fmt.Sprintf("CLUSTER_ID=%s", coredump.ClusterID),
 		// Host info
 		fmt.Sprintf("HOST_IP=%s", os.Getenv("HOST_IP")),
 	)
@@ -106,3 +139,15 @@ func (h *CustomHandler) Execute(ctx context.Context, coredump CoredumpInfo, pod
 	logrus.Infof("custom handler script executed successfully, output: %s", string(output))
 	return nil
 }
+
+// jsonMap 将 map 编码为 JSON 对象，空 map 编码为 {}，便于脚本用 jq 解析
+func jsonMap(m map[string]string) string {
+	if m == nil {
+		m = map[string]string{}
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
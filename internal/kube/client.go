@@ -0,0 +1,45 @@
+package kube
+
+import (
+	"sync"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	clientOnce sync.Once
+	client     kubernetes.Interface
+	clientErr  error
+
+	dynamicOnce   sync.Once
+	dynamicClient dynamic.Interface
+	dynamicErr    error
+)
+
+// Client 返回进程内共享的 in-cluster clientset
+func Client() (kubernetes.Interface, error) {
+	clientOnce.Do(func() {
+		var cfg *rest.Config
+		cfg, clientErr = rest.InClusterConfig()
+		if clientErr != nil {
+			return
+		}
+		client, clientErr = kubernetes.NewForConfig(cfg)
+	})
+	return client, clientErr
+}
+
+// Dynamic 返回进程内共享的 in-cluster dynamic client，用于访问 CoreDump 等自定义资源
+func Dynamic() (dynamic.Interface, error) {
+	dynamicOnce.Do(func() {
+		var cfg *rest.Config
+		cfg, dynamicErr = rest.InClusterConfig()
+		if dynamicErr != nil {
+			return
+		}
+		dynamicClient, dynamicErr = dynamic.NewForConfig(cfg)
+	})
+	return dynamicClient, dynamicErr
+}
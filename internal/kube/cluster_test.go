@@ -0,0 +1,24 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClusterID(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: "6b1c5a0e-0000-0000-0000-000000000001"},
+	})
+	id, err := ClusterID(context.Background(), client)
+	if err != nil || id != "6b1c5a0e-0000-0000-0000-000000000001" {
+		t.Errorf("got (%q, %v)", id, err)
+	}
+
+	if _, err := ClusterID(context.Background(), fake.NewSimpleClientset()); err == nil {
+		t.Error("expected error without kube-system namespace")
+	}
+}
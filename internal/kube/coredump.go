@@ -0,0 +1,189 @@
+package kube
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+// ErrStatusNotSet CoreDump 已创建，但初始状态写入失败
+var ErrStatusNotSet = errors.New("CoreDump created without status")
+
+// CoreDumpGVR CoreDump 自定义资源（定义见 charts/crds/coredumps.coredog.io.yaml）
+var CoreDumpGVR = schema.GroupVersionResource{Group: "coredog.io", Version: "v1alpha1", Resource: "coredumps"}
+
+const coreDumpKind = "CoreDump"
+
+// CoreDump 处理阶段
+const (
+	CoreDumpPhaseUploaded      = "Uploaded"
+	CoreDumpPhaseUploadFailed  = "UploadFailed"
+	CoreDumpPhaseQuotaExceeded = "QuotaExceeded" // 超出配额，未上传
+)
+
+// CoreDump 一次被收集的 core dump
+type CoreDump struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CoreDumpSpec   `json:"spec"`
+	Status CoreDumpStatus `json:"status,omitempty"`
+}
+
+// CoreDumpSpec core dump 的来源和内容，创建后不再变化
+type CoreDumpSpec struct {
+	CoredumpID   string          `json:"coredumpID"`
+	Pod          CoreDumpPod     `json:"pod"`
+	Container    string          `json:"container,omitempty"`
+	Image        string          `json:"image,omitempty"`
+	Node         string          `json:"node,omitempty"`
+	NodeIP       string          `json:"nodeIP,omitempty"`
+	FileName     string          `json:"fileName"`
+	Executable   string          `json:"executable,omitempty"`
+	Signal       string          `json:"signal,omitempty"`
+	SignalNumber int             `json:"signalNumber,omitempty"`
+	Size         int64           `json:"size"`
+	Digests      CoreDumpDigests `json:"digests,omitempty"`
+	Storage      CoreDumpStorage `json:"storage,omitempty"`
+	Fingerprint  string          `json:"fingerprint,omitempty"`
+	CollectedAt  metav1.Time     `json:"collectedAt"`
+}
+
+// CoreDumpPod 产生 core dump 的 Pod
+type CoreDumpPod struct {
+	Name string `json:"name"`
+	UID  string `json:"uid,omitempty"`
+}
+
+// CoreDumpDigests core 文件摘要
+type CoreDumpDigests struct {
+	MD5    string `json:"md5,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// CoreDumpStorage core 文件的存储位置
+type CoreDumpStorage struct {
+	Protocol string `json:"protocol,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// CoreDumpStatus 处理状态
+type CoreDumpStatus struct {
+	Phase        string   `json:"phase,omitempty"`
+	Message      string   `json:"message,omitempty"`
+	Handler      string   `json:"handler,omitempty"`   // 自定义处理器结果：Succeeded、Failed
+	LocalFile    string   `json:"localFile,omitempty"` // 本地文件处理：Deleted、Truncated、Retained
+	Unresolved   []string `json:"unresolved,omitempty"`
+	Completeness float64  `json:"completeness,omitempty"`
+}
+
+// CoreDumpWriter 通过 dynamic client 维护 CoreDump 资源
+type CoreDumpWriter struct {
+	client dynamic.Interface
+}
+
+// NewCoreDumpWriter 创建 CoreDumpWriter
+func NewCoreDumpWriter(client dynamic.Interface) *CoreDumpWriter {
+	return &CoreDumpWriter{client: client}
+}
+
+// Create 创建 CoreDump，并写入初始状态
+// 资源已创建但状态写入失败时返回 ErrStatusNotSet
+// pod UID 已知且 ownedByPod 为 true 时设置指向 Pod 的 ownerReference（Pod 删除时 CoreDump 随之删除）
+func (w *CoreDumpWriter) Create(ctx context.Context, cd *CoreDump, ownedByPod bool) error {
+	cd.TypeMeta = metav1.TypeMeta{APIVersion: CoreDumpGVR.GroupVersion().String(), Kind: coreDumpKind}
+	if ownedByPod && cd.Spec.Pod.UID != "" {
+		cd.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       cd.Spec.Pod.Name,
+			UID:        types.UID(cd.Spec.Pod.UID),
+		}}
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cd)
+	if err != nil {
+		return fmt.Errorf("failed to convert CoreDump: %w", err)
+	}
+	status := cd.Status
+
+	obj := &unstructured.Unstructured{Object: content}
+	unstructured.RemoveNestedField(obj.Object, "status")
+	if _, err := w.client.Resource(CoreDumpGVR).Namespace(cd.Namespace).Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create CoreDump %s/%s: %w", cd.Namespace, cd.Name, err)
+	}
+	if err := w.UpdateStatus(ctx, cd.Namespace, cd.Name, status); err != nil {
+		return fmt.Errorf("%w: %v", ErrStatusNotSet, err)
+	}
+	return nil
+}
+
+// CreateOrReplace 创建 CoreDump，同名资源已存在时以 cd 覆盖其 spec 和 status
+// 用于断点续传：上一次运行中上传失败的 core 已有同名（coredump ID）的 CoreDump，续传成功后不应保留失败的状态
+func (w *CoreDumpWriter) CreateOrReplace(ctx context.Context, cd *CoreDump, ownedByPod bool) error {
+	err := w.Create(ctx, cd, ownedByPod)
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cd)
+	if err != nil {
+		return fmt.Errorf("failed to convert CoreDump: %w", err)
+	}
+	res := w.client.Resource(CoreDumpGVR).Namespace(cd.Namespace)
+	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		obj, err := res.Get(ctx, cd.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		obj.Object["spec"] = content["spec"]
+		if obj, err = res.Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		obj.Object["status"] = content["status"]
+		_, err = res.UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace CoreDump %s/%s: %w", cd.Namespace, cd.Name, err)
+	}
+	return nil
+}
+
+// UpdateStatus 以 merge patch 更新 status 子资源中非空的字段，临时错误按 retry.DefaultBackoff 重试
+func (w *CoreDumpWriter) UpdateStatus(ctx context.Context, namespace, name string, status CoreDumpStatus) error {
+	patch, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return err
+	}
+	retriable := func(err error) bool {
+		return !apierrors.IsNotFound(err) && !apierrors.IsInvalid(err) && ctx.Err() == nil
+	}
+	err = retry.OnError(retry.DefaultBackoff, retriable, func() error {
+		_, err := w.client.Resource(CoreDumpGVR).Namespace(namespace).
+			Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update CoreDump %s/%s status: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// Fingerprint 由可执行文件、信号和镜像计算崩溃指纹
+// 只是粗粒度的聚合键：同一程序因同一信号在同一镜像中崩溃即视为相同，不区分调用栈
+func Fingerprint(executable, signal, image string) string {
+	sum := sha256.Sum256([]byte(executable + "|" + signal + "|" + image))
+	return hex.EncodeToString(sum[:])[:16]
+}
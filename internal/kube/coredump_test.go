@@ -0,0 +1,187 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCoreDumpWriter(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{CoreDumpGVR: "CoreDumpList"})
+	w := NewCoreDumpWriter(client)
+	ctx := context.Background()
+
+	cd := &CoreDump{
+		ObjectMeta: metav1.ObjectMeta{Name: "0190a1b2-c3d4-7e5f-8a9b-0c1d2e3f4a5b", Namespace: "myns"},
+		Spec: CoreDumpSpec{
+			CoredumpID:   "0190a1b2-c3d4-7e5f-8a9b-0c1d2e3f4a5b",
+			Pod:          CoreDumpPod{Name: "app-0", UID: "9f8e7d6c-0000-0000-0000-000000000001"},
+			Container:    "app",
+			FileName:     "core.app.1",
+			Executable:   "/app/bin/app",
+			Signal:       "SIGSEGV",
+			SignalNumber: 11,
+			Size:         4096,
+			Digests:      CoreDumpDigests{MD5: "d41d8cd98f00b204e9800998ecf8427e"},
+			Fingerprint:  Fingerprint("/app/bin/app", "SIGSEGV", "app:v1"),
+			CollectedAt:  metav1.NewTime(time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)),
+		},
+		Status: CoreDumpStatus{Phase: CoreDumpPhaseUploaded, Completeness: 1},
+	}
+	if err := w.Create(ctx, cd, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.UpdateStatus(ctx, "myns", cd.Name, CoreDumpStatus{LocalFile: "Deleted"}); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := client.Resource(CoreDumpGVR).Namespace("myns").Get(ctx, cd.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got CoreDump
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != "CoreDump" || got.APIVersion != "coredog.io/v1alpha1" {
+		t.Errorf("unexpected type meta %s/%s", got.APIVersion, got.Kind)
+	}
+	if got.Spec.Executable != "/app/bin/app" || got.Spec.SignalNumber != 11 || got.Spec.Size != 4096 {
+		t.Errorf("unexpected spec %+v", got.Spec)
+	}
+	if len(got.OwnerReferences) != 1 || got.OwnerReferences[0].Kind != "Pod" || string(got.OwnerReferences[0].UID) != cd.Spec.Pod.UID {
+		t.Errorf("unexpected owner references %+v", got.OwnerReferences)
+	}
+	// 后续状态更新保留已有字段
+	if got.Status.Phase != CoreDumpPhaseUploaded || got.Status.LocalFile != "Deleted" {
+		t.Errorf("unexpected status %+v", got.Status)
+	}
+}
+
+func TestCoreDumpWriterCreateOrReplace(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{CoreDumpGVR: "CoreDumpList"})
+	w := NewCoreDumpWriter(client)
+	ctx := context.Background()
+
+	// 上一次运行中上传失败
+	failed := &CoreDump{
+		ObjectMeta: metav1.ObjectMeta{Name: "dump-1", Namespace: "myns"},
+		Spec:       CoreDumpSpec{CoredumpID: "dump-1", Pod: CoreDumpPod{Name: "app-0"}, FileName: "core.1"},
+		Status:     CoreDumpStatus{Phase: CoreDumpPhaseUploadFailed, Message: "connection reset", LocalFile: "Retained"},
+	}
+	if err := w.CreateOrReplace(ctx, failed, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// 续传成功：覆盖 spec 和 status
+	uploaded := &CoreDump{
+		ObjectMeta: metav1.ObjectMeta{Name: "dump-1", Namespace: "myns"},
+		Spec: CoreDumpSpec{CoredumpID: "dump-1", Pod: CoreDumpPod{Name: "app-0"}, FileName: "core.1",
+			Storage: CoreDumpStorage{Protocol: "s3", URL: "https://example.com/core.1"}},
+		Status: CoreDumpStatus{Phase: CoreDumpPhaseUploaded},
+	}
+	if err := w.CreateOrReplace(ctx, uploaded, false); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := client.Resource(CoreDumpGVR).Namespace("myns").Get(ctx, "dump-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got CoreDump
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Spec.Storage.URL != "https://example.com/core.1" {
+		t.Errorf("spec not replaced: %+v", got.Spec)
+	}
+	if got.Status.Phase != CoreDumpPhaseUploaded || got.Status.Message != "" || got.Status.LocalFile != "" {
+		t.Errorf("status not replaced: %+v", got.Status)
+	}
+}
+
+func TestCoreDumpWriterWithoutOwner(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{CoreDumpGVR: "CoreDumpList"})
+	w := NewCoreDumpWriter(client)
+
+	cd := &CoreDump{
+		ObjectMeta: metav1.ObjectMeta{Name: "dump-1", Namespace: "myns"},
+		Spec:       CoreDumpSpec{CoredumpID: "dump-1", Pod: CoreDumpPod{Name: "app-0"}, FileName: "core.1"},
+	}
+	if err := w.Create(context.Background(), cd, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(cd.OwnerReferences) != 0 {
+		t.Errorf("owner reference set without pod uid: %+v", cd.OwnerReferences)
+	}
+}
+
+func TestCoreDumpWriterStatusRetry(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{CoreDumpGVR: "CoreDumpList"})
+	failures := 2
+	client.PrependReactor("patch", "coredumps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if failures == 0 {
+			return false, nil, nil
+		}
+		failures--
+		return true, nil, apierrors.NewServiceUnavailable("etcd leader changed")
+	})
+	w := NewCoreDumpWriter(client)
+	ctx := context.Background()
+
+	// 临时错误重试后成功
+	cd := &CoreDump{
+		ObjectMeta: metav1.ObjectMeta{Name: "dump-1", Namespace: "myns"},
+		Spec:       CoreDumpSpec{CoredumpID: "dump-1", FileName: "core.1"},
+		Status:     CoreDumpStatus{Phase: CoreDumpPhaseUploaded},
+	}
+	if err := w.Create(ctx, cd, false); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := client.Resource(CoreDumpGVR).Namespace("myns").Get(ctx, "dump-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase"); phase != CoreDumpPhaseUploaded {
+		t.Errorf("status phase = %q after retry", phase)
+	}
+
+	// 状态一直写入失败时资源仍然已创建
+	failures = 100
+	cd = &CoreDump{
+		ObjectMeta: metav1.ObjectMeta{Name: "dump-2", Namespace: "myns"},
+		Spec:       CoreDumpSpec{CoredumpID: "dump-2", FileName: "core.2"},
+		Status:     CoreDumpStatus{Phase: CoreDumpPhaseUploaded},
+	}
+	if err := w.Create(ctx, cd, false); !errors.Is(err, ErrStatusNotSet) {
+		t.Fatalf("expected ErrStatusNotSet, got %v", err)
+	}
+	if _, err := client.Resource(CoreDumpGVR).Namespace("myns").Get(ctx, "dump-2", metav1.GetOptions{}); err != nil {
+		t.Errorf("CoreDump should exist: %v", err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := Fingerprint("/app/bin/app", "SIGSEGV", "app:v1")
+	if len(a) != 16 {
+		t.Fatalf("unexpected fingerprint %q", a)
+	}
+	if a != Fingerprint("/app/bin/app", "SIGSEGV", "app:v1") {
+		t.Error("fingerprint is not stable")
+	}
+	if a == Fingerprint("/app/bin/app", "SIGABRT", "app:v1") {
+		t.Error("different signals share a fingerprint")
+	}
+}
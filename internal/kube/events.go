@@ -0,0 +1,86 @@
+package kube
+
+import (
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// ReasonCoreDumped Pod 中的进程产生了 core dump
+	ReasonCoreDumped = "CoreDumped"
+
+	eventComponent = "coredog-watcher"
+	// maxEventMessage Event message 的长度上限，超出部分截断
+	maxEventMessage = 1024
+)
+
+// PodRef 事件关联的 Pod
+type PodRef struct {
+	Namespace string
+	Name      string
+	UID       string
+}
+
+// Recorder 在 Pod 上记录 Kubernetes Event
+// 使用 client-go 的 EventBroadcaster：相同的事件会合并计数，短时间内同一 Pod 的大量相似事件会被聚合
+type Recorder struct {
+	broadcaster record.EventBroadcaster
+	recorder    record.EventRecorder
+}
+
+// NewRecorder 创建事件记录器，host 为 watcher 所在节点名称
+func NewRecorder(client kubernetes.Interface, host string) *Recorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	broadcaster.StartLogging(func(format string, args ...interface{}) {
+		logrus.Debugf(format, args...)
+	})
+	return &Recorder{
+		broadcaster: broadcaster,
+		recorder:    broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: eventComponent, Host: host}),
+	}
+}
+
+// CoreDumped 在 Pod 上记录 CoreDumped Warning 事件，annotations 会写入 Event 对象
+func (r *Recorder) CoreDumped(pod PodRef, message string, annotations map[string]string) {
+	if r == nil {
+		return
+	}
+	message = truncateMessage(message)
+	ref := &v1.ObjectReference{
+		Kind:       "Pod",
+		APIVersion: "v1",
+		Namespace:  pod.Namespace,
+		Name:       pod.Name,
+		UID:        types.UID(pod.UID),
+	}
+	r.recorder.AnnotatedEventf(ref, annotations, v1.EventTypeWarning, ReasonCoreDumped, "%s", message)
+}
+
+// truncateMessage 把 message 截断到 maxEventMessage 字节以内
+// 消息大多是中文，截断位置退回到 UTF-8 字符边界，避免留下半个字符
+func truncateMessage(message string) string {
+	if len(message) <= maxEventMessage {
+		return message
+	}
+	n := maxEventMessage - 3
+	for n > 0 && !utf8.RuneStart(message[n]) {
+		n--
+	}
+	return message[:n] + "..."
+}
+
+// Shutdown 停止事件广播
+func (r *Recorder) Shutdown() {
+	if r == nil {
+		return
+	}
+	r.broadcaster.Shutdown()
+}
@@ -0,0 +1,88 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// waitForEvent 等待 default 命名空间中出现满足条件的事件
+func waitForEvent(t *testing.T, client *fake.Clientset, cond func(v1.Event) bool) v1.Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		list, err := client.CoreV1().Events("default").List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range list.Items {
+			if cond(e) {
+				return e
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for event")
+	return v1.Event{}
+}
+
+func TestRecorderCoreDumped(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	r := NewRecorder(client, "node-1")
+	defer r.Shutdown()
+
+	pod := PodRef{Namespace: "default", Name: "app-0", UID: "9f8e7d6c-0000-0000-0000-000000000001"}
+	annotations := map[string]string{"coredog.io/download-url": "https://bucket/core.app.1"}
+	r.CoreDumped(pod, "Container app: /app/bin/app dumped core (SIGSEGV)", annotations)
+
+	e := waitForEvent(t, client, func(e v1.Event) bool { return e.Reason == ReasonCoreDumped })
+	if e.Type != v1.EventTypeWarning {
+		t.Errorf("expected Warning event, got %s", e.Type)
+	}
+	if e.InvolvedObject.Kind != "Pod" || e.InvolvedObject.Name != "app-0" || string(e.InvolvedObject.UID) != pod.UID {
+		t.Errorf("unexpected involved object: %+v", e.InvolvedObject)
+	}
+	if e.Source.Component != eventComponent || e.Source.Host != "node-1" {
+		t.Errorf("unexpected source: %+v", e.Source)
+	}
+	if e.Annotations["coredog.io/download-url"] != "https://bucket/core.app.1" {
+		t.Errorf("unexpected annotations: %v", e.Annotations)
+	}
+
+	// 相同的事件合并计数，而不是创建新的 Event
+	r.CoreDumped(pod, "Container app: /app/bin/app dumped core (SIGSEGV)", annotations)
+	waitForEvent(t, client, func(e v1.Event) bool { return e.Reason == ReasonCoreDumped && e.Count == 2 })
+}
+
+func TestRecorderTruncatesMessage(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	r := NewRecorder(client, "node-1")
+	defer r.Shutdown()
+
+	long := make([]byte, 2000)
+	for i := range long {
+		long[i] = 'x'
+	}
+	r.CoreDumped(PodRef{Namespace: "default", Name: "app-0", UID: "uid"}, string(long), nil)
+	e := waitForEvent(t, client, func(e v1.Event) bool { return e.Reason == ReasonCoreDumped })
+	if len(e.Message) != maxEventMessage {
+		t.Errorf("expected message truncated to %d, got %d", maxEventMessage, len(e.Message))
+	}
+}
+
+func TestTruncateMessageUTF8(t *testing.T) {
+	long := strings.Repeat("崩溃", 500) // 每个汉字 3 字节
+	got := truncateMessage("x" + long)
+	if !utf8.ValidString(got) || len(got) > maxEventMessage || !strings.HasSuffix(got, "...") {
+		t.Errorf("truncated message is invalid: len %d, valid %v", len(got), utf8.ValidString(got))
+	}
+	if short := "容器崩溃"; truncateMessage(short) != short {
+		t.Error("short message should not be truncated")
+	}
+}
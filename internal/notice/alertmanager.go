@@ -0,0 +1,161 @@
+package notice
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// alertmanagerAlertName core dump 告警的 alertname
+	alertmanagerAlertName = "CoreDump"
+	// alertmanagerGenericAlertName 非 core dump 告警（如 webhook 注入失败）的 alertname
+	alertmanagerGenericAlertName = "CoreDogAlert"
+	defaultResolveAfter          = time.Hour
+)
+
+// AlertmanagerMsg 通过 Alertmanager v2 API 推送告警
+// 告警带有 endsAt，到期后由 Alertmanager 自动恢复
+type AlertmanagerMsg struct {
+	apiURL         string
+	headers        map[string]string
+	headersFromEnv map[string]string
+	resolveAfter   time.Duration
+	generatorURL   string // 来源链接模板
+	client         *http.Client
+}
+
+// amAlert 对应 Alertmanager v2 API 中 postableAlert 的结构
+type amAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     string            `json:"startsAt,omitempty"`
+	EndsAt       string            `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// NewAlertmanagerMsg 创建 Alertmanager 通知
+// webhookurl 可以是 Alertmanager 地址（如 http://alertmanager:9093），也可以是完整的 /api/v2/alerts 地址
+// generatorURL 为告警的来源链接模板，变量见 renderGeneratorURL
+func NewAlertmanagerMsg(webhookurl string, headers, headersFromEnv map[string]string, resolveAfterSeconds int, generatorURL string) Notifier {
+	apiURL := strings.TrimRight(webhookurl, "/")
+	if !strings.HasSuffix(apiURL, "/api/v2/alerts") {
+		apiURL += "/api/v2/alerts"
+	}
+	resolveAfter := time.Duration(resolveAfterSeconds) * time.Second
+	if resolveAfter <= 0 {
+		resolveAfter = defaultResolveAfter
+	}
+	return AlertmanagerMsg{
+		apiURL:         apiURL,
+		headers:        headers,
+		headersFromEnv: headersFromEnv,
+		resolveAfter:   resolveAfter,
+		generatorURL:   generatorURL,
+		client:         &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// buildAlert 构建 postableAlert，空值标签会被省略（Alertmanager 中空标签等价于不存在）
+func (am AlertmanagerMsg) buildAlert(alertname, content string, alert Alert) amAlert {
+	startsAt := alert.Time
+	if startsAt.IsZero() {
+		startsAt = time.Now()
+	}
+
+	labels := map[string]string{"alertname": alertname}
+	for k, v := range map[string]string{
+		"cluster":    alert.Cluster,
+		"namespace":  alert.Namespace,
+		"pod":        alert.Pod,
+		"workload":   alert.Workload,
+		"container":  alert.Container,
+		"node":       alert.Node,
+		"executable": alert.Executable,
+		"signal":     alert.Signal,
+	} {
+		if v != "" {
+			labels[k] = v
+		}
+	}
+
+	annotations := map[string]string{"summary": content}
+	for k, v := range map[string]string{
+		"cluster_id":         alert.ClusterID,
+		"download_url":       alert.URL,
+		"md5":                alert.MD5,
+		"file_name":          alert.FileName,
+		"mentions":           strings.Join(alert.Mentions, ","),
+		"image":              alert.Image,
+		"image_digest":       alert.ImageDigest,
+		"workload_kind":      alert.WorkloadKind,
+		"termination_reason": alert.LastTerminationReason,
+	} {
+		if v != "" {
+			annotations[k] = v
+		}
+	}
+
+	return amAlert{
+		Labels:       labels,
+		Annotations:  annotations,
+		StartsAt:     startsAt.UTC().Format(time.RFC3339),
+		EndsAt:       startsAt.Add(am.resolveAfter).UTC().Format(time.RFC3339),
+		GeneratorURL: am.renderGeneratorURL(alert),
+	}
+}
+
+// renderGeneratorURL 渲染来源链接，变量值经过 URL 转义
+// core 文件的下载地址在 download_url 注解中，不作为来源链接
+func (am AlertmanagerMsg) renderGeneratorURL(alert Alert) string {
+	if am.generatorURL == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{cluster}", url.QueryEscape(alert.Cluster),
+		"{namespace}", url.QueryEscape(alert.Namespace),
+		"{pod}", url.QueryEscape(alert.Pod),
+		"{workload}", url.QueryEscape(alert.Workload),
+		"{node}", url.QueryEscape(alert.Node),
+	).Replace(am.generatorURL)
+}
+
+func (am AlertmanagerMsg) Notice(content string) {
+	logSendError(am.Deliver(content))
+}
+
+func (am AlertmanagerMsg) Deliver(content string) error {
+	return am.post(am.buildAlert(alertmanagerGenericAlertName, content, Alert{}))
+}
+
+func (am AlertmanagerMsg) NoticeAlert(content string, alert Alert) {
+	logSendError(am.post(am.buildAlert(alertmanagerAlertName, content, alert)))
+}
+
+func (am AlertmanagerMsg) post(alerts ...amAlert) error {
+	payload, err := json.Marshal(alerts)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alertmanager payload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, am.apiURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create alertmanager request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setHeaders(req, am.headers, am.headersFromEnv)
+
+	resp, err := am.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return checkStatus("alertmanager "+am.apiURL, resp.StatusCode, string(body))
+}
@@ -0,0 +1,102 @@
+package notice
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAlertmanagerURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"http://am:9093":                  "http://am:9093/api/v2/alerts",
+		"http://am:9093/":                 "http://am:9093/api/v2/alerts",
+		"http://am:9093/api/v2/alerts":    "http://am:9093/api/v2/alerts",
+		"http://am:9093/api/v2/alerts/":   "http://am:9093/api/v2/alerts",
+		"http://gw/prom/am/api/v2/alerts": "http://gw/prom/am/api/v2/alerts",
+	} {
+		if got := NewAlertmanagerMsg(in, nil, nil, 0, "").(AlertmanagerMsg).apiURL; got != want {
+			t.Errorf("%s: api url = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestAlertmanagerBuildAlert(t *testing.T) {
+	am := NewAlertmanagerMsg("http://am:9093", nil, nil, 600,
+		"https://grafana/d/cores?var-cluster={cluster}&var-namespace={namespace}&var-pod={pod}").(AlertmanagerMsg)
+	start := time.Date(2026, 10, 18, 8, 0, 0, 0, time.FixedZone("UTC+8", 8*3600))
+	a := am.buildAlert(alertmanagerAlertName, "core dumped", Alert{
+		Cluster:    "prod eu",
+		Namespace:  "default",
+		Pod:        "app-0",
+		Executable: "/app/server",
+		Signal:     "SIGSEGV",
+		URL:        "https://bucket/core.app.1?X-Amz-Signature=abc",
+		MD5:        "d41d8cd98f00b204e9800998ecf8427e",
+		Time:       start,
+	})
+
+	wantLabels := map[string]string{
+		"alertname":  "CoreDump",
+		"cluster":    "prod eu",
+		"namespace":  "default",
+		"pod":        "app-0",
+		"executable": "/app/server",
+		"signal":     "SIGSEGV",
+	}
+	if len(a.Labels) != len(wantLabels) {
+		t.Errorf("labels = %v, empty values should be omitted", a.Labels)
+	}
+	for k, v := range wantLabels {
+		if a.Labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, a.Labels[k], v)
+		}
+	}
+	if a.StartsAt != "2026-10-18T00:00:00Z" || a.EndsAt != "2026-10-18T00:10:00Z" {
+		t.Errorf("startsAt/endsAt = %s/%s", a.StartsAt, a.EndsAt)
+	}
+	if a.Annotations["summary"] != "core dumped" || a.Annotations["download_url"] != "https://bucket/core.app.1?X-Amz-Signature=abc" {
+		t.Errorf("annotations = %v", a.Annotations)
+	}
+	if want := "https://grafana/d/cores?var-cluster=prod+eu&var-namespace=default&var-pod=app-0"; a.GeneratorURL != want {
+		t.Errorf("generatorURL = %s, want %s", a.GeneratorURL, want)
+	}
+
+	// 未配置来源链接时不设置，下载地址不作为来源链接
+	am = NewAlertmanagerMsg("http://am:9093", nil, nil, 0, "").(AlertmanagerMsg)
+	a = am.buildAlert(alertmanagerAlertName, "core dumped", Alert{URL: "https://bucket/core", Time: start})
+	if a.GeneratorURL != "" {
+		t.Errorf("generatorURL = %s", a.GeneratorURL)
+	}
+	if a.EndsAt != "2026-10-18T01:00:00Z" {
+		t.Errorf("default resolve time: endsAt = %s", a.EndsAt)
+	}
+}
+
+func TestAlertmanagerPost(t *testing.T) {
+	server, reqs := newCaptureServer(t, http.StatusOK)
+	t.Setenv("COREDOG_TEST_AM_AUTH", "Bearer token")
+	am := NewAlertmanagerMsg(server.URL, nil, map[string]string{"Authorization": "COREDOG_TEST_AM_AUTH"}, 0, "")
+
+	Send(am, "core dumped", Alert{Namespace: "default", Pod: "app-0"})
+	req := <-reqs
+	if req.path != "/api/v2/alerts" || req.header.Get("Authorization") != "Bearer token" {
+		t.Errorf("request %s %v", req.path, req.header)
+	}
+	var alerts []amAlert
+	if err := json.Unmarshal(req.body, &alerts); err != nil || len(alerts) != 1 {
+		t.Fatalf("body = %s (%v)", req.body, err)
+	}
+	if alerts[0].Labels["alertname"] != "CoreDump" || alerts[0].Labels["pod"] != "app-0" {
+		t.Errorf("labels = %v", alerts[0].Labels)
+	}
+
+	// 非 core dump 告警（如 webhook 注入失败）
+	if err := Deliver(am, "inject failed"); err != nil {
+		t.Fatal(err)
+	}
+	req = <-reqs
+	if err := json.Unmarshal(req.body, &alerts); err != nil || alerts[0].Labels["alertname"] != "CoreDogAlert" {
+		t.Errorf("body = %s (%v)", req.body, err)
+	}
+}
@@ -1,6 +1,11 @@
 package notice
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
 	"github.com/parnurzeal/gorequest"
 	"github.com/sirupsen/logrus"
 )
@@ -9,6 +14,98 @@ type Notifier interface {
 	Notice(content string)
 }
 
+// Alert core dump 告警的结构化信息
+// 文本类渠道只使用渲染后的消息内容，Alertmanager 等渠道需要结构化字段
+type Alert struct {
+	Cluster    string // 集群名称，未设置时为集群 ID
+	ClusterID  string
+	Namespace  string
+	Pod        string
+	Workload   string
+	Container  string
+	Node       string
+	Image      string
+	Executable string
+	Signal     string
+	FileName   string
+	URL        string
+	MD5        string
+	Time       time.Time
+
+	WorkloadKind          string // Deployment、StatefulSet、DaemonSet、CronJob 等
+	ImageDigest           string
+	RestartCount          int32
+	LastTerminationReason string
+	LastExitCode          int32
+
+	Labels      map[string]string // Pod labels
+	Annotations map[string]string // Pod annotations
+	Mentions    []string          // 需要 @ 的用户（来自 coredog.io/notify-mentions）
+}
+
+// AlertNotifier 支持结构化告警的 Notifier
+type AlertNotifier interface {
+	Notifier
+	NoticeAlert(content string, alert Alert)
+}
+
+// Send 发送 core dump 告警，渠道支持结构化告警时优先使用结构化字段
+func Send(n Notifier, content string, alert Alert) {
+	if an, ok := n.(AlertNotifier); ok {
+		an.NoticeAlert(content, alert)
+		return
+	}
+	n.Notice(content)
+}
+
+// Deliverer 能返回发送结果的 Notifier，Notice 发送失败时只记录日志
+type Deliverer interface {
+	Deliver(content string) error
+}
+
+// Deliver 发送通知并返回发送结果，不支持返回结果的 Notifier 总是返回 nil
+func Deliver(n Notifier, content string) error {
+	if d, ok := n.(Deliverer); ok {
+		return d.Deliver(content)
+	}
+	n.Notice(content)
+	return nil
+}
+
+// logSendError 记录发送失败，Notice/NoticeAlert 没有返回值
+func logSendError(err error) {
+	if err != nil {
+		logrus.Errorf("send notice error:%v", err)
+	}
+}
+
+// checkStatus 非 2xx 响应作为发送失败
+func checkStatus(target string, status int, body string) error {
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("%s returned status %d, body: %s", target, status, body)
+	}
+	return nil
+}
+
+// NewNotifier 根据通知渠道配置创建对应的 Notifier
+// agent 与 webhook server 共用该入口，保证各渠道的消息格式一致
+func NewNotifier(ch cfgpkg.NoticeChannel) (Notifier, error) {
+	switch ch.Chan {
+	case "wechat":
+		return NewWechatWebhookMsg(ch.Webhookurl), nil
+	case "slack":
+		return NewSlackWebhookMsg(ch.Webhookurl), nil
+	case "teams":
+		return NewTeamsWebhookMsg(ch.Webhookurl), nil
+	case "webhook":
+		return NewGenericWebhookMsg(ch.Webhookurl, ch.Method, ch.Headers, ch.HeadersFromEnv, ch.BodyTemplate), nil
+	case "alertmanager":
+		return NewAlertmanagerMsg(ch.Webhookurl, ch.Headers, ch.HeadersFromEnv, ch.ResolveAfterSeconds, ch.GeneratorURL), nil
+	default:
+		return nil, fmt.Errorf("unsupported notice channel: %s", ch.Chan)
+	}
+}
+
 type WechatWebhookMsg struct {
 	webhookurl string
 }
@@ -20,17 +117,35 @@ func NewWechatWebhookMsg(webhookurl string) Notifier {
 }
 
 func (wm WechatWebhookMsg) Notice(content string) {
+	logSendError(wm.send(content, nil))
+}
+
+func (wm WechatWebhookMsg) Deliver(content string) error {
+	return wm.send(content, nil)
+}
+
+// NoticeAlert 通过 mentioned_list @ 指定用户（企业微信 userid，@all 表示所有人）
+func (wm WechatWebhookMsg) NoticeAlert(content string, alert Alert) {
+	logSendError(wm.send(content, alert.Mentions))
+}
+
+func (wm WechatWebhookMsg) send(content string, mentions []string) error {
 	payload := map[string]interface{}{
 		"msgtype": "text",
 	}
-	payload["text"] = map[string]string{
+	text := map[string]interface{}{
 		"content": content,
 	}
+	if len(mentions) > 0 {
+		text["mentioned_list"] = mentions
+	}
+	payload["text"] = text
 
-	_, _, errs := gorequest.New().Post(wm.webhookurl).Send(payload).End()
+	resp, body, errs := gorequest.New().Post(wm.webhookurl).Send(payload).End()
 	if len(errs) > 0 {
-		logrus.Errorf("send notice error:%v", errs)
+		return fmt.Errorf("%v", errs)
 	}
+	return checkStatus("wechat webhook", resp.StatusCode, body)
 }
 
 type SlackWebhookMsg struct {
@@ -43,15 +158,36 @@ func NewSlackWebhookMsg(webhookurl string) Notifier {
 	}
 }
 
+// NoticeAlert 在消息前 @ 指定用户，支持 Slack member ID（U123）或已格式化的 <@U123>、<!here>
+func (wm SlackWebhookMsg) NoticeAlert(content string, alert Alert) {
+	if len(alert.Mentions) == 0 {
+		wm.Notice(content)
+		return
+	}
+	var mentions []string
+	for _, m := range alert.Mentions {
+		if !strings.HasPrefix(m, "<") {
+			m = "<@" + strings.TrimPrefix(m, "@") + ">"
+		}
+		mentions = append(mentions, m)
+	}
+	wm.Notice(strings.Join(mentions, " ") + "\n" + content)
+}
+
 func (wm SlackWebhookMsg) Notice(content string) {
+	logSendError(wm.Deliver(content))
+}
+
+func (wm SlackWebhookMsg) Deliver(content string) error {
 	payload := map[string]interface{}{
 		"text": content,
 	}
 
-	_, _, errs := gorequest.New().Post(wm.webhookurl).
+	resp, body, errs := gorequest.New().Post(wm.webhookurl).
 		Set("Content-type", "application/json").
 		Send(payload).End()
 	if len(errs) > 0 {
-		logrus.Errorf("send notice error:%v", errs)
+		return fmt.Errorf("%v", errs)
 	}
+	return checkStatus("slack webhook", resp.StatusCode, body)
 }
@@ -0,0 +1,258 @@
+package notice
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// matcher 匹配单个字段值
+type matcher func(value string) bool
+
+// compilePattern 编译匹配模式
+// 以 ~ 开头的按正则匹配（整串匹配），否则按通配符匹配（* 匹配任意字符，? 匹配单个字符）
+func compilePattern(pattern string) (matcher, error) {
+	var expr string
+	if strings.HasPrefix(pattern, "~") {
+		expr = "^(?:" + pattern[1:] + ")$"
+	} else {
+		expr = regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+		expr = "^" + expr + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return re.MatchString, nil
+}
+
+func compilePatterns(patterns []string, normalize func(string) string) ([]matcher, error) {
+	var matchers []matcher
+	for _, p := range patterns {
+		if normalize != nil && !strings.HasPrefix(p, "~") {
+			p = normalize(p)
+		}
+		m, err := compilePattern(p)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func compileMapPatterns(patterns map[string]string) (map[string]matcher, error) {
+	matchers := make(map[string]matcher, len(patterns))
+	for k, p := range patterns {
+		m, err := compilePattern(p)
+		if err != nil {
+			return nil, err
+		}
+		matchers[k] = m
+	}
+	return matchers, nil
+}
+
+// normalizeSignal 统一信号写法：SEGV、sigsegv 均视为 SIGSEGV
+func normalizeSignal(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s != "" && !strings.HasPrefix(s, "SIG") {
+		s = "SIG" + s
+	}
+	return s
+}
+
+// matchAny 没有配置条件时视为命中；否则任意一个模式命中任意一个候选值即可
+func matchAny(matchers []matcher, values ...string) bool {
+	if len(matchers) == 0 {
+		return true
+	}
+	for _, m := range matchers {
+		for _, v := range values {
+			if m(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchMap 每个 key 都必须存在且值匹配
+func matchMap(matchers map[string]matcher, values map[string]string) bool {
+	for k, m := range matchers {
+		v, ok := values[k]
+		if !ok || !m(v) {
+			return false
+		}
+	}
+	return true
+}
+
+type route struct {
+	cfg         cfgpkg.NoticeRoute
+	namespaces  []matcher
+	containers  []matcher
+	executables []matcher
+	signals     []matcher
+	images      []matcher
+	labels      map[string]matcher
+	annotations map[string]matcher
+}
+
+func (rt *route) match(alert Alert) bool {
+	return matchAny(rt.namespaces, alert.Namespace) &&
+		matchAny(rt.containers, alert.Container) &&
+		matchAny(rt.executables, alert.Executable, filepath.Base(alert.Executable)) &&
+		matchAny(rt.signals, alert.Signal) &&
+		matchAny(rt.images, alert.Image) &&
+		matchMap(rt.labels, alert.Labels) &&
+		matchMap(rt.annotations, alert.Annotations)
+}
+
+// Router 根据 NoticeRoutes 为告警选择通知渠道
+type Router struct {
+	channels []cfgpkg.NoticeChannel
+	byName   map[string]cfgpkg.NoticeChannel
+	routes   []*route
+	allowed  map[string]bool // 允许 Pod 通过 annotation 选择的渠道
+}
+
+// NewRouter 编译路由规则，规则引用了不存在的渠道或模式非法时返回错误
+// podAllowedChannels 为允许 Pod 通过 annotation 选择的渠道名称，为空表示不允许
+func NewRouter(channels []cfgpkg.NoticeChannel, routes []cfgpkg.NoticeRoute, podAllowedChannels []string) (*Router, error) {
+	r := &Router{
+		channels: channels,
+		byName:   make(map[string]cfgpkg.NoticeChannel),
+		allowed:  make(map[string]bool),
+	}
+	for _, ch := range channels {
+		if ch.Name != "" {
+			r.byName[ch.Name] = ch
+		}
+	}
+
+	for _, name := range podAllowedChannels {
+		if _, ok := r.byName[name]; !ok {
+			return nil, fmt.Errorf("PodNotify.allowedChannels: channel %q is not defined in NoticeChannel", name)
+		}
+		r.allowed[name] = true
+	}
+
+	for i, rc := range routes {
+		for _, name := range rc.Channels {
+			if _, ok := r.byName[name]; !ok {
+				return nil, fmt.Errorf("NoticeRoutes[%d]: channel %q is not defined in NoticeChannel", i, name)
+			}
+		}
+
+		rt := &route{cfg: rc}
+		var err error
+		if rt.namespaces, err = compilePatterns(rc.Match.Namespaces, nil); err != nil {
+			return nil, fmt.Errorf("NoticeRoutes[%d]: %w", i, err)
+		}
+		if rt.containers, err = compilePatterns(rc.Match.Containers, nil); err != nil {
+			return nil, fmt.Errorf("NoticeRoutes[%d]: %w", i, err)
+		}
+		if rt.executables, err = compilePatterns(rc.Match.Executables, nil); err != nil {
+			return nil, fmt.Errorf("NoticeRoutes[%d]: %w", i, err)
+		}
+		if rt.signals, err = compilePatterns(rc.Match.Signals, normalizeSignal); err != nil {
+			return nil, fmt.Errorf("NoticeRoutes[%d]: %w", i, err)
+		}
+		if rt.images, err = compilePatterns(rc.Match.Images, nil); err != nil {
+			return nil, fmt.Errorf("NoticeRoutes[%d]: %w", i, err)
+		}
+		if rt.labels, err = compileMapPatterns(rc.Match.Labels); err != nil {
+			return nil, fmt.Errorf("NoticeRoutes[%d]: %w", i, err)
+		}
+		if rt.annotations, err = compileMapPatterns(rc.Match.Annotations); err != nil {
+			return nil, fmt.Errorf("NoticeRoutes[%d]: %w", i, err)
+		}
+		r.routes = append(r.routes, rt)
+	}
+	return r, nil
+}
+
+// Route 返回告警应发送的渠道
+// 规则按顺序匹配：命中 mute 规则时返回空；命中普通规则时收集其渠道，continue=false 时停止匹配。
+// 没有任何规则命中时回退到旧行为：所有渠道，按 keyword 对 corefile 路径做子串过滤
+func (r *Router) Route(alert Alert, corefilePath string) []cfgpkg.NoticeChannel {
+	var selected []cfgpkg.NoticeChannel
+	seen := make(map[string]bool)
+	matched := false
+
+	for _, rt := range r.routes {
+		if !rt.match(alert) {
+			continue
+		}
+		if rt.cfg.Mute {
+			return nil
+		}
+		matched = true
+		for _, name := range rt.cfg.Channels {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			selected = append(selected, r.byName[name])
+		}
+		if !rt.cfg.Continue {
+			break
+		}
+	}
+
+	if matched {
+		return selected
+	}
+
+	for _, ch := range r.channels {
+		if ch.Keyword != "" && !strings.Contains(corefilePath, ch.Keyword) {
+			continue
+		}
+		selected = append(selected, ch)
+	}
+	return selected
+}
+
+// Muted 告警是否被 NoticeRoutes 静默，与 Route 的匹配顺序一致：
+// 按顺序匹配，先命中 continue=false 的普通规则时不再检查后续的 mute 规则
+// Pod 通过 annotation 指定渠道时同样受静默规则约束
+func (r *Router) Muted(alert Alert) bool {
+	for _, rt := range r.routes {
+		if !rt.match(alert) {
+			continue
+		}
+		if rt.cfg.Mute {
+			return true
+		}
+		if !rt.cfg.Continue {
+			return false
+		}
+	}
+	return false
+}
+
+// PodChannels 返回 Pod 通过 annotation 指定且在白名单中的渠道
+// 不在白名单中的渠道会被忽略并记录警告，全部无效时返回空，由调用方回退到全局路由
+func (r *Router) PodChannels(names []string) []cfgpkg.NoticeChannel {
+	var selected []cfgpkg.NoticeChannel
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if !r.allowed[name] {
+			logrus.Warnf("notify channel %q from pod annotation is not in PodNotify.allowedChannels, ignored", name)
+			continue
+		}
+		selected = append(selected, r.byName[name])
+	}
+	return selected
+}
@@ -0,0 +1,166 @@
+package notice
+
+import (
+	"reflect"
+	"testing"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+)
+
+func channelNames(chs []cfgpkg.NoticeChannel) []string {
+	var names []string
+	for _, ch := range chs {
+		names = append(names, ch.Name)
+	}
+	return names
+}
+
+func TestRouterRoute(t *testing.T) {
+	channels := []cfgpkg.NoticeChannel{
+		{Name: "platform", Chan: "slack"},
+		{Name: "payments", Chan: "wechat"},
+		{Name: "oncall", Chan: "alertmanager"},
+		{Name: "legacy", Chan: "wechat", Keyword: "production"},
+	}
+	routes := []cfgpkg.NoticeRoute{
+		{
+			Match: cfgpkg.NoticeRouteMatch{Namespaces: []string{"sandbox-*"}},
+			Mute:  true,
+		},
+		{
+			Match:    cfgpkg.NoticeRouteMatch{Namespaces: []string{"~pay(ments|-gw)"}},
+			Channels: []string{"payments"},
+			Continue: true,
+		},
+		{
+			Match: cfgpkg.NoticeRouteMatch{
+				Signals: []string{"segv", "SIGABRT"},
+				Labels:  map[string]string{"tier": "critical"},
+			},
+			Channels: []string{"oncall", "payments"},
+		},
+		{
+			Match:    cfgpkg.NoticeRouteMatch{Executables: []string{"java"}, Images: []string{"*/openjdk:*"}},
+			Channels: []string{"platform"},
+		},
+	}
+
+	r, err := NewRouter(channels, routes, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		alert    Alert
+		path     string
+		expected []string
+	}{
+		{
+			name:     "muted",
+			alert:    Alert{Namespace: "sandbox-alice", Signal: "SIGSEGV", Labels: map[string]string{"tier": "critical"}},
+			expected: nil,
+		},
+		{
+			name:     "continue_then_stop_dedup",
+			alert:    Alert{Namespace: "payments", Signal: "SIGSEGV", Labels: map[string]string{"tier": "critical"}},
+			expected: []string{"payments", "oncall"},
+		},
+		{
+			name:     "continue_without_further_match",
+			alert:    Alert{Namespace: "pay-gw", Signal: "SIGBUS"},
+			expected: []string{"payments"},
+		},
+		{
+			name:     "regex_is_anchored",
+			alert:    Alert{Namespace: "prepayments", Signal: "SIGSEGV"},
+			path:     "/corefile/prepayments/app/core.1",
+			expected: []string{"platform", "payments", "oncall"},
+		},
+		{
+			name:     "executable_basename_and_image_glob",
+			alert:    Alert{Namespace: "default", Executable: "/usr/bin/java", Image: "docker.io/library/openjdk:17"},
+			expected: []string{"platform"},
+		},
+		{
+			name:     "fallback_with_keyword",
+			alert:    Alert{Namespace: "default"},
+			path:     "/corefile/production/app/app/core.1",
+			expected: []string{"platform", "payments", "oncall", "legacy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := channelNames(r.Route(tt.alert, tt.path))
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected channels %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewRouterUnknownChannel(t *testing.T) {
+	_, err := NewRouter(
+		[]cfgpkg.NoticeChannel{{Name: "platform", Chan: "slack"}},
+		[]cfgpkg.NoticeRoute{{Channels: []string{"missing"}}},
+		nil,
+	)
+	if err == nil {
+		t.Fatal("expected error for undefined channel")
+	}
+}
+
+func TestRouterPodChannels(t *testing.T) {
+	channels := []cfgpkg.NoticeChannel{
+		{Name: "platform", Chan: "slack"},
+		{Name: "team-a", Chan: "slack"},
+		{Name: "team-b", Chan: "wechat"},
+	}
+	r, err := NewRouter(channels, nil, []string{"team-a", "team-b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := channelNames(r.PodChannels([]string{"team-b", "platform", "team-b", "unknown"}))
+	if !reflect.DeepEqual(got, []string{"team-b"}) {
+		t.Errorf("expected [team-b], got %v", got)
+	}
+	if got := r.PodChannels([]string{"platform"}); len(got) != 0 {
+		t.Errorf("expected no channels for non-allowlisted name, got %v", channelNames(got))
+	}
+
+	if _, err := NewRouter(channels, nil, []string{"missing"}); err == nil {
+		t.Error("expected error for undefined allowlisted channel")
+	}
+}
+
+func TestRouterMuted(t *testing.T) {
+	channels := []cfgpkg.NoticeChannel{{Name: "platform", Chan: "slack"}, {Name: "team-a", Chan: "slack"}}
+	r, err := NewRouter(channels, []cfgpkg.NoticeRoute{
+		{Match: cfgpkg.NoticeRouteMatch{Namespaces: []string{"prod"}, Labels: map[string]string{"tier": "critical"}}, Channels: []string{"platform"}},
+		{Match: cfgpkg.NoticeRouteMatch{Namespaces: []string{"sandbox-*", "prod"}}, Mute: true},
+	}, []string{"team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		alert Alert
+		want  bool
+	}{
+		{Alert{Namespace: "sandbox-1"}, true},
+		{Alert{Namespace: "prod"}, true},
+		// 先命中 continue=false 的普通规则，后续的 mute 规则不生效，与 Route 一致
+		{Alert{Namespace: "prod", Labels: map[string]string{"tier": "critical"}}, false},
+		{Alert{Namespace: "default"}, false},
+	}
+	for _, tt := range tests {
+		if got := r.Muted(tt.alert); got != tt.want {
+			t.Errorf("%s %v: muted = %v, want %v", tt.alert.Namespace, tt.alert.Labels, got, tt.want)
+		}
+		if empty := len(r.Route(tt.alert, "/corefile/core")) == 0; tt.want && !empty {
+			t.Errorf("%s: Route should return no channels for muted alerts", tt.alert.Namespace)
+		}
+	}
+}
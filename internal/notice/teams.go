@@ -0,0 +1,111 @@
+package notice
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TeamsWebhookMsg 通过 Microsoft Teams Incoming Webhook / Workflows 发送 Adaptive Card
+type TeamsWebhookMsg struct {
+	webhookurl string
+	client     *http.Client
+}
+
+func NewTeamsWebhookMsg(webhookurl string) Notifier {
+	return TeamsWebhookMsg{
+		webhookurl: webhookurl,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// buildAdaptiveCard 将文本消息转换为 Adaptive Card
+// 第一行作为加粗标题，其余每行一个 TextBlock，保留消息模板的换行结构
+// mentions 为 Teams 用户的 UPN（邮箱）或 AAD object ID
+func buildAdaptiveCard(content string, mentions []string) map[string]interface{} {
+	if len(mentions) > 0 {
+		var tags []string
+		for _, m := range mentions {
+			tags = append(tags, "<at>"+m+"</at>")
+		}
+		content = strings.Join(tags, " ") + "\n" + content
+	}
+
+	var body []map[string]interface{}
+	for i, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		block := map[string]interface{}{
+			"type": "TextBlock",
+			"text": line,
+			"wrap": true,
+		}
+		if i == 0 {
+			block["weight"] = "Bolder"
+			block["size"] = "Medium"
+		}
+		body = append(body, block)
+	}
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if len(mentions) > 0 {
+		var entities []map[string]interface{}
+		for _, m := range mentions {
+			entities = append(entities, map[string]interface{}{
+				"type":      "mention",
+				"text":      "<at>" + m + "</at>",
+				"mentioned": map[string]string{"id": m, "name": m},
+			})
+		}
+		card["msteams"] = map[string]interface{}{"entities": entities}
+	}
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"contentUrl":  nil,
+				"content":     card,
+			},
+		},
+	}
+}
+
+func (tm TeamsWebhookMsg) Notice(content string) {
+	logSendError(tm.send(content, nil))
+}
+
+func (tm TeamsWebhookMsg) Deliver(content string) error {
+	return tm.send(content, nil)
+}
+
+func (tm TeamsWebhookMsg) NoticeAlert(content string, alert Alert) {
+	logSendError(tm.send(content, alert.Mentions))
+}
+
+func (tm TeamsWebhookMsg) send(content string, mentions []string) error {
+	payload, err := json.Marshal(buildAdaptiveCard(content, mentions))
+	if err != nil {
+		return fmt.Errorf("failed to marshal teams payload: %w", err)
+	}
+
+	resp, err := tm.client.Post(tm.webhookurl, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return checkStatus("teams webhook", resp.StatusCode, string(body))
+}
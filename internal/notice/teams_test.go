@@ -0,0 +1,91 @@
+package notice
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestBuildAdaptiveCard(t *testing.T) {
+	msg := buildAdaptiveCard("🚨 core dump\n\nPod: `default/app`\n", []string{"alice@example.com"})
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Type        string
+		Attachments []struct {
+			ContentType string
+			Content     struct {
+				Type    string
+				Version string
+				Body    []struct {
+					Type, Text, Weight string
+					Wrap               bool
+				}
+				MSTeams struct {
+					Entities []struct {
+						Type, Text string
+						Mentioned  struct{ ID, Name string }
+					}
+				} `json:"msteams"`
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Type != "message" || len(payload.Attachments) != 1 ||
+		payload.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" {
+		t.Fatalf("unexpected envelope: %s", data)
+	}
+	card := payload.Attachments[0].Content
+	if card.Type != "AdaptiveCard" || card.Version != "1.4" {
+		t.Errorf("card %s %s", card.Type, card.Version)
+	}
+	// 第一行为 @ 提及，空行被跳过
+	want := []string{"<at>alice@example.com</at>", "🚨 core dump", "Pod: `default/app`"}
+	if len(card.Body) != len(want) {
+		t.Fatalf("body = %+v", card.Body)
+	}
+	for i, b := range card.Body {
+		if b.Type != "TextBlock" || b.Text != want[i] || !b.Wrap {
+			t.Errorf("block %d = %+v", i, b)
+		}
+	}
+	if card.Body[0].Weight != "Bolder" || card.Body[1].Weight != "" {
+		t.Errorf("only the first line should be bold: %+v", card.Body)
+	}
+	entities := card.MSTeams.Entities
+	if len(entities) != 1 || entities[0].Type != "mention" || entities[0].Text != "<at>alice@example.com</at>" ||
+		entities[0].Mentioned.ID != "alice@example.com" {
+		t.Errorf("entities = %+v", entities)
+	}
+
+	// 无 @ 时没有 msteams 字段
+	plain := buildAdaptiveCard("title", nil)
+	card2 := plain["attachments"].([]map[string]interface{})[0]["content"].(map[string]interface{})
+	if _, ok := card2["msteams"]; ok {
+		t.Error("msteams should be omitted without mentions")
+	}
+}
+
+func TestTeamsSend(t *testing.T) {
+	server, reqs := newCaptureServer(t, http.StatusAccepted)
+	if err := Deliver(NewTeamsWebhookMsg(server.URL), "title\nline"); err != nil {
+		t.Fatal(err)
+	}
+	req := <-reqs
+	if req.method != http.MethodPost || req.header.Get("Content-Type") != "application/json" {
+		t.Errorf("request %s %v", req.method, req.header)
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(req.body, &v); err != nil || v["type"] != "message" {
+		t.Errorf("body = %s (%v)", req.body, err)
+	}
+
+	failing, _ := newCaptureServer(t, http.StatusBadRequest)
+	if err := Deliver(NewTeamsWebhookMsg(failing.URL), "title"); err == nil {
+		t.Error("expected error for 400 response")
+	}
+}
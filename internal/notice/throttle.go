@@ -0,0 +1,292 @@
+package notice
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultDigestInterval = time.Minute
+	// digestLinksPerGroup 汇总消息中每个分组最多展示的下载链接数
+	digestLinksPerGroup = 3
+)
+
+// Dispatcher 负责把告警发送到渠道，并按渠道做限流
+// 未配置限流的渠道直接发送；超出限流时告警进入汇总队列，每个周期合并为一条汇总消息
+type Dispatcher struct {
+	mu          sync.Mutex
+	throttles   map[string]*throttle
+	newNotifier func(ch cfgpkg.NoticeChannel) (Notifier, error)
+	stop        chan struct{}
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		throttles:   make(map[string]*throttle),
+		newNotifier: NewNotifier,
+		stop:        make(chan struct{}),
+	}
+}
+
+// channelKey 渠道的唯一标识，优先使用名称
+func channelKey(ch cfgpkg.NoticeChannel) string {
+	if ch.Name != "" {
+		return ch.Name
+	}
+	return ch.Chan + "|" + ch.Webhookurl
+}
+
+// Dispatch 发送一条 core dump 告警
+func (d *Dispatcher) Dispatch(ch cfgpkg.NoticeChannel, content string, alert Alert) {
+	n, err := d.newNotifier(ch)
+	if err != nil {
+		logrus.Warn(err)
+		return
+	}
+
+	if ch.RateLimit.PerMinute <= 0 {
+		Send(n, content, alert)
+		return
+	}
+
+	t := d.throttleFor(ch, n)
+	if t == nil {
+		// Close 之后不再汇总，直接发送，避免告警留在不会再发送的汇总队列中
+		logrus.Debugf("notice dispatcher closed, sending alert to channel %s without rate limit", channelKey(ch))
+		Send(n, content, alert)
+		return
+	}
+	t.dispatch(content, alert)
+}
+
+// Close 停止所有汇总协程，未发送的汇总会立即发送，之后的告警不再限流，直接发送
+// 发送在锁外进行，避免慢速渠道阻塞其他告警的分发
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	select {
+	case <-d.stop:
+		d.mu.Unlock()
+		return
+	default:
+		close(d.stop)
+	}
+	throttles := make([]*throttle, 0, len(d.throttles))
+	for _, t := range d.throttles {
+		throttles = append(throttles, t)
+	}
+	d.mu.Unlock()
+
+	for _, t := range throttles {
+		t.close()
+	}
+}
+
+// throttleFor 返回渠道的限流状态，Dispatcher 已关闭时返回 nil
+func (d *Dispatcher) throttleFor(ch cfgpkg.NoticeChannel, n Notifier) *throttle {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	select {
+	case <-d.stop:
+		return nil
+	default:
+	}
+	key := channelKey(ch)
+	if t, ok := d.throttles[key]; ok {
+		return t
+	}
+
+	burst := ch.RateLimit.Burst
+	if burst <= 0 {
+		burst = ch.RateLimit.PerMinute
+	}
+	interval := time.Duration(ch.RateLimit.DigestIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultDigestInterval
+	}
+
+	t := &throttle{
+		name:     key,
+		notifier: n,
+		limiter:  rate.NewLimiter(rate.Limit(float64(ch.RateLimit.PerMinute)/60), burst),
+		interval: interval,
+	}
+	d.throttles[key] = t
+	go t.run(d.stop)
+	logrus.Infof("notice channel %s rate limited: %d/min, burst=%d, digest interval=%v",
+		key, ch.RateLimit.PerMinute, burst, interval)
+	return t
+}
+
+// throttle 单个渠道的限流与汇总状态
+type throttle struct {
+	mu       sync.Mutex
+	name     string
+	notifier Notifier
+	limiter  *rate.Limiter
+	interval time.Duration
+	pending  []Alert
+	closed   bool // 关闭后不再汇总，告警直接发送
+}
+
+func (t *throttle) dispatch(content string, alert Alert) {
+	t.mu.Lock()
+	// 已进入汇总模式时继续汇总，保证消息顺序
+	if t.closed || (len(t.pending) == 0 && t.limiter.Allow()) {
+		t.mu.Unlock()
+		Send(t.notifier, content, alert)
+		return
+	}
+	t.pending = append(t.pending, alert)
+	count := len(t.pending)
+	t.mu.Unlock()
+	logrus.Debugf("notice channel %s over rate limit, %d alerts queued for digest", t.name, count)
+}
+
+func (t *throttle) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.flush()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// close 发送未发送的汇总，之后到达的告警直接发送
+func (t *throttle) close() {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+	t.flush()
+}
+
+func (t *throttle) flush() {
+	t.mu.Lock()
+	alerts := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+
+	if len(alerts) == 0 {
+		return
+	}
+	logrus.Infof("sending digest of %d alerts to notice channel %s", len(alerts), t.name)
+	Send(t.notifier, buildDigest(alerts, t.interval), digestAlert(alerts))
+}
+
+// digestAlert 汇总消息的结构化信息，渠道按各自的格式发送（@ 负责人、Alertmanager 标签等）
+// 所有告警相同的字段保留，不同的字段留空；@ 的用户取并集，时间取最新的告警
+func digestAlert(alerts []Alert) Alert {
+	d := Alert{
+		Cluster:               alerts[0].Cluster,
+		ClusterID:             alerts[0].ClusterID,
+		Namespace:             alerts[0].Namespace,
+		Workload:              alerts[0].Workload,
+		WorkloadKind:          alerts[0].WorkloadKind,
+		Container:             alerts[0].Container,
+		Node:                  alerts[0].Node,
+		Image:                 alerts[0].Image,
+		Executable:            alerts[0].Executable,
+		Signal:                alerts[0].Signal,
+		LastTerminationReason: alerts[0].LastTerminationReason,
+	}
+	// keep 只保留所有告警都相同的值
+	keep := func(dst *string, v string) {
+		if *dst != v {
+			*dst = ""
+		}
+	}
+	seen := make(map[string]bool)
+	for _, a := range alerts {
+		keep(&d.Cluster, a.Cluster)
+		keep(&d.ClusterID, a.ClusterID)
+		keep(&d.Namespace, a.Namespace)
+		keep(&d.Workload, a.Workload)
+		keep(&d.WorkloadKind, a.WorkloadKind)
+		keep(&d.Container, a.Container)
+		keep(&d.Node, a.Node)
+		keep(&d.Image, a.Image)
+		keep(&d.Executable, a.Executable)
+		keep(&d.Signal, a.Signal)
+		keep(&d.LastTerminationReason, a.LastTerminationReason)
+		for _, m := range a.Mentions {
+			if !seen[m] {
+				seen[m] = true
+				d.Mentions = append(d.Mentions, m)
+			}
+		}
+		if a.Time.After(d.Time) {
+			d.Time = a.Time
+		}
+	}
+	return d
+}
+
+// digestGroup 汇总消息中的一个分组（namespace/workload/executable）
+type digestGroup struct {
+	namespace  string
+	workload   string
+	executable string
+	count      int
+	links      []string
+}
+
+// buildDigest 按 namespace/workload/executable 分组生成汇总消息
+func buildDigest(alerts []Alert, interval time.Duration) string {
+	groups := make(map[string]*digestGroup)
+	for _, a := range alerts {
+		workload := a.Workload
+		if workload == "" {
+			workload = a.Pod
+		}
+		executable := filepath.Base(a.Executable)
+		if a.Executable == "" {
+			executable = "unknown"
+		}
+		key := a.Namespace + "/" + workload + "/" + executable
+		g, ok := groups[key]
+		if !ok {
+			g = &digestGroup{namespace: a.Namespace, workload: workload, executable: executable}
+			groups[key] = g
+		}
+		g.count++
+		if a.URL != "" && len(g.links) < digestLinksPerGroup {
+			g.links = append(g.links, a.URL)
+		}
+	}
+
+	sorted := make([]*digestGroup, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].namespace+sorted[i].workload < sorted[j].namespace+sorted[j].workload
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🚨 CoreDog 告警汇总：过去 %v 内共 %d 个 core dump（超出通知限流）\n", interval, len(alerts))
+	for _, g := range sorted {
+		fmt.Fprintf(&b, "\n📦 %s/%s  ⚙️ %s  × %d\n", g.namespace, g.workload, g.executable, g.count)
+		for _, link := range g.links {
+			fmt.Fprintf(&b, "  📥 %s\n", link)
+		}
+		if more := g.count - len(g.links); more > 0 && len(g.links) > 0 {
+			fmt.Fprintf(&b, "  … 另有 %d 个\n", more)
+		}
+	}
+	return b.String()
+}
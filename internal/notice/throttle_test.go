@@ -0,0 +1,155 @@
+package notice
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+)
+
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []string
+	alerts   []Alert // NoticeAlert 收到的结构化告警
+}
+
+func (r *recordingNotifier) Notice(content string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, content)
+}
+
+func (r *recordingNotifier) NoticeAlert(content string, alert Alert) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, content)
+	r.alerts = append(r.alerts, alert)
+}
+
+func (r *recordingNotifier) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.messages...)
+}
+
+func TestDispatcherRateLimitDigest(t *testing.T) {
+	rec := &recordingNotifier{}
+	d := NewDispatcher()
+	d.newNotifier = func(ch cfgpkg.NoticeChannel) (Notifier, error) { return rec, nil }
+
+	ch := cfgpkg.NoticeChannel{
+		Name: "platform",
+		Chan: "slack",
+		// 每分钟 1 条、突发 2 条；digest 周期足够长，由 Close 触发汇总
+		RateLimit: cfgpkg.NoticeRateLimit{PerMinute: 1, Burst: 2, DigestIntervalSeconds: 3600},
+	}
+
+	alerts := []Alert{
+		{Namespace: "prod", Workload: "api", Executable: "/app/api", URL: "u1"},
+		{Namespace: "prod", Workload: "api", Executable: "/app/api", URL: "u2"},
+		{Namespace: "prod", Workload: "api", Executable: "/app/api", URL: "u3"},
+		{Namespace: "prod", Workload: "api", Executable: "/app/api", URL: "u4"},
+		{Namespace: "prod", Workload: "api", Executable: "/app/api", URL: "u5"},
+		{Namespace: "prod", Workload: "api", Executable: "/app/api", URL: "u6"},
+		{Namespace: "batch", Pod: "job-1", Executable: "/bin/worker", URL: "u7"},
+	}
+	for _, a := range alerts {
+		d.Dispatch(ch, "core:"+a.URL, a)
+	}
+
+	if got := rec.snapshot(); len(got) != 2 {
+		t.Fatalf("expected 2 direct messages within burst, got %d: %v", len(got), got)
+	}
+
+	d.Close()
+	got := rec.snapshot()
+	if len(got) != 3 {
+		t.Fatalf("expected 1 digest after close, got %d messages", len(got))
+	}
+
+	digest := got[2]
+	for _, want := range []string{"共 5 个 core dump", "prod/api  ⚙️ api  × 4", "u3", "u5", "另有 1 个", "batch/job-1  ⚙️ worker  × 1", "u7"} {
+		if !strings.Contains(digest, want) {
+			t.Errorf("digest missing %q:\n%s", want, digest)
+		}
+	}
+	if strings.Contains(digest, "u6") {
+		t.Errorf("digest should show at most %d links per group:\n%s", digestLinksPerGroup, digest)
+	}
+}
+
+func TestDispatcherWithoutRateLimit(t *testing.T) {
+	rec := &recordingNotifier{}
+	d := NewDispatcher()
+	d.newNotifier = func(ch cfgpkg.NoticeChannel) (Notifier, error) { return rec, nil }
+	defer d.Close()
+
+	for i := 0; i < 10; i++ {
+		d.Dispatch(cfgpkg.NoticeChannel{Chan: "slack"}, "msg", Alert{})
+	}
+	if got := rec.snapshot(); len(got) != 10 {
+		t.Errorf("expected 10 messages without rate limit, got %d", len(got))
+	}
+}
+
+// Close 之后的告警直接发送，不能留在不会再发送的汇总队列中
+func TestDispatcherAfterClose(t *testing.T) {
+	rec := &recordingNotifier{}
+	d := NewDispatcher()
+	d.newNotifier = func(ch cfgpkg.NoticeChannel) (Notifier, error) { return rec, nil }
+
+	limited := cfgpkg.NoticeRateLimit{PerMinute: 1, Burst: 1, DigestIntervalSeconds: 3600}
+	existing := cfgpkg.NoticeChannel{Name: "existing", Chan: "slack", RateLimit: limited}
+	d.Dispatch(existing, "first", Alert{URL: "u1"})
+	d.Close()
+
+	// 已有限流状态的渠道超出限流，以及 Close 之后才出现的渠道
+	d.Dispatch(existing, "second", Alert{URL: "u2"})
+	d.Dispatch(cfgpkg.NoticeChannel{Name: "late", Chan: "slack", RateLimit: limited}, "third", Alert{URL: "u3"})
+
+	got := rec.snapshot()
+	want := []string{"first", "second", "third"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("messages after close = %q, want %q", got, want)
+	}
+}
+
+func TestDigestAlert(t *testing.T) {
+	t1 := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+	d := digestAlert([]Alert{
+		{Cluster: "prod", Namespace: "payments", Workload: "api", Pod: "api-1", Signal: "SIGSEGV", URL: "u1", Mentions: []string{"alice"}, Time: t1},
+		{Cluster: "prod", Namespace: "payments", Workload: "worker", Pod: "worker-1", Signal: "SIGSEGV", URL: "u2", Mentions: []string{"bob", "alice"}, Time: t1.Add(time.Minute)},
+	})
+	if d.Cluster != "prod" || d.Namespace != "payments" || d.Signal != "SIGSEGV" {
+		t.Errorf("common fields should be kept: %+v", d)
+	}
+	if d.Workload != "" || d.Pod != "" || d.URL != "" {
+		t.Errorf("differing and per-core fields should be empty: %+v", d)
+	}
+	if strings.Join(d.Mentions, ",") != "alice,bob" || !d.Time.Equal(t1.Add(time.Minute)) {
+		t.Errorf("mentions/time = %v/%v", d.Mentions, d.Time)
+	}
+}
+
+func TestDispatcherDigestKeepsMentions(t *testing.T) {
+	rec := &recordingNotifier{}
+	d := NewDispatcher()
+	d.newNotifier = func(ch cfgpkg.NoticeChannel) (Notifier, error) { return rec, nil }
+	ch := cfgpkg.NoticeChannel{Name: "team", Chan: "wechat",
+		RateLimit: cfgpkg.NoticeRateLimit{PerMinute: 1, Burst: 1, DigestIntervalSeconds: 3600}}
+	for i := 0; i < 3; i++ {
+		d.Dispatch(ch, "core", Alert{Namespace: "payments", Mentions: []string{"alice"}})
+	}
+	d.Close()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.alerts) != 2 {
+		t.Fatalf("expected 1 direct alert and 1 digest, got %d", len(rec.alerts))
+	}
+	if digest := rec.alerts[1]; digest.Namespace != "payments" || strings.Join(digest.Mentions, ",") != "alice" {
+		t.Errorf("digest alert = %+v", digest)
+	}
+}
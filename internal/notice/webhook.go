@@ -0,0 +1,105 @@
+package notice
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultBodyTemplate 与 webhook server 以往对未知渠道使用的 {"content": ...} 格式保持一致
+const defaultBodyTemplate = `{"content": "{content}"}`
+
+// GenericWebhookMsg 通用 HTTP webhook 通知
+// 请求方法、请求头和 JSON 请求体均可配置
+type GenericWebhookMsg struct {
+	webhookurl     string
+	method         string
+	headers        map[string]string
+	headersFromEnv map[string]string
+	bodyTemplate   string
+	client         *http.Client
+}
+
+func NewGenericWebhookMsg(webhookurl, method string, headers, headersFromEnv map[string]string, bodyTemplate string) Notifier {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		method = http.MethodPost
+	}
+	if strings.TrimSpace(bodyTemplate) == "" {
+		bodyTemplate = defaultBodyTemplate
+	}
+	return GenericWebhookMsg{
+		webhookurl:     webhookurl,
+		method:         method,
+		headers:        headers,
+		headersFromEnv: headersFromEnv,
+		bodyTemplate:   bodyTemplate,
+		client:         &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// jsonEscape 返回可直接嵌入 JSON 字符串字面量中的内容（不含两侧引号）
+func jsonEscape(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
+
+// renderBody 渲染请求体模板
+// {content} 替换为 JSON 转义后的消息内容，{mentions} 替换为逗号分隔的 @ 用户列表
+func (gm GenericWebhookMsg) renderBody(content string, mentions []string) string {
+	body := strings.ReplaceAll(gm.bodyTemplate, "{content}", jsonEscape(content))
+	return strings.ReplaceAll(body, "{mentions}", jsonEscape(strings.Join(mentions, ",")))
+}
+
+// setHeaders 设置自定义请求头，headersFromEnv 中的值从环境变量读取
+func setHeaders(req *http.Request, headers, headersFromEnv map[string]string) {
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	for header, env := range headersFromEnv {
+		v := os.Getenv(env)
+		if v == "" {
+			logrus.Warnf("env %s for webhook header %s is empty", env, header)
+			continue
+		}
+		req.Header.Set(header, v)
+	}
+}
+
+func (gm GenericWebhookMsg) Notice(content string) {
+	logSendError(gm.send(content, nil))
+}
+
+func (gm GenericWebhookMsg) Deliver(content string) error {
+	return gm.send(content, nil)
+}
+
+func (gm GenericWebhookMsg) NoticeAlert(content string, alert Alert) {
+	logSendError(gm.send(content, alert.Mentions))
+}
+
+func (gm GenericWebhookMsg) send(content string, mentions []string) error {
+	req, err := http.NewRequest(gm.method, gm.webhookurl, bytes.NewBufferString(gm.renderBody(content, mentions)))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	setHeaders(req, gm.headers, gm.headersFromEnv)
+
+	resp, err := gm.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return checkStatus("webhook "+gm.webhookurl, resp.StatusCode, string(body))
+}
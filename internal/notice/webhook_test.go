@@ -0,0 +1,104 @@
+package notice
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// capturedRequest fake webhook 收到的请求
+type capturedRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// newCaptureServer 记录收到的请求并返回 status
+func newCaptureServer(t *testing.T, status int) (*httptest.Server, chan capturedRequest) {
+	t.Helper()
+	reqs := make(chan capturedRequest, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs <- capturedRequest{method: r.Method, path: r.URL.Path, header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, reqs
+}
+
+func TestJSONEscape(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain":          "plain",
+		`say "hi"`:       `say \"hi\"`,
+		"line1\nline2\t": `line1\nline2\t`,
+		`C:\core`:        `C:\\core`,
+		"<a>&":           `\u003ca\u003e\u0026`,
+	} {
+		if got := jsonEscape(in); got != want {
+			t.Errorf("jsonEscape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGenericWebhookRenderBody(t *testing.T) {
+	gm := NewGenericWebhookMsg("http://example", "", nil, nil, "").(GenericWebhookMsg)
+	body := gm.renderBody("core \"dumped\"\nhere", nil)
+	var v map[string]string
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		t.Fatalf("default body is not valid JSON: %v (%s)", err, body)
+	}
+	if v["content"] != "core \"dumped\"\nhere" {
+		t.Errorf("content = %q", v["content"])
+	}
+
+	gm = NewGenericWebhookMsg("http://example", "put", nil, nil,
+		`{"text": "{content}", "at": "{mentions}", "again": "{content}"}`).(GenericWebhookMsg)
+	if gm.method != http.MethodPut {
+		t.Errorf("method = %s", gm.method)
+	}
+	body = gm.renderBody("a\\b", []string{"alice", `b"ob`})
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		t.Fatalf("body is not valid JSON: %v (%s)", err, body)
+	}
+	if v["text"] != `a\b` || v["again"] != `a\b` || v["at"] != `alice,b"ob` {
+		t.Errorf("rendered %+v", v)
+	}
+}
+
+func TestGenericWebhookSend(t *testing.T) {
+	server, reqs := newCaptureServer(t, http.StatusOK)
+	t.Setenv("COREDOG_TEST_TOKEN", "secret")
+
+	gm := NewGenericWebhookMsg(server.URL+"/hook", "PATCH",
+		map[string]string{"X-Static": "1"},
+		map[string]string{"Authorization": "COREDOG_TEST_TOKEN", "X-Missing": "COREDOG_TEST_UNSET"}, "")
+	if err := Deliver(gm, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	req := <-reqs
+	if req.method != http.MethodPatch || req.path != "/hook" {
+		t.Errorf("request %s %s", req.method, req.path)
+	}
+	if req.header.Get("Authorization") != "secret" || req.header.Get("X-Static") != "1" {
+		t.Errorf("headers not set: %v", req.header)
+	}
+	if _, ok := req.header["X-Missing"]; ok {
+		t.Error("header from empty env should be skipped")
+	}
+	if req.header.Get("Content-Type") != "application/json" {
+		t.Errorf("content type = %q", req.header.Get("Content-Type"))
+	}
+	if string(req.body) != `{"content": "hello"}` {
+		t.Errorf("body = %s", req.body)
+	}
+
+	failing, _ := newCaptureServer(t, http.StatusBadGateway)
+	err := Deliver(NewGenericWebhookMsg(failing.URL, "", nil, nil, ""), "hello")
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("expected status error, got %v", err)
+	}
+}
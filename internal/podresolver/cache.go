@@ -0,0 +1,262 @@
+package podresolver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DomineCore/coredog/internal/kube"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// AnnotationAdmissionUID webhook 注入时写入的 admission UID，旧路径格式中以它作为目录名
+	AnnotationAdmissionUID = "coredog.io/admission-uid"
+	// AnnotationPodName webhook 注入时使用的 Pod 目录名：Pod 名称，注入时名称为空（generateName）则为 pod-<admission UID 前 8 位>
+	AnnotationPodName = "coredog.io/pod-name"
+
+	indexAdmissionUID = "admission-uid"
+
+	defaultTombstoneTTL = 10 * time.Minute
+)
+
+// tombstone 已删除的 Pod，在 TTL 内仍可被解析
+type tombstone struct {
+	pod       *v1.Pod
+	deletedAt time.Time
+}
+
+// PodCache 基于 informer 的本节点 Pod 缓存
+// 只 watch spec.nodeName 为本节点的 Pod；Pod 删除后保留 TTL 时间，
+// 使崩溃后立即被删除的 Pod 产生的 core 文件仍能解析到 Pod 信息
+type PodCache struct {
+	factory  informers.SharedInformerFactory
+	informer cache.SharedIndexInformer
+	ttl      time.Duration
+
+	mu         sync.Mutex
+	tombstones map[string]tombstone // key: namespace/name
+	now        func() time.Time
+}
+
+// NewPodCache 创建 nodeName 节点上的 Pod 缓存，需调用 Start 后才能使用
+func NewPodCache(client kubernetes.Interface, nodeName string, ttl time.Duration) (*PodCache, error) {
+	if ttl <= 0 {
+		ttl = defaultTombstoneTTL
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+		}))
+	informer := factory.Core().V1().Pods().Informer()
+
+	err := informer.AddIndexers(cache.Indexers{
+		indexAdmissionUID: func(obj interface{}) ([]string, error) {
+			pod, ok := obj.(*v1.Pod)
+			if !ok {
+				return nil, nil
+			}
+			if uid := pod.Annotations[AnnotationAdmissionUID]; uid != "" {
+				return []string{uid}, nil
+			}
+			return nil, nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add pod indexers: %w", err)
+	}
+
+	c := &PodCache{
+		factory:    factory,
+		informer:   informer,
+		ttl:        ttl,
+		tombstones: make(map[string]tombstone),
+		now:        time.Now,
+	}
+	_, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: c.onDelete,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add pod event handler: %w", err)
+	}
+	return c, nil
+}
+
+// Start 启动 informer 并等待首次同步完成
+func (c *PodCache) Start(stop <-chan struct{}) error {
+	c.factory.Start(stop)
+	if !cache.WaitForCacheSync(stop, c.informer.HasSynced) {
+		return fmt.Errorf("pod cache did not sync")
+	}
+	return nil
+}
+
+func (c *PodCache) onDelete(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		// watch 断开期间被删除的 Pod
+		d, isTombstone := obj.(cache.DeletedFinalStateUnknown)
+		if !isTombstone {
+			return
+		}
+		if pod, ok = d.Obj.(*v1.Pod); !ok {
+			return
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.tombstones[pod.Namespace+"/"+pod.Name] = tombstone{pod: pod, deletedAt: now}
+	c.expireLocked(now)
+	logrus.Debugf("pod %s/%s deleted, keeping it for %v", pod.Namespace, pod.Name, c.ttl)
+}
+
+// expireLocked 清理超过 TTL 的已删除 Pod，调用方需持有锁
+func (c *PodCache) expireLocked(now time.Time) {
+	for key, t := range c.tombstones {
+		if now.Sub(t.deletedAt) > c.ttl {
+			delete(c.tombstones, key)
+		}
+	}
+}
+
+// Get 按 namespace 和名称查找 Pod，包括 TTL 内已删除的 Pod
+func (c *PodCache) Get(namespace, name string) (*v1.Pod, bool) {
+	obj, exists, err := c.informer.GetIndexer().GetByKey(namespace + "/" + name)
+	if err == nil && exists {
+		if pod, ok := obj.(*v1.Pod); ok {
+			return pod, true
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expireLocked(c.now())
+	if t, ok := c.tombstones[namespace+"/"+name]; ok {
+		return t.pod, true
+	}
+	return nil, false
+}
+
+// ByAdmissionUID 按 coredog.io/admission-uid annotation 查找 Pod，包括 TTL 内已删除的 Pod
+func (c *PodCache) ByAdmissionUID(namespace, admissionUID string) (*v1.Pod, bool) {
+	objs, err := c.informer.GetIndexer().ByIndex(indexAdmissionUID, admissionUID)
+	if err == nil {
+		for _, obj := range objs {
+			if pod, ok := obj.(*v1.Pod); ok && pod.Namespace == namespace {
+				return pod, true
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expireLocked(c.now())
+	for _, t := range c.tombstones {
+		if t.pod.Namespace == namespace && t.pod.Annotations[AnnotationAdmissionUID] == admissionUID {
+			return t.pod, true
+		}
+	}
+	return nil, false
+}
+
+// OwnsDir 本节点是否有 Pod（包括 TTL 内已删除的 Pod）使用 namespace 下名为 dir 的 core 目录
+// 目录名为 coredog.io/pod-name annotation、Pod 名称，或旧路径格式中的 admission UID
+func (c *PodCache) OwnsDir(namespace, dir string) bool {
+	owns := func(pod *v1.Pod) bool {
+		return pod.Name == dir || pod.Annotations[AnnotationPodName] == dir || pod.Annotations[AnnotationAdmissionUID] == dir
+	}
+	objs, err := c.informer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		// 无法确认时视为仍在使用
+		return true
+	}
+	for _, obj := range objs {
+		if pod, ok := obj.(*v1.Pod); ok && owns(pod) {
+			return true
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expireLocked(c.now())
+	for _, t := range c.tombstones {
+		if t.pod.Namespace == namespace && owns(t.pod) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	podCacheMu sync.RWMutex
+	podCache   *PodCache
+)
+
+// SetPodCache 设置 Resolve 使用的 Pod 缓存，为 nil 时直接查询 Kubernetes API
+func SetPodCache(c *PodCache) {
+	podCacheMu.Lock()
+	defer podCacheMu.Unlock()
+	podCache = c
+}
+
+// GetPodCache 返回已同步的 Pod 缓存，未启用或尚未同步完成时返回 nil
+func GetPodCache() *PodCache {
+	podCacheMu.RLock()
+	defer podCacheMu.RUnlock()
+	return podCache
+}
+
+// getPod 查找 Pod：优先使用缓存，缓存未命中（例如 Pod 刚创建、informer 尚未收到）时查询 API
+func getPod(namespace, name string) (*v1.Pod, error) {
+	if c := GetPodCache(); c != nil {
+		if pod, ok := c.Get(namespace, name); ok {
+			return pod, nil
+		}
+		logrus.Debugf("pod %s/%s not in cache, querying API", namespace, name)
+	}
+
+	client, err := kube.Client()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// findPodByAdmissionUID 按 admission UID 查找 Pod
+// 有缓存时只查缓存（已包含本节点全部 Pod 及最近删除的 Pod），否则列出 namespace 中的 Pod 逐个匹配
+func findPodByAdmissionUID(namespace, admissionUID string) (*v1.Pod, error) {
+	if c := GetPodCache(); c != nil {
+		if pod, ok := c.ByAdmissionUID(namespace, admissionUID); ok {
+			return pod, nil
+		}
+		return nil, nil
+	}
+
+	client, err := kube.Client()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	podList, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in namespace %s: %w", namespace, err)
+	}
+	for i := range podList.Items {
+		if podList.Items[i].Annotations[AnnotationAdmissionUID] == admissionUID {
+			return &podList.Items[i], nil
+		}
+	}
+	return nil, nil
+}
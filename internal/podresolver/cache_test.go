@@ -0,0 +1,95 @@
+package podresolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testAdmissionUID = "0a1b2c3d-0000-0000-0000-000000000001"
+
+func TestPodCacheTombstone(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "app-0",
+			Namespace:   "default",
+			UID:         "9f8e7d6c-0000-0000-0000-000000000001",
+			Annotations: map[string]string{AnnotationAdmissionUID: testAdmissionUID, AnnotationPodName: "pod-0a1b2c3d"},
+		},
+		Spec: v1.PodSpec{NodeName: "node-1"},
+	}
+	client := fake.NewSimpleClientset(pod)
+
+	c, err := NewPodCache(client, "node-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	stop := make(chan struct{})
+	defer close(stop)
+	if err := c.Start(stop); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, ok := c.Get("default", "app-0"); !ok || got.UID != pod.UID {
+		t.Fatalf("pod not found in cache")
+	}
+	if got, ok := c.ByAdmissionUID("default", testAdmissionUID); !ok || got.Name != "app-0" {
+		t.Fatalf("pod not found by admission uid")
+	}
+	if _, ok := c.ByAdmissionUID("other", testAdmissionUID); ok {
+		t.Error("admission uid matched pod in another namespace")
+	}
+
+	for _, dir := range []string{"app-0", "pod-0a1b2c3d", testAdmissionUID} {
+		if !c.OwnsDir("default", dir) {
+			t.Errorf("expected pod to own dir %s", dir)
+		}
+	}
+	if c.OwnsDir("default", "app-1") || c.OwnsDir("other", "app-0") {
+		t.Error("unexpected owner of dir")
+	}
+
+	if err := client.CoreV1().Pods("default").Delete(context.Background(), "app-0", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		_, deleted := c.tombstones["default/app-0"]
+		c.mu.Unlock()
+		if deleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for pod deletion")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// 删除后 TTL 内仍可解析
+	if _, ok := c.Get("default", "app-0"); !ok {
+		t.Error("deleted pod not kept as tombstone")
+	}
+	if _, ok := c.ByAdmissionUID("default", testAdmissionUID); !ok {
+		t.Error("deleted pod not found by admission uid")
+	}
+
+	if !c.OwnsDir("default", "pod-0a1b2c3d") {
+		t.Error("deleted pod should own its dir within ttl")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("default", "app-0"); ok {
+		t.Error("tombstone not expired after ttl")
+	}
+	if c.OwnsDir("default", "pod-0a1b2c3d") {
+		t.Error("dir still owned after ttl")
+	}
+}
@@ -0,0 +1,30 @@
+package podresolver
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// containerIDPattern 匹配 cgroup 路径中的容器 ID（64 位十六进制）
+// 覆盖常见格式：
+//   - cgroup v1 cgroupfs：/kubepods/burstable/pod<uid>/<id>
+//   - systemd 驱动：/kubepods.slice/.../cri-containerd-<id>.scope、crio-<id>.scope、docker-<id>.scope
+var containerIDPattern = regexp.MustCompile(`(?:^|[/-])([0-9a-f]{64})(?:\.scope)?$`)
+
+// parseCgroupContainerID 从 /proc/<pid>/cgroup 内容中提取容器 ID
+func parseCgroupContainerID(data []byte) (string, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// 格式：hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if m := containerIDPattern.FindStringSubmatch(parts[2]); m != nil {
+			return m[1], true
+		}
+	}
+	return "", false
+}
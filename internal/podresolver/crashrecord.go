@@ -0,0 +1,103 @@
+package podresolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CrashRecord core_pattern 管道处理程序（coredog core-helper）在崩溃时记录的进程信息
+// 内核在宿主机的 namespace 中以 root 运行管道处理程序，core 写完之前崩溃进程仍在 /proc 中，
+// 因此此时读取的 /proc/<%P>/cgroup 是可靠的；watcher 之后按 core 文件名查找记录
+type CrashRecord struct {
+	CoreFile    string    `json:"core_file"`              // core 文件名
+	HostPID     int       `json:"host_pid"`               // 宿主机 PID namespace 中的进程号（%P）
+	Comm        string    `json:"comm"`                   // 进程名（%e）
+	Cgroup      string    `json:"cgroup"`                 // /proc/<pid>/cgroup 原始内容
+	ContainerID string    `json:"container_id,omitempty"` // 从 cgroup 解析出的容器 ID，宿主机进程为空
+	Time        time.Time `json:"time"`
+}
+
+var (
+	crashRecordMu  sync.RWMutex
+	crashRecordDir string
+)
+
+// SetCrashRecordDir 设置 watcher 查找崩溃记录的目录，为空时不使用崩溃记录
+func SetCrashRecordDir(dir string) {
+	crashRecordMu.Lock()
+	defer crashRecordMu.Unlock()
+	crashRecordDir = dir
+}
+
+func getCrashRecordDir() string {
+	crashRecordMu.RLock()
+	defer crashRecordMu.RUnlock()
+	return crashRecordDir
+}
+
+// crashRecordPath 记录文件路径：<dir>/<core 文件名>.json
+func crashRecordPath(dir, coreFile string) string {
+	return filepath.Join(dir, filepath.Base(coreFile)+".json")
+}
+
+// RecordCrash 读取崩溃进程的 cgroup，在 dir 中写入崩溃记录，必须在 core 写完之前调用
+// procRoot 为宿主机 /proc；进程不在容器中时 ContainerID 为空
+func RecordCrash(procRoot, dir, coreFile string, pid int, comm string) (CrashRecord, error) {
+	rec := CrashRecord{CoreFile: filepath.Base(coreFile), HostPID: pid, Comm: comm, Time: time.Now().UTC()}
+	data, err := os.ReadFile(filepath.Join(procRoot, fmt.Sprint(pid), "cgroup"))
+	if err != nil {
+		return rec, fmt.Errorf("failed to read cgroup of process %d: %w", pid, err)
+	}
+	rec.Cgroup = strings.TrimSpace(string(data))
+	rec.ContainerID, _ = parseCgroupContainerID(data)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return rec, err
+	}
+	content, err := json.Marshal(rec)
+	if err != nil {
+		return rec, err
+	}
+	path := crashRecordPath(dir, coreFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return rec, err
+	}
+	return rec, os.Rename(tmp, path)
+}
+
+// ReadCrashRecord 查找 core 文件对应的崩溃记录，未设置记录目录或没有记录时返回 false
+func ReadCrashRecord(corefilePath string) (CrashRecord, bool) {
+	dir := getCrashRecordDir()
+	if dir == "" {
+		return CrashRecord{}, false
+	}
+	data, err := os.ReadFile(crashRecordPath(dir, corefilePath))
+	if err != nil {
+		return CrashRecord{}, false
+	}
+	var rec CrashRecord
+	if err := json.Unmarshal(data, &rec); err != nil || rec.CoreFile != filepath.Base(corefilePath) {
+		return CrashRecord{}, false
+	}
+	return rec, true
+}
+
+// PruneCrashRecords 删除 dir 中早于 maxAge 的崩溃记录
+func PruneCrashRecords(dir string, maxAge time.Duration) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-maxAge)
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && !e.IsDir() && info.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
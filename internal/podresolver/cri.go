@@ -0,0 +1,117 @@
+package podresolver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// kubelet 写在容器上的标签
+const (
+	criLabelPodName       = "io.kubernetes.pod.name"
+	criLabelPodNamespace  = "io.kubernetes.pod.namespace"
+	criLabelPodUID        = "io.kubernetes.pod.uid"
+	criLabelContainerName = "io.kubernetes.container.name"
+)
+
+// PIDResolver 通过容器运行时解析 Pod
+// 崩溃记录中的容器 ID（由 core-helper 在崩溃时从 /proc/<%P>/cgroup 得到）→ CRI ContainerStatus（containerd、CRI-O）
+// 结果来自容器运行时，不依赖 core 文件所在的目录结构；procRoot 用于访问运行中容器的文件系统
+type PIDResolver struct {
+	procRoot string
+	conn     *grpc.ClientConn
+	runtime  runtimeapi.RuntimeServiceClient
+}
+
+// NewPIDResolver 连接 CRI 运行时 socket，endpoint 形如 unix:///run/containerd/containerd.sock
+// procRoot 为宿主机 /proc 在容器内的挂载点
+// grpc.Dial 不会立即建立连接，因此先检查 socket 并调用 Version 确认运行时可用
+func NewPIDResolver(endpoint, procRoot string) (*PIDResolver, error) {
+	if socket, ok := strings.CutPrefix(endpoint, "unix://"); ok {
+		if _, err := os.Stat(socket); err != nil {
+			return nil, fmt.Errorf("CRI runtime socket %s is not available: %w", socket, err)
+		}
+	}
+	conn, err := grpc.Dial(endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to CRI runtime %s: %w", endpoint, err)
+	}
+	client := runtimeapi.NewRuntimeServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	version, err := client.Version(ctx, &runtimeapi.VersionRequest{})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("CRI runtime %s is not responding: %w", endpoint, err)
+	}
+	logrus.Infof("resolving pods through CRI runtime %s (%s %s, proc: %s)",
+		endpoint, version.GetRuntimeName(), version.GetRuntimeVersion(), procRoot)
+	return &PIDResolver{
+		procRoot: procRoot,
+		conn:     conn,
+		runtime:  client,
+	}, nil
+}
+
+// ResolveContainer 从 CRI 查询容器所属的 Pod
+func (r *PIDResolver) ResolveContainer(ctx context.Context, containerID string) (PodInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	resp, err := r.runtime.ContainerStatus(ctx, &runtimeapi.ContainerStatusRequest{ContainerId: containerID})
+	if err != nil {
+		return PodInfo{}, fmt.Errorf("failed to get status of container %s: %w", containerID, err)
+	}
+	status := resp.GetStatus()
+	labels := status.GetLabels()
+	info := PodInfo{
+		Name:          labels[criLabelPodName],
+		Namespace:     labels[criLabelPodNamespace],
+		UID:           labels[criLabelPodUID],
+		ContainerName: labels[criLabelContainerName],
+		Image:         status.GetImage().GetImage(),
+	}
+	if info.Name == "" || info.Namespace == "" {
+		return PodInfo{}, fmt.Errorf("container %s is not managed by kubelet", containerID)
+	}
+	return info, nil
+}
+
+// Close 关闭与 CRI 运行时的连接
+func (r *PIDResolver) Close() error {
+	return r.conn.Close()
+}
+
+// ResolveCrash 优先通过 core-helper 记录的容器 ID 解析 Pod，失败时退回到按 core 文件路径解析
+// r 为 nil 或没有崩溃记录（core 不是由 core-helper 写入，或进程不在容器中）时直接按路径解析
+func ResolveCrash(r *PIDResolver, corefilePath string, enableLookup bool) PodInfo {
+	rec, ok := ReadCrashRecord(corefilePath)
+	if r == nil || !ok || rec.ContainerID == "" {
+		return Resolve(corefilePath, enableLookup)
+	}
+
+	info, err := r.ResolveContainer(context.Background(), rec.ContainerID)
+	if err != nil {
+		logrus.Debugf("failed to resolve pod of container %s: %v, falling back to path", rec.ContainerID, err)
+		return Resolve(corefilePath, enableLookup)
+	}
+	logrus.Infof("resolved pod from crash record (pid %d, container %.12s): %s/%s, container: %s",
+		rec.HostPID, rec.ContainerID, info.Namespace, info.Name, info.ContainerName)
+	info.Match = ContainerMatch{Method: MatchCgroup, Confidence: 1}
+
+	// CRI 不提供 NodeIP、labels 等信息，仍从 Kubernetes 补充
+	if enableLookup {
+		if enriched := enrichPodInfoFromKubernetes(info); enriched.UID != "" {
+			return enriched
+		}
+	}
+	return info
+}
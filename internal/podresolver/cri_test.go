@@ -0,0 +1,216 @@
+package podresolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+const testContainerID = "3f4e5d6c7b8a99887766554433221100ffeeddccbbaa00112233445566778899"
+
+func TestParseCgroupContainerID(t *testing.T) {
+	tests := []struct {
+		name   string
+		cgroup string
+		want   string
+	}{
+		{
+			name:   "cgroup v2 systemd containerd",
+			cgroup: "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-" + testContainerID + ".scope\n",
+			want:   testContainerID,
+		},
+		{
+			name:   "cgroup v2 crio",
+			cgroup: "0::/kubepods.slice/kubepods-pod1234.slice/crio-" + testContainerID + ".scope\n",
+			want:   testContainerID,
+		},
+		{
+			name: "cgroup v1 cgroupfs",
+			cgroup: "12:memory:/kubepods/burstable/pod0a1b2c3d-0000-0000-0000-000000000001/" + testContainerID + "\n" +
+				"11:cpu,cpuacct:/kubepods/burstable/pod0a1b2c3d-0000-0000-0000-000000000001/" + testContainerID + "\n",
+			want: testContainerID,
+		},
+		{
+			name:   "host process",
+			cgroup: "0::/system.slice/sshd.service\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseCgroupContainerID([]byte(tt.cgroup))
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("got (%q, %v), want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+// fakeRuntime 只实现 ContainerStatus 的 CRI 运行时
+type fakeRuntime struct {
+	runtimeapi.UnimplementedRuntimeServiceServer
+	containers map[string]*runtimeapi.ContainerStatus
+	pids       map[string]int // verbose 状态中返回的容器主进程 PID
+}
+
+func (f *fakeRuntime) Version(context.Context, *runtimeapi.VersionRequest) (*runtimeapi.VersionResponse, error) {
+	return &runtimeapi.VersionResponse{RuntimeName: "fake", RuntimeVersion: "v1"}, nil
+}
+
+func (f *fakeRuntime) ContainerStatus(_ context.Context, req *runtimeapi.ContainerStatusRequest) (*runtimeapi.ContainerStatusResponse, error) {
+	if pid, ok := f.pids[req.ContainerId]; ok && req.Verbose {
+		return &runtimeapi.ContainerStatusResponse{
+			Status: &runtimeapi.ContainerStatus{Id: req.ContainerId},
+			Info:   map[string]string{"info": fmt.Sprintf(`{"pid":%d}`, pid)},
+		}, nil
+	}
+	s, ok := f.containers[req.ContainerId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "container %s not found", req.ContainerId)
+	}
+	return &runtimeapi.ContainerStatusResponse{Status: s}, nil
+}
+
+// startFakeCRI 在临时 unix socket 上启动假的 CRI 服务，返回 endpoint
+func startFakeCRI(t *testing.T, runtime *fakeRuntime) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "cri.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	runtimeapi.RegisterRuntimeServiceServer(srv, runtime)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return "unix://" + socket
+}
+
+// writeProc 在临时目录中生成 <procRoot>/<pid>/cgroup
+func writeProc(t *testing.T, pid, cgroup string) string {
+	t.Helper()
+	procRoot := t.TempDir()
+	dir := filepath.Join(procRoot, pid)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup"), []byte(cgroup), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return procRoot
+}
+
+func TestRecordCrash(t *testing.T) {
+	procRoot := writeProc(t, "4242", "0::/kubepods.slice/cri-containerd-"+testContainerID+".scope\n")
+	dir := t.TempDir()
+	SetCrashRecordDir(dir)
+	defer SetCrashRecordDir("")
+
+	if _, err := RecordCrash(procRoot, dir, "core.myapp.4242.node-1.1700000000", 4242, "myapp"); err != nil {
+		t.Fatal(err)
+	}
+	rec, ok := ReadCrashRecord("/corefile/core.myapp.4242.node-1.1700000000")
+	if !ok || rec.HostPID != 4242 || rec.Comm != "myapp" || rec.ContainerID != testContainerID {
+		t.Errorf("record = %+v, %v", rec, ok)
+	}
+	if _, ok := ReadCrashRecord("/corefile/core.other.1.node-1.1700000000"); ok {
+		t.Error("unexpected record for another core")
+	}
+
+	// 进程已不存在：仍然返回错误，不写入记录
+	if _, err := RecordCrash(procRoot, dir, "core.gone.1", 1, "gone"); err == nil {
+		t.Error("expected error for missing process")
+	}
+	if _, ok := ReadCrashRecord("core.gone.1"); ok {
+		t.Error("record written for missing process")
+	}
+}
+
+func TestResolveCrash(t *testing.T) {
+	endpoint := startFakeCRI(t, &fakeRuntime{containers: map[string]*runtimeapi.ContainerStatus{
+		testContainerID: {
+			Id: testContainerID,
+			Labels: map[string]string{
+				criLabelPodName:       "app-7d9f8-x2k4p",
+				criLabelPodNamespace:  "payments",
+				criLabelPodUID:        "9f8e7d6c-0000-0000-0000-000000000001",
+				criLabelContainerName: "app",
+			},
+			Image: &runtimeapi.ImageSpec{Image: "registry.example.com/payments/app:v1.2.3"},
+		},
+	}})
+	r, err := NewPIDResolver(endpoint, "/proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	procRoot := writeProc(t, "4242", "0::/kubepods.slice/cri-containerd-"+testContainerID+".scope\n")
+	dir := t.TempDir()
+	SetCrashRecordDir(dir)
+	defer SetCrashRecordDir("")
+	if _, err := RecordCrash(procRoot, dir, "core.myapp.4242", 4242, "myapp"); err != nil {
+		t.Fatal(err)
+	}
+
+	info := ResolveCrash(r, "/corefile/custom/path/core.myapp.4242", false)
+	want := PodInfo{
+		Name:          "app-7d9f8-x2k4p",
+		Namespace:     "payments",
+		UID:           "9f8e7d6c-0000-0000-0000-000000000001",
+		ContainerName: "app",
+		Image:         "registry.example.com/payments/app:v1.2.3",
+	}
+	if info.Name != want.Name || info.Namespace != want.Namespace || info.UID != want.UID ||
+		info.ContainerName != want.ContainerName || info.Image != want.Image || info.Match.Method != MatchCgroup {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+
+	// 没有崩溃记录时按路径解析
+	info = ResolveCrash(r, "/corefile/default/app-0/app/core.other.1", false)
+	if info.Name != "app-0" || info.Match.Method == MatchCgroup {
+		t.Errorf("expected path based resolving, got %+v", info)
+	}
+}
+
+func TestPIDResolverUnknownContainer(t *testing.T) {
+	endpoint := startFakeCRI(t, &fakeRuntime{})
+	r, err := NewPIDResolver(endpoint, "/proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if _, err := r.ResolveContainer(context.Background(), testContainerID); err == nil {
+		t.Error("expected error for container unknown to the runtime")
+	}
+}
+
+func TestNewPIDResolverUnavailable(t *testing.T) {
+	// socket 不存在
+	if _, err := NewPIDResolver("unix://"+filepath.Join(t.TempDir(), "missing.sock"), "/proc"); err == nil {
+		t.Error("expected error for missing socket")
+	}
+
+	// socket 存在但不是 CRI 服务
+	socket := filepath.Join(t.TempDir(), "idle.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	srv := grpc.NewServer()
+	go srv.Serve(lis)
+	defer srv.Stop()
+	if _, err := NewPIDResolver("unix://"+socket, "/proc"); err == nil {
+		t.Error("expected error when the runtime does not answer Version")
+	}
+}
@@ -0,0 +1,209 @@
+package podresolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DomineCore/coredog/internal/coreparser"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// 容器匹配方式，按可信程度从高到低
+const (
+	MatchPath            = "path"             // core 路径中包含容器名
+	MatchCgroup          = "cgroup"           // core-helper 在崩溃时记录的 cgroup → 容器 ID
+	MatchBuildID         = "build-id"         // 容器文件系统中可执行文件的 build-id 与 core 一致
+	MatchSingleContainer = "single-container" // Pod 只有一个容器
+	MatchExecutable      = "executable"       // 只有一个容器的文件系统中存在该可执行文件
+	MatchName            = "name"             // 可执行文件名与容器名或镜像名相同（启发式）
+)
+
+// ContainerMatch core 与 Pod 中容器的匹配结果
+type ContainerMatch struct {
+	Method     string   // 匹配方式，未匹配时为空
+	Confidence float64  // 置信度，0~1
+	Ambiguous  bool     // 无法唯一确定容器，此时 ContainerName 和 Image 为空
+	Candidates []string // 无法唯一确定时的候选容器名
+}
+
+var (
+	pidResolverMu sync.RWMutex
+	pidResolver   *PIDResolver
+)
+
+// SetPIDResolver 设置旧路径格式匹配容器时使用的 CRI 解析器，为 nil 时只能按 Pod 结构和名称匹配
+func SetPIDResolver(r *PIDResolver) {
+	pidResolverMu.Lock()
+	defer pidResolverMu.Unlock()
+	pidResolver = r
+}
+
+func getPIDResolver() *PIDResolver {
+	pidResolverMu.RLock()
+	defer pidResolverMu.RUnlock()
+	return pidResolver
+}
+
+// matchContainer 确定崩溃进程属于 Pod 中的哪个容器
+// 依次尝试：崩溃记录中的容器 ID（containerID，来自 core-helper）；Pod 只有一个容器；在各容器文件系统中比较可执行文件的 build-id；
+// 最后按可执行文件名与容器名/镜像名做启发式匹配
+// 无法唯一确定时返回空的容器名，并在 ContainerMatch 中标记 Ambiguous 和候选容器，不会默认选择第一个容器
+func matchContainer(pod *v1.Pod, proc coreparser.ProcessInfo, executable, containerID string, r *PIDResolver) (string, ContainerMatch) {
+	containers := append(append([]v1.Container{}, pod.Spec.Containers...), pod.Spec.InitContainers...)
+	statuses := append(append([]v1.ContainerStatus{}, pod.Status.ContainerStatuses...), pod.Status.InitContainerStatuses...)
+
+	if containerID != "" {
+		for _, s := range statuses {
+			if trimContainerID(s.ContainerID) == containerID {
+				return s.Name, ContainerMatch{Method: MatchCgroup, Confidence: 1}
+			}
+		}
+		logrus.Debugf("container %.12s from crash record is not in pod %s/%s", containerID, pod.Namespace, pod.Name)
+	}
+
+	if len(containers) == 1 {
+		return containers[0].Name, ContainerMatch{Method: MatchSingleContainer, Confidence: 1}
+	}
+
+	if r != nil && proc.Executable != "" {
+		if name, m, ok := r.matchByFilesystem(statuses, proc); ok {
+			return name, m
+		}
+	}
+
+	if executable == "" {
+		executable = proc.Name
+	}
+	if proc.Executable != "" {
+		executable = filepath.Base(proc.Executable)
+	}
+	if name, m, ok := matchByName(containers, executable); ok {
+		return name, m
+	}
+
+	candidates := make([]string, 0, len(containers))
+	for _, c := range containers {
+		candidates = append(candidates, c.Name)
+	}
+	return "", ContainerMatch{Ambiguous: true, Candidates: candidates}
+}
+
+// matchByName 按可执行文件名匹配容器：与容器名相同优先，其次与镜像名（去掉 registry 和 tag）相同
+// 同一规则命中多个容器时视为无法确定
+func matchByName(containers []v1.Container, executable string) (string, ContainerMatch, bool) {
+	if executable == "" {
+		return "", ContainerMatch{}, false
+	}
+	rules := []struct {
+		confidence float64
+		match      func(c v1.Container) bool
+	}{
+		{0.6, func(c v1.Container) bool { return c.Name == executable }},
+		{0.5, func(c v1.Container) bool { return extractImageName(c.Image) == executable }},
+	}
+	for _, rule := range rules {
+		var matched []string
+		for _, c := range containers {
+			if rule.match(c) {
+				matched = append(matched, c.Name)
+			}
+		}
+		switch {
+		case len(matched) == 1:
+			return matched[0], ContainerMatch{Method: MatchName, Confidence: rule.confidence}, true
+		case len(matched) > 1:
+			return "", ContainerMatch{Method: MatchName, Ambiguous: true, Candidates: matched}, true
+		}
+	}
+	return "", ContainerMatch{}, false
+}
+
+// matchByFilesystem 通过 <procRoot>/<容器进程 pid>/root 访问各运行中容器的文件系统（即运行时挂载的快照），
+// 比较可执行文件的 build-id；core 中没有 build-id 时只检查可执行文件是否存在
+func (r *PIDResolver) matchByFilesystem(statuses []v1.ContainerStatus, proc coreparser.ProcessInfo) (string, ContainerMatch, bool) {
+	var sameBuild, present []string
+	for _, s := range statuses {
+		id := trimContainerID(s.ContainerID)
+		if id == "" || s.State.Running == nil {
+			continue
+		}
+		pid, err := r.containerPID(context.Background(), id)
+		if err != nil {
+			logrus.Debugf("failed to get pid of container %s: %v", s.Name, err)
+			continue
+		}
+		path := filepath.Join(r.procRoot, strconv.Itoa(pid), "root", proc.Executable)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		present = append(present, s.Name)
+		if proc.BuildID == "" {
+			continue
+		}
+		if buildID, err := coreparser.BuildIDOfFile(path); err == nil && buildID == proc.BuildID {
+			sameBuild = append(sameBuild, s.Name)
+		}
+	}
+
+	switch {
+	case len(sameBuild) == 1:
+		return sameBuild[0], ContainerMatch{Method: MatchBuildID, Confidence: 0.95}, true
+	case len(sameBuild) > 1:
+		// 多个容器使用同一个二进制（如同一镜像的多个容器），无法区分
+		return "", ContainerMatch{Method: MatchBuildID, Ambiguous: true, Candidates: sameBuild}, true
+	case len(present) == 1:
+		return present[0], ContainerMatch{Method: MatchExecutable, Confidence: 0.8}, true
+	}
+	return "", ContainerMatch{}, false
+}
+
+// containerPID 通过 CRI verbose 状态获取容器主进程在宿主机上的 PID（containerd 和 CRI-O 都在 info.pid 中返回）
+func (r *PIDResolver) containerPID(ctx context.Context, containerID string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	resp, err := r.runtime.ContainerStatus(ctx, &runtimeapi.ContainerStatusRequest{ContainerId: containerID, Verbose: true})
+	if err != nil {
+		return 0, err
+	}
+	var info struct {
+		PID int `json:"pid"`
+	}
+	if err := json.Unmarshal([]byte(resp.GetInfo()["info"]), &info); err != nil {
+		return 0, fmt.Errorf("failed to parse verbose info of container %s: %w", containerID, err)
+	}
+	if info.PID <= 0 {
+		return 0, fmt.Errorf("container %s has no running process", containerID)
+	}
+	return info.PID, nil
+}
+
+// trimContainerID 去掉 containerStatuses[].containerID 的运行时前缀，如 containerd://<id>
+func trimContainerID(id string) string {
+	if i := strings.Index(id, "://"); i >= 0 {
+		return id[i+3:]
+	}
+	return id
+}
+
+// containerImage 返回容器的镜像，name 为空或容器不存在时返回空
+func containerImage(pod *v1.Pod, name string) string {
+	if name == "" {
+		return ""
+	}
+	for _, c := range append(append([]v1.Container{}, pod.Spec.Containers...), pod.Spec.InitContainers...) {
+		if c.Name == name {
+			return c.Image
+		}
+	}
+	return ""
+}
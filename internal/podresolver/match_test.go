@@ -0,0 +1,199 @@
+package podresolver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/DomineCore/coredog/internal/coreparser"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testPod(containers ...v1.Container) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-0", Namespace: "default"},
+		Spec:       v1.PodSpec{Containers: containers},
+	}
+}
+
+func TestMatchContainerWithoutRuntime(t *testing.T) {
+	app := v1.Container{Name: "app", Image: "registry.example.com/team/server:v1"}
+	proxy := v1.Container{Name: "proxy", Image: "envoyproxy/envoy:v1.29"}
+	worker := v1.Container{Name: "worker", Image: "registry.example.com/team/server:v1"}
+
+	tests := []struct {
+		name       string
+		pod        *v1.Pod
+		proc       coreparser.ProcessInfo
+		executable string
+		want       string
+		match      ContainerMatch
+	}{
+		{
+			name:  "single container",
+			pod:   testPod(app),
+			want:  "app",
+			match: ContainerMatch{Method: MatchSingleContainer, Confidence: 1},
+		},
+		{
+			name:       "container name",
+			pod:        testPod(app, proxy),
+			executable: "proxy",
+			want:       "proxy",
+			match:      ContainerMatch{Method: MatchName, Confidence: 0.6},
+		},
+		{
+			name:  "image name from core executable path",
+			pod:   testPod(app, proxy),
+			proc:  coreparser.ProcessInfo{Name: "server", Executable: "/usr/local/bin/envoy"},
+			want:  "proxy",
+			match: ContainerMatch{Method: MatchName, Confidence: 0.5},
+		},
+		{
+			name:       "same image in two containers",
+			pod:        testPod(app, proxy, worker),
+			executable: "server",
+			match:      ContainerMatch{Method: MatchName, Ambiguous: true, Candidates: []string{"app", "worker"}},
+		},
+		{
+			name:       "no match",
+			pod:        testPod(app, proxy),
+			executable: "java",
+			match:      ContainerMatch{Ambiguous: true, Candidates: []string{"app", "proxy"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, m := matchContainer(tt.pod, tt.proc, tt.executable, "", nil)
+			if got != tt.want || !reflect.DeepEqual(m, tt.match) {
+				t.Errorf("got (%q, %+v), want (%q, %+v)", got, m, tt.want, tt.match)
+			}
+		})
+	}
+}
+
+func TestMatchContainerByCgroup(t *testing.T) {
+	pod := testPod(v1.Container{Name: "app"}, v1.Container{Name: "sidecar"})
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{
+		{Name: "app", ContainerID: "containerd://0000000000000000000000000000000000000000000000000000000000000001"},
+		{Name: "sidecar", ContainerID: "containerd://" + testContainerID},
+	}
+
+	// 容器 ID 来自 core-helper 的崩溃记录，不需要 CRI
+	got, m := matchContainer(pod, coreparser.ProcessInfo{PID: 7, Name: "server"}, "", testContainerID, nil)
+	if got != "sidecar" || m.Method != MatchCgroup || m.Confidence != 1 {
+		t.Errorf("got (%q, %+v)", got, m)
+	}
+}
+
+// writeELFWithBuildID 生成只包含 GNU build-id note 的最小 ELF64 文件
+func writeELFWithBuildID(t *testing.T, path, buildID string) {
+	t.Helper()
+	id, err := hex.DecodeString(buildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var note bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&note, le, uint32(4))       // namesz
+	binary.Write(&note, le, uint32(len(id))) // descsz
+	binary.Write(&note, le, uint32(3))       // NT_GNU_BUILD_ID
+	note.WriteString("GNU\x00")
+	note.Write(id)
+
+	var buf bytes.Buffer
+	ident := [16]byte{0x7f, 'E', 'L', 'F', 2, 1, 1}
+	buf.Write(ident[:])
+	for _, v := range []interface{}{
+		uint16(2), uint16(62), uint32(1), uint64(0), uint64(64), uint64(0), uint32(0),
+		uint16(64), uint16(56), uint16(1), uint16(64), uint16(0), uint16(0),
+		// PT_NOTE 程序头
+		uint32(4), uint32(0), uint64(120), uint64(0), uint64(0), uint64(note.Len()), uint64(note.Len()), uint64(4),
+	} {
+		binary.Write(&buf, le, v)
+	}
+	buf.Write(note.Bytes())
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatchContainerByFilesystem(t *testing.T) {
+	const (
+		appID    = "1111111111111111111111111111111111111111111111111111111111111111"
+		workerID = "2222222222222222222222222222222222222222222222222222222222222222"
+		proxyID  = "3333333333333333333333333333333333333333333333333333333333333333"
+		buildA   = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		buildB   = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+	endpoint := startFakeCRI(t, &fakeRuntime{pids: map[string]int{appID: 100, workerID: 200, proxyID: 300}})
+	procRoot := t.TempDir()
+	writeELFWithBuildID(t, filepath.Join(procRoot, "100/root/app/bin/server"), buildA)
+	writeELFWithBuildID(t, filepath.Join(procRoot, "200/root/app/bin/server"), buildB)
+	writeELFWithBuildID(t, filepath.Join(procRoot, "300/root/usr/local/bin/envoy"), buildB)
+
+	r, err := NewPIDResolver(endpoint, procRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	running := v1.ContainerState{Running: &v1.ContainerStateRunning{}}
+	pod := testPod(v1.Container{Name: "app"}, v1.Container{Name: "worker"}, v1.Container{Name: "proxy"})
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{
+		{Name: "app", ContainerID: "containerd://" + appID, State: running},
+		{Name: "worker", ContainerID: "containerd://" + workerID, State: running},
+		{Name: "proxy", ContainerID: "containerd://" + proxyID, State: running},
+	}
+
+	tests := []struct {
+		name  string
+		proc  coreparser.ProcessInfo
+		want  string
+		match ContainerMatch
+	}{
+		{
+			name:  "build-id",
+			proc:  coreparser.ProcessInfo{Name: "server", Executable: "/app/bin/server", BuildID: buildB},
+			want:  "worker",
+			match: ContainerMatch{Method: MatchBuildID, Confidence: 0.95},
+		},
+		{
+			name:  "executable present in one container",
+			proc:  coreparser.ProcessInfo{Name: "envoy", Executable: "/usr/local/bin/envoy"},
+			want:  "proxy",
+			match: ContainerMatch{Method: MatchExecutable, Confidence: 0.8},
+		},
+		{
+			name:  "executable present in several containers without build-id",
+			proc:  coreparser.ProcessInfo{Name: "server", Executable: "/app/bin/server"},
+			match: ContainerMatch{Ambiguous: true, Candidates: []string{"app", "worker", "proxy"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, m := matchContainer(pod, tt.proc, "", "", r)
+			if got != tt.want || !reflect.DeepEqual(m, tt.match) {
+				t.Errorf("got (%q, %+v), want (%q, %+v)", got, m, tt.want, tt.match)
+			}
+		})
+	}
+
+	// 同一二进制运行在两个容器中
+	writeELFWithBuildID(t, filepath.Join(procRoot, "200/root/app/bin/server"), buildA)
+	got, m := matchContainer(pod, coreparser.ProcessInfo{Executable: "/app/bin/server", BuildID: buildA}, "", "", r)
+	want := ContainerMatch{Method: MatchBuildID, Ambiguous: true, Candidates: []string{"app", "worker"}}
+	if got != "" || !reflect.DeepEqual(m, want) {
+		t.Errorf("got (%q, %+v), want ambiguous %+v", got, m, want)
+	}
+}
@@ -0,0 +1,124 @@
+package podresolver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/DomineCore/coredog/internal/kube"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Workload Pod 所属的顶层工作负载
+type Workload struct {
+	Kind string // Deployment、StatefulSet、DaemonSet、CronJob、Job、ReplicaSet 等
+	Name string
+}
+
+// ownerCache 缓存 ReplicaSet/Job 的上层 owner，key 为 ReplicaSet/Job 的 UID（UID 不会复用，owner 也不会改变）
+var ownerCache sync.Map
+
+// resolveWorkload 沿 ownerReferences 找到 Pod 的顶层工作负载
+// ReplicaSet → Deployment，Job → CronJob；StatefulSet、DaemonSet 等直接返回
+// 查询 ReplicaSet/Job 失败时返回中间层对象本身
+func resolveWorkload(client kubernetes.Interface, pod *v1.Pod) Workload {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return Workload{}
+	}
+	w := Workload{Kind: ref.Kind, Name: ref.Name}
+	if ref.Kind != "ReplicaSet" && ref.Kind != "Job" {
+		return w
+	}
+	if cached, ok := ownerCache.Load(ref.UID); ok {
+		return cached.(Workload)
+	}
+	if client == nil {
+		return w
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var owner *metav1.OwnerReference
+	switch ref.Kind {
+	case "ReplicaSet":
+		rs, err := client.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			logrus.Debugf("failed to get replicaset %s/%s: %v", pod.Namespace, ref.Name, err)
+			return w
+		}
+		owner = metav1.GetControllerOf(rs)
+	case "Job":
+		job, err := client.BatchV1().Jobs(pod.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			logrus.Debugf("failed to get job %s/%s: %v", pod.Namespace, ref.Name, err)
+			return w
+		}
+		owner = metav1.GetControllerOf(job)
+	}
+	if owner != nil {
+		w = Workload{Kind: owner.Kind, Name: owner.Name}
+	}
+	ownerCache.Store(ref.UID, w)
+	return w
+}
+
+// findContainerStatus 查找容器状态：优先按容器名，其次按镜像；都未知时只有 Pod 仅有一个容器才返回其状态
+func findContainerStatus(pod *v1.Pod, containerName, image string) *v1.ContainerStatus {
+	statuses := append(append([]v1.ContainerStatus{}, pod.Status.ContainerStatuses...), pod.Status.InitContainerStatuses...)
+	if containerName != "" {
+		for i := range statuses {
+			if statuses[i].Name == containerName {
+				return &statuses[i]
+			}
+		}
+		return nil
+	}
+	if image != "" {
+		for i := range statuses {
+			if statuses[i].Image == image {
+				return &statuses[i]
+			}
+		}
+	}
+	if len(statuses) == 1 {
+		return &statuses[0]
+	}
+	return nil
+}
+
+// withPodMetadata 用 Pod 对象补充 PodInfo：节点、ServiceAccount、工作负载、labels/annotations 以及容器状态
+// 需在 ContainerName/Image 确定之后调用，用于定位容器状态
+func withPodMetadata(info PodInfo, pod *v1.Pod) PodInfo {
+	info.UID = string(pod.UID)
+	info.NodeIP = pod.Status.HostIP // 从 status.hostIP 获取节点 IP
+	info.NodeName = pod.Spec.NodeName
+	info.ServiceAccount = pod.Spec.ServiceAccountName
+	info.Labels = pod.Labels
+	info.Annotations = pod.Annotations
+
+	client, err := kube.Client()
+	if err != nil {
+		client = nil
+	}
+	info.Workload = resolveWorkload(client, pod)
+
+	if cs := findContainerStatus(pod, info.ContainerName, info.Image); cs != nil {
+		info.ImageDigest = cs.ImageID
+		info.RestartCount = cs.RestartCount
+		// 容器刚崩溃时 State 为 Terminated；已被重启时上一次退出记录在 LastTerminationState
+		term := cs.State.Terminated
+		if term == nil {
+			term = cs.LastTerminationState.Terminated
+		}
+		if term != nil {
+			info.LastTerminationReason = term.Reason
+			info.LastExitCode = term.ExitCode
+		}
+	}
+	return info
+}
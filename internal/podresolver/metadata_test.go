@@ -0,0 +1,91 @@
+package podresolver
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func controllerRef(kind, name, uid string) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{Kind: kind, Name: name, UID: types.UID(uid), Controller: &controller}}
+}
+
+func TestResolveWorkload(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Name: "app-7d9f8", Namespace: "default", UID: "rs-1",
+			OwnerReferences: controllerRef("Deployment", "app", "deploy-1"),
+		}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name: "backup-28000000", Namespace: "default", UID: "job-1",
+			OwnerReferences: controllerRef("CronJob", "backup", "cron-1"),
+		}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "bare-rs", Namespace: "default", UID: "rs-2"}},
+	)
+
+	tests := []struct {
+		name  string
+		owner []metav1.OwnerReference
+		want  Workload
+	}{
+		{"deployment", controllerRef("ReplicaSet", "app-7d9f8", "rs-1"), Workload{Kind: "Deployment", Name: "app"}},
+		{"cronjob", controllerRef("Job", "backup-28000000", "job-1"), Workload{Kind: "CronJob", Name: "backup"}},
+		{"statefulset", controllerRef("StatefulSet", "db", "sts-1"), Workload{Kind: "StatefulSet", Name: "db"}},
+		{"daemonset", controllerRef("DaemonSet", "agent", "ds-1"), Workload{Kind: "DaemonSet", Name: "agent"}},
+		{"bare replicaset", controllerRef("ReplicaSet", "bare-rs", "rs-2"), Workload{Kind: "ReplicaSet", Name: "bare-rs"}},
+		{"missing replicaset", controllerRef("ReplicaSet", "gone", "rs-3"), Workload{Kind: "ReplicaSet", Name: "gone"}},
+		{"standalone", nil, Workload{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "default", OwnerReferences: tt.owner}}
+			if got := resolveWorkload(client, pod); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithPodMetadata(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "db-0", Namespace: "default", UID: "9f8e7d6c-0000-0000-0000-000000000001",
+			Labels:          map[string]string{"app": "db"},
+			OwnerReferences: controllerRef("StatefulSet", "db", "sts-1"),
+		},
+		Spec: v1.PodSpec{NodeName: "node-1", ServiceAccountName: "db"},
+		Status: v1.PodStatus{
+			HostIP: "10.0.0.1",
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "sidecar", Image: "proxy:v1", ImageID: "proxy@sha256:aaa"},
+				{
+					Name: "db", Image: "db:v1", ImageID: "db@sha256:bbb", RestartCount: 2,
+					LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 139}},
+				},
+			},
+		},
+	}
+
+	info := withPodMetadata(PodInfo{Name: "db-0", Namespace: "default", ContainerName: "db"}, pod)
+	if info.UID != string(pod.UID) || info.NodeName != "node-1" || info.ServiceAccount != "db" || info.NodeIP != "10.0.0.1" {
+		t.Errorf("unexpected pod fields %+v", info)
+	}
+	if info.Workload != (Workload{Kind: "StatefulSet", Name: "db"}) {
+		t.Errorf("unexpected workload %+v", info.Workload)
+	}
+	if info.ImageDigest != "db@sha256:bbb" || info.RestartCount != 2 || info.LastTerminationReason != "Error" || info.LastExitCode != 139 {
+		t.Errorf("unexpected container status fields %+v", info)
+	}
+
+	// 旧路径格式没有容器名，按镜像匹配容器状态
+	info = withPodMetadata(PodInfo{Name: "db-0", Namespace: "default", Image: "proxy:v1"}, pod)
+	if info.ImageDigest != "proxy@sha256:aaa" {
+		t.Errorf("expected status matched by image, got %q", info.ImageDigest)
+	}
+}
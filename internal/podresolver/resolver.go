@@ -1,34 +1,93 @@
 package podresolver
 
 import (
-	"context"
 	"path/filepath"
 	"regexp"
 	"strings"
-	"time"
 
+	"github.com/DomineCore/coredog/internal/coreparser"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
+)
+
+const (
+	// AnnotationNotifyChannel Pod 指定的通知渠道名称，多个用逗号分隔，需在 PodNotify.allowedChannels 中
+	AnnotationNotifyChannel = "coredog.io/notify-channel"
+	// AnnotationNotifyMentions 通知时需要 @ 的用户，多个用逗号分隔
+	AnnotationNotifyMentions = "coredog.io/notify-mentions"
 )
 
 type PodInfo struct {
-	Name          string
-	Namespace     string
-	UID           string
-	NodeIP        string // Pod 所在节点的 IP，从 status.hostIP 获取
-	Image         string
-	ContainerName string
-	IsLegacyPath  bool // 标记是否来自旧路径格式
+	Name           string
+	Namespace      string
+	UID            string
+	NodeIP         string // Pod 所在节点的 IP，从 status.hostIP 获取
+	Image          string
+	ContainerName  string
+	IsLegacyPath   bool              // 标记是否来自旧路径格式
+	NameUnresolved bool              // Name 是由 admission UID 生成的占位名称（Pod 未找到）
+	Labels         map[string]string // Pod labels，用于通知路由
+	Annotations    map[string]string // Pod annotations，用于通知路由
+
+	NodeName              string         // spec.nodeName
+	ServiceAccount        string         // spec.serviceAccountName
+	Workload              Workload       // 沿 ownerReferences 解析出的顶层工作负载
+	ImageDigest           string         // containerStatuses[].imageID
+	RestartCount          int32          // 容器重启次数
+	LastTerminationReason string         // 最近一次退出原因，如 Error、OOMKilled
+	LastExitCode          int32          // 最近一次退出码
+	Match                 ContainerMatch // 崩溃进程与容器的匹配方式和置信度
+}
+
+// splitAnnotation 解析逗号分隔的 annotation 值，去除空白和空项
+func splitAnnotation(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// NotifyChannels 返回 Pod 通过 coredog.io/notify-channel 指定的通知渠道名称
+func (p PodInfo) NotifyChannels() []string {
+	return splitAnnotation(p.Annotations[AnnotationNotifyChannel])
+}
+
+// NotifyMentions 返回 Pod 通过 coredog.io/notify-mentions 指定的需要 @ 的用户
+func (p PodInfo) NotifyMentions() []string {
+	return splitAnnotation(p.Annotations[AnnotationNotifyMentions])
+}
+
+// 控制器生成名称后缀使用的字符集（k8s.io/apimachinery/pkg/util/rand，去掉了元音和易混淆字符）
+const nameSuffixChars = `[bcdfghjklmnpqrstvwxz2456789]`
+
+var (
+	// Deployment 管理的 Pod：<name>-<pod-template-hash>-<5 位随机后缀>
+	replicaSetPodRe = regexp.MustCompile(`^(.+)-` + nameSuffixChars + `{6,10}-` + nameSuffixChars + `{5}$`)
+	// DaemonSet/Job 等控制器生成的 Pod：<name>-<5 位随机后缀>
+	generatedPodRe = regexp.MustCompile(`^(.+)-` + nameSuffixChars + `{5}$`)
+	// StatefulSet 管理的 Pod：<name>-<序号>
+	statefulSetPodRe = regexp.MustCompile(`^(.+)-[0-9]+$`)
+)
+
+// GuessWorkloadName 根据 Pod 名称推测所属工作负载名称，用于告警分组
+// 只是基于控制器命名规则的推测，无法识别时返回 Pod 名称本身
+func GuessWorkloadName(podName string) string {
+	for _, re := range []*regexp.Regexp{replicaSetPodRe, statefulSetPodRe, generatedPodRe} {
+		if m := re.FindStringSubmatch(podName); len(m) == 2 {
+			return m[1]
+		}
+	}
+	return podName
 }
 
 // extractExecutableFromCorefile 从 coredump 文件名中提取可执行文件名
 // 文件名格式: core.%e.%p.%h.%t (例如: core.bash.12345.hostname.1234567890)
 func extractExecutableFromCorefile(corefilePath string) string {
 	filename := filepath.Base(corefilePath)
-	
+
 	// 匹配格式: core.<executable>.<pid>.<hostname>.<timestamp>
 	// 使用正则表达式提取可执行文件名
 	re := regexp.MustCompile(`^core\.([^.]+)\..*`)
@@ -38,7 +97,7 @@ func extractExecutableFromCorefile(corefilePath string) string {
 		logrus.Debugf("extracted executable '%s' from corefile: %s", executable, filename)
 		return executable
 	}
-	
+
 	logrus.Warnf("failed to extract executable from corefile: %s", filename)
 	return ""
 }
@@ -56,10 +115,10 @@ func Resolve(corefilePath string, enableLookup bool) PodInfo {
 	// 1. 首先尝试从新的路径结构解析
 	if newInfo := resolveFromNewPathStructure(corefilePath); newInfo.Name != "" {
 		info = newInfo
-		
-		logrus.Infof("resolved pod from new path structure: %s/%s, container: %s", 
+
+		logrus.Infof("resolved pod from new path structure: %s/%s, container: %s",
 			info.Namespace, info.Name, info.ContainerName)
-		
+
 		// 必须通过 Kubernetes 查询获取 NodeIP 信息
 		if enableLookup {
 			if enrichedInfo := enrichPodInfoFromKubernetes(info); enrichedInfo.UID != "" {
@@ -81,9 +140,9 @@ func Resolve(corefilePath string, enableLookup bool) PodInfo {
 func resolveFromNewPathStructure(corefilePath string) PodInfo {
 	// 标准化路径
 	cleanPath := filepath.Clean(corefilePath)
-	
+
 	var relativePath string
-	
+
 	// 尝试匹配 /dumps/ 路径（宿主机路径）
 	if dumpsIndex := strings.Index(cleanPath, "/dumps/"); dumpsIndex != -1 {
 		relativePath = cleanPath[dumpsIndex+7:] // 跳过 "/dumps/"
@@ -93,30 +152,31 @@ func resolveFromNewPathStructure(corefilePath string) PodInfo {
 	} else {
 		return PodInfo{}
 	}
-	
+
 	parts := strings.Split(relativePath, "/")
-	
+
 	// 需要至少 3 个部分：namespace/pod-name/container-name
 	if len(parts) < 3 {
 		return PodInfo{}
 	}
-	
+
 	namespace := parts[0]
-	podName := parts[1] 
+	podName := parts[1]
 	containerName := parts[2]
-	
+
 	// 验证部分不为空
 	if namespace == "" || podName == "" || containerName == "" {
 		return PodInfo{}
 	}
-	
-	logrus.Debugf("parsed new path structure: namespace=%s, pod=%s, container=%s", 
+
+	logrus.Debugf("parsed new path structure: namespace=%s, pod=%s, container=%s",
 		namespace, podName, containerName)
-	
+
 	return PodInfo{
 		Name:          podName,
 		Namespace:     namespace,
 		ContainerName: containerName,
+		Match:         ContainerMatch{Method: MatchPath, Confidence: 1},
 	}
 }
 
@@ -127,8 +187,12 @@ func resolveFromLegacyPath(corefilePath string, enableLookup bool, info PodInfo)
 	// 标记为旧路径格式
 	info.IsLegacyPath = true
 
-	// 从文件名中提取可执行文件名
+	// 从文件名中提取可执行文件名，并从 core 中读取崩溃进程的可执行文件路径和 build-id
 	executable := extractExecutableFromCorefile(corefilePath)
+	proc, err := coreparser.ReadProcessInfo(corefilePath)
+	if err != nil {
+		logrus.Debugf("failed to read process info from %s: %v", corefilePath, err)
+	}
 
 	// 从路径提取 namespace 和 admission UID
 	pathRegexp := regexp.MustCompile(`/corefile/([^/]+)/([0-9a-f-]{36})/`)
@@ -138,18 +202,25 @@ func resolveFromLegacyPath(corefilePath string, enableLookup bool, info PodInfo)
 
 		// 通过 admission UID 查询 Pod（匹配 volume 路径）
 		if enableLookup {
-			if podName, podUID, image, hostIP, ok := lookupPodByAdmissionUID(info.Namespace, admissionUID, executable); ok {
-				info.Name = podName
-				info.UID = podUID
-				info.Image = image
-				info.NodeIP = hostIP
-				logrus.Infof("resolved pod: %s/%s (admission-uid: %s, image: %s, hostIP: %s)", info.Namespace, info.Name, admissionUID, image, hostIP)
+			if pod, ok := lookupPodByAdmissionUID(info.Namespace, admissionUID); ok {
+				info.Name = pod.Name
+				rec, _ := ReadCrashRecord(corefilePath)
+				info.ContainerName, info.Match = matchContainer(pod, proc, executable, rec.ContainerID, getPIDResolver())
+				info.Image = containerImage(pod, info.ContainerName)
+				info = withPodMetadata(info, pod)
+				if info.Match.Ambiguous {
+					logrus.Warnf("could not determine which container of pod %s/%s dumped core (executable: %s, candidates: %v)",
+						info.Namespace, info.Name, executable, info.Match.Candidates)
+				}
+				logrus.Infof("resolved pod: %s/%s (admission-uid: %s, container: %s, match: %s, confidence: %.2f, hostIP: %s)",
+					info.Namespace, info.Name, admissionUID, info.ContainerName, info.Match.Method, info.Match.Confidence, info.NodeIP)
 				return info
 			}
 		}
 
 		// 查询失败，使用 admission UID 前缀作为标识
 		info.Name = "pod-" + admissionUID[:8]
+		info.NameUnresolved = true
 		logrus.Warnf("pod with admission-uid %s not found, using prefix as name", admissionUID)
 		return info
 	}
@@ -161,32 +232,13 @@ func resolveFromLegacyPath(corefilePath string, enableLookup bool, info PodInfo)
 // enrichPodInfoFromKubernetes 从 Kubernetes API 获取详细的 Pod 信息
 // NodeIP 从 status.hostIP 获取（Pod 实际运行所在节点的 IP）
 func enrichPodInfoFromKubernetes(info PodInfo) PodInfo {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		logrus.Warnf("failed to get in-cluster config: %v", err)
-		return info
-	}
-
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		logrus.Warnf("failed to create kubernetes client: %v", err)
-		return info
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
 	// 查询指定的 Pod
-	pod, err := clientset.CoreV1().Pods(info.Namespace).Get(ctx, info.Name, metav1.GetOptions{})
+	pod, err := getPod(info.Namespace, info.Name)
 	if err != nil {
 		logrus.Warnf("failed to get pod %s/%s: %v", info.Namespace, info.Name, err)
 		return info
 	}
 
-	// 更新基本信息
-	info.UID = string(pod.UID)
-	info.NodeIP = pod.Status.HostIP // 从 status.hostIP 获取节点 IP
-
 	// 查找对应容器的镜像
 	if info.ContainerName != "" {
 		// 在 containers 中查找
@@ -194,19 +246,19 @@ func enrichPodInfoFromKubernetes(info PodInfo) PodInfo {
 			if container.Name == info.ContainerName {
 				info.Image = container.Image
 				logrus.Infof("found container %s with image: %s, hostIP: %s", info.ContainerName, info.Image, info.NodeIP)
-				return info
+				return withPodMetadata(info, pod)
 			}
 		}
-		
+
 		// 在 initContainers 中查找
 		for _, container := range pod.Spec.InitContainers {
 			if container.Name == info.ContainerName {
 				info.Image = container.Image
 				logrus.Infof("found init container %s with image: %s, hostIP: %s", info.ContainerName, info.Image, info.NodeIP)
-				return info
+				return withPodMetadata(info, pod)
 			}
 		}
-		
+
 		logrus.Warnf("container %s not found in pod %s/%s", info.ContainerName, info.Namespace, info.Name)
 	}
 
@@ -216,129 +268,26 @@ func enrichPodInfoFromKubernetes(info PodInfo) PodInfo {
 		logrus.Debugf("using first container image as default: %s", info.Image)
 	}
 
-	return info
+	return withPodMetadata(info, pod)
 }
 
-// lookupPodByAdmissionUID 通过 annotation 查找 Pod，并根据可执行文件名匹配容器镜像
+// lookupPodByAdmissionUID 通过 annotation 查找 Pod
 // annotation: coredog.io/admission-uid
-// 返回 pod name, pod uid, image, hostIP (从 status.hostIP 获取)
-// 注意：由于需要返回 hostIP 等动态信息，缓存仅用于减少日志，每次仍需查询 API
-func lookupPodByAdmissionUID(namespace, admissionUID, executable string) (name string, uid string, image string, hostIP string, ok bool) {
-	cfg, err := rest.InClusterConfig()
+func lookupPodByAdmissionUID(namespace, admissionUID string) (*v1.Pod, bool) {
+	pod, err := findPodByAdmissionUID(namespace, admissionUID)
 	if err != nil {
-		logrus.Errorf("failed to get in-cluster config: %v", err)
-		return "", "", "", "", false
+		logrus.Errorf("failed to look up pod by admission-uid %s: %v", admissionUID, err)
+		return nil, false
 	}
 
-	cli, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		logrus.Errorf("failed to create k8s client: %v", err)
-		return "", "", "", "", false
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	// 列出 namespace 中的所有 Pod
-	podList, err := cli.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		logrus.Errorf("failed to list pods in namespace %s: %v", namespace, err)
-		return "", "", "", "", false
-	}
-
-	// 遍历 Pod，查找 annotation 匹配的
-	for _, pod := range podList.Items {
-		if pod.Annotations["coredog.io/admission-uid"] == admissionUID {
-			podName := pod.Name
-			podUID := string(pod.UID)
-			podHostIP := pod.Status.HostIP // 从 status.hostIP 获取节点 IP
-			
-			// 根据可执行文件名匹配对应的容器镜像
-			var matchedImage string
-			if executable != "" {
-				matchedImage = findContainerImageByExecutable(pod.Spec.Containers, executable)
-			}
-			
-			// 如果没有匹配到特定容器，使用第一个容器的镜像作为后备
-			if matchedImage == "" && len(pod.Spec.Containers) > 0 {
-				matchedImage = pod.Spec.Containers[0].Image
-				logrus.Warnf("could not match executable '%s' to specific container, using first container image: %s", executable, matchedImage)
-			}
-
-			logrus.Infof("found pod by admission-uid annotation: %s/%s (pod-uid: %s, executable: %s, image: %s, hostIP: %s)", 
-				namespace, podName, podUID, executable, matchedImage, podHostIP)
-			return podName, podUID, matchedImage, podHostIP, true
-		}
+	if pod == nil {
+		logrus.Warnf("pod with admission-uid %s not found (pod may have been deleted)", admissionUID)
+		return nil, false
 	}
 
-	logrus.Warnf("pod with admission-uid %s not found (searched %d pods, pod may have been deleted)",
-		admissionUID, len(podList.Items))
-	return "", "", "", "", false
-}
-
-// findContainerImageByExecutable 根据可执行文件名匹配容器镜像
-// 策略：
-// 1. 如果可执行文件名与容器名匹配，返回该容器镜像
-// 2. 如果可执行文件名与镜像名的一部分匹配，返回该容器镜像
-// 3. 对于常见的可执行文件（如 java, python, node），尝试匹配对应的基础镜像
-func findContainerImageByExecutable(containers []v1.Container, executable string) string {
-	if executable == "" {
-		return ""
-	}
-	
-	// 策略1: 直接匹配容器名
-	for _, container := range containers {
-		if container.Name == executable {
-			logrus.Debugf("matched executable '%s' to container name '%s', image: %s", executable, container.Name, container.Image)
-			return container.Image
-		}
-	}
-	
-	// 策略2: 匹配镜像名的一部分
-	for _, container := range containers {
-		// 提取镜像名（去掉 registry 和 tag）
-		imageName := extractImageName(container.Image)
-		if strings.Contains(imageName, executable) || strings.Contains(executable, imageName) {
-			logrus.Debugf("matched executable '%s' to image name '%s', full image: %s", executable, imageName, container.Image)
-			return container.Image
-		}
-	}
-	
-	// 策略3: 基于常见可执行文件的启发式匹配
-	executableLower := strings.ToLower(executable)
-	for _, container := range containers {
-		imageLower := strings.ToLower(container.Image)
-		
-		// Java 应用
-		if (executableLower == "java" || strings.Contains(executableLower, "java")) && 
-		   (strings.Contains(imageLower, "java") || strings.Contains(imageLower, "openjdk") || strings.Contains(imageLower, "jre")) {
-			logrus.Debugf("matched Java executable '%s' to Java image: %s", executable, container.Image)
-			return container.Image
-		}
-		
-		// Python 应用
-		if (executableLower == "python" || executableLower == "python3" || strings.HasPrefix(executableLower, "python")) && 
-		   strings.Contains(imageLower, "python") {
-			logrus.Debugf("matched Python executable '%s' to Python image: %s", executable, container.Image)
-			return container.Image
-		}
-		
-		// Node.js 应用
-		if (executableLower == "node" || executableLower == "nodejs") && 
-		   (strings.Contains(imageLower, "node") || strings.Contains(imageLower, "nodejs")) {
-			logrus.Debugf("matched Node.js executable '%s' to Node.js image: %s", executable, container.Image)
-			return container.Image
-		}
-		
-		// Go 应用
-		if strings.Contains(imageLower, "golang") || strings.Contains(imageLower, "go:") {
-			logrus.Debugf("matched executable '%s' to Go image: %s", executable, container.Image)
-			return container.Image
-		}
-	}
-	
-	logrus.Debugf("could not match executable '%s' to any specific container", executable)
-	return ""
+	logrus.Infof("found pod by admission-uid annotation: %s/%s (pod-uid: %s, hostIP: %s)",
+		namespace, pod.Name, pod.UID, pod.Status.HostIP)
+	return pod, true
 }
 
 // extractImageName 从完整的镜像路径中提取镜像名
@@ -348,11 +297,11 @@ func extractImageName(fullImage string) string {
 	if idx := strings.LastIndex(fullImage, ":"); idx != -1 {
 		fullImage = fullImage[:idx]
 	}
-	
+
 	// 移除 registry 和 namespace
 	if idx := strings.LastIndex(fullImage, "/"); idx != -1 {
 		return fullImage[idx+1:]
 	}
-	
+
 	return fullImage
 }
@@ -0,0 +1,340 @@
+package quota
+
+import (
+	"expvar"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Pod annotation，覆盖配置中的配额，值必须为正数
+const (
+	AnnotationMaxCoreSize     = "coredog.io/max-core-size"      // 如 2Gi
+	AnnotationMaxCoresPerHour = "coredog.io/max-cores-per-hour" // 整数
+	AnnotationMaxBytesPerDay  = "coredog.io/max-bytes-per-day"  // 如 20Gi
+)
+
+// 超出的配额
+const (
+	ReasonCoreSize     = "max-core-size"
+	ReasonCoresPerHour = "max-cores-per-hour"
+	ReasonBytesPerDay  = "max-bytes-per-day"
+)
+
+const (
+	hour = time.Hour
+	day  = 24 * time.Hour
+)
+
+// 配额指标，通过 expvar 发布（agent 的 /debug/vars），所有工作负载合计
+var (
+	uploadedCores = expvar.NewInt("coredog_quota_uploaded_cores") // 计入配额的已上传 core 数
+	uploadedBytes = expvar.NewInt("coredog_quota_uploaded_bytes")
+	rejectedCores = expvar.NewMap("coredog_quota_rejected_cores") // 按超出的配额（Reason）统计未上传的 core 数
+	rejectedBytes = expvar.NewMap("coredog_quota_rejected_bytes")
+)
+
+// Policy 生效的配额，0 表示不限制
+type Policy struct {
+	MaxCoreSize     int64
+	MaxCoresPerHour int
+	MaxBytesPerDay  int64
+}
+
+// Limited 是否设置了任何限制
+func (p Policy) Limited() bool {
+	return p.MaxCoreSize > 0 || p.MaxCoresPerHour > 0 || p.MaxBytesPerDay > 0
+}
+
+// ParseSize 解析 Kubernetes 数量格式（如 512Mi、8Gi）或字节数，空字符串为 0
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if q.Sign() < 0 {
+		return 0, fmt.Errorf("invalid size %q: must not be negative", s)
+	}
+	return q.Value(), nil
+}
+
+// ParseAnnotations 解析 Pod annotation 中的配额，未设置的字段为 0
+// 返回的错误包含所有无效的 annotation，有效的字段仍会返回
+func ParseAnnotations(annotations map[string]string) (Policy, error) {
+	var p Policy
+	var errs []string
+	if v, ok := annotations[AnnotationMaxCoreSize]; ok {
+		n, err := ParseSize(v)
+		if err == nil && n == 0 {
+			err = fmt.Errorf("must be positive")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", AnnotationMaxCoreSize, err))
+		} else {
+			p.MaxCoreSize = n
+		}
+	}
+	if v, ok := annotations[AnnotationMaxCoresPerHour]; ok {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n <= 0 {
+			errs = append(errs, fmt.Sprintf("%s: invalid count %q, must be a positive integer", AnnotationMaxCoresPerHour, v))
+		} else {
+			p.MaxCoresPerHour = n
+		}
+	}
+	if v, ok := annotations[AnnotationMaxBytesPerDay]; ok {
+		n, err := ParseSize(v)
+		if err == nil && n == 0 {
+			err = fmt.Errorf("must be positive")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", AnnotationMaxBytesPerDay, err))
+		} else {
+			p.MaxBytesPerDay = n
+		}
+	}
+	if len(errs) > 0 {
+		return p, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return p, nil
+}
+
+// ValidateAnnotations 校验 Pod annotation 中的配额，供 webhook 在创建 Pod 时拒绝无效的值
+func ValidateAnnotations(annotations map[string]string) error {
+	_, err := ParseAnnotations(annotations)
+	return err
+}
+
+type rule struct {
+	namespace string
+	workload  string
+	policy    Policy
+}
+
+// Decision 一个 core 的配额检查结果
+type Decision struct {
+	Allowed bool
+	Policy  Policy
+	Reason  string // 超出的配额，Allowed 时为空
+	Limit   int64  // 超出的配额值
+	Used    int64  // 窗口内已上传的 core 数或字节数；超出 max-core-size 时为 core 大小
+	// Notify 是否需要发送配额告警：超出大小时每次都发送，超出频率时每个窗口只发送一次
+	Notify bool
+	// Rejected/RejectedBytes 该工作负载因 Reason 累计被拒绝的 core 数和大小（包括本次）
+	Rejected      int64
+	RejectedBytes int64
+}
+
+type upload struct {
+	at   time.Time
+	size int64
+}
+
+type counter struct {
+	cores int64
+	bytes int64
+}
+
+// usage 一个工作负载最近 24 小时的上传记录和拒绝计数
+type usage struct {
+	uploads  []upload             // 按时间顺序
+	notified map[string]time.Time // reason → 最近一次告警时间
+	rejected map[string]*counter
+}
+
+// Limiter 按 namespace/工作负载统计上传量并检查配额，只在内存中计数，agent 重启后重新计算
+type Limiter struct {
+	def           Policy
+	rules         []rule
+	allowIncrease bool
+	Now           func() time.Time // 为 nil 时使用 time.Now
+
+	mu    sync.Mutex
+	usage map[string]*usage // namespace/workload
+}
+
+// New 根据配置创建 Limiter，配置中的大小无效时返回错误
+func New(cfg cfgpkg.QuotaConfig) (*Limiter, error) {
+	def, err := parsePolicy(cfg.Default.MaxCoreSize, cfg.Default.MaxCoresPerHour, cfg.Default.MaxBytesPerDay)
+	if err != nil {
+		return nil, fmt.Errorf("Quota.default: %w", err)
+	}
+	l := &Limiter{def: def, allowIncrease: cfg.AllowAnnotationIncrease, usage: make(map[string]*usage)}
+	for i, r := range cfg.Policies {
+		p, err := parsePolicy(r.MaxCoreSize, r.MaxCoresPerHour, r.MaxBytesPerDay)
+		if err != nil {
+			return nil, fmt.Errorf("Quota.policies[%d]: %w", i, err)
+		}
+		for _, pattern := range []string{r.Namespace, r.Workload} {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("Quota.policies[%d]: invalid pattern %q", i, pattern)
+			}
+		}
+		l.rules = append(l.rules, rule{namespace: r.Namespace, workload: r.Workload, policy: merge(def, p)})
+	}
+	return l, nil
+}
+
+func parsePolicy(maxCoreSize string, maxCoresPerHour int, maxBytesPerDay string) (Policy, error) {
+	var p Policy
+	var err error
+	if p.MaxCoreSize, err = ParseSize(maxCoreSize); err != nil {
+		return p, fmt.Errorf("maxCoreSize: %w", err)
+	}
+	if maxCoresPerHour < 0 {
+		return p, fmt.Errorf("maxCoresPerHour: must not be negative")
+	}
+	p.MaxCoresPerHour = maxCoresPerHour
+	if p.MaxBytesPerDay, err = ParseSize(maxBytesPerDay); err != nil {
+		return p, fmt.Errorf("maxBytesPerDay: %w", err)
+	}
+	return p, nil
+}
+
+// merge 用 override 中非 0 的字段覆盖 base
+func merge(base, override Policy) Policy {
+	if override.MaxCoreSize > 0 {
+		base.MaxCoreSize = override.MaxCoreSize
+	}
+	if override.MaxCoresPerHour > 0 {
+		base.MaxCoresPerHour = override.MaxCoresPerHour
+	}
+	if override.MaxBytesPerDay > 0 {
+		base.MaxBytesPerDay = override.MaxBytesPerDay
+	}
+	return base
+}
+
+// tighten 用 override 中更严格的非 0 字段覆盖 base
+func tighten(base, override Policy) Policy {
+	if override.MaxCoreSize > 0 && (base.MaxCoreSize == 0 || override.MaxCoreSize < base.MaxCoreSize) {
+		base.MaxCoreSize = override.MaxCoreSize
+	}
+	if override.MaxCoresPerHour > 0 && (base.MaxCoresPerHour == 0 || override.MaxCoresPerHour < base.MaxCoresPerHour) {
+		base.MaxCoresPerHour = override.MaxCoresPerHour
+	}
+	if override.MaxBytesPerDay > 0 && (base.MaxBytesPerDay == 0 || override.MaxBytesPerDay < base.MaxBytesPerDay) {
+		base.MaxBytesPerDay = override.MaxBytesPerDay
+	}
+	return base
+}
+
+// Policy 工作负载生效的配额：第一条匹配的规则（未匹配时为 default），再应用 Pod annotation
+// annotation 无效时忽略无效的字段，并返回错误供调用方记录
+func (l *Limiter) Policy(namespace, workload string, annotations map[string]string) (Policy, error) {
+	p := l.def
+	for _, r := range l.rules {
+		if ok, _ := filepath.Match(r.namespace, namespace); r.namespace != "" && !ok {
+			continue
+		}
+		if ok, _ := filepath.Match(r.workload, workload); r.workload != "" && !ok {
+			continue
+		}
+		p = r.policy
+		break
+	}
+	override, err := ParseAnnotations(annotations)
+	if l.allowIncrease {
+		return merge(p, override), err
+	}
+	return tighten(p, override), err
+}
+
+// Admit 检查工作负载上传一个大小为 size 的 core 是否超出配额
+// 依次检查 max-core-size、max-cores-per-hour 和 max-bytes-per-day；允许时不计入上传量，上传成功后由调用方 Record
+func (l *Limiter) Admit(namespace, workload string, policy Policy, size int64) Decision {
+	now := time.Now()
+	if l.Now != nil {
+		now = l.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := namespace + "/" + workload
+	u := l.usage[key]
+	if u == nil {
+		u = &usage{notified: make(map[string]time.Time), rejected: make(map[string]*counter)}
+		l.usage[key] = u
+	}
+	u.expire(now)
+
+	d := Decision{Allowed: true, Policy: policy}
+	var coresInHour, bytesInDay int64
+	for _, up := range u.uploads {
+		if now.Sub(up.at) < hour {
+			coresInHour++
+		}
+		bytesInDay += up.size
+	}
+	switch {
+	case policy.MaxCoreSize > 0 && size > policy.MaxCoreSize:
+		d.Reason, d.Limit, d.Used = ReasonCoreSize, policy.MaxCoreSize, size
+	case policy.MaxCoresPerHour > 0 && coresInHour >= int64(policy.MaxCoresPerHour):
+		d.Reason, d.Limit, d.Used = ReasonCoresPerHour, int64(policy.MaxCoresPerHour), coresInHour
+	case policy.MaxBytesPerDay > 0 && bytesInDay+size > policy.MaxBytesPerDay:
+		d.Reason, d.Limit, d.Used = ReasonBytesPerDay, policy.MaxBytesPerDay, bytesInDay
+	default:
+		return d
+	}
+
+	d.Allowed = false
+	c := u.rejected[d.Reason]
+	if c == nil {
+		c = &counter{}
+		u.rejected[d.Reason] = c
+	}
+	c.cores++
+	c.bytes += size
+	d.Rejected, d.RejectedBytes = c.cores, c.bytes
+	rejectedCores.Add(d.Reason, 1)
+	rejectedBytes.Add(d.Reason, size)
+
+	window := map[string]time.Duration{ReasonCoresPerHour: hour, ReasonBytesPerDay: day}[d.Reason]
+	last, notified := u.notified[d.Reason]
+	if window == 0 || !notified || now.Sub(last) >= window {
+		d.Notify = true
+		u.notified[d.Reason] = now
+	}
+	return d
+}
+
+// Record 记录工作负载成功上传的一个 core，计入之后的配额检查
+// 上传失败的 core 不计入，避免存储故障期间重试耗尽配额
+func (l *Limiter) Record(namespace, workload string, size int64) {
+	now := time.Now()
+	if l.Now != nil {
+		now = l.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := namespace + "/" + workload
+	u := l.usage[key]
+	if u == nil {
+		u = &usage{notified: make(map[string]time.Time), rejected: make(map[string]*counter)}
+		l.usage[key] = u
+	}
+	u.expire(now)
+	u.uploads = append(u.uploads, upload{at: now, size: size})
+	uploadedCores.Add(1)
+	uploadedBytes.Add(size)
+}
+
+// expire 清理超过 24 小时的上传记录
+func (u *usage) expire(now time.Time) {
+	i := 0
+	for i < len(u.uploads) && now.Sub(u.uploads[i].at) >= day {
+		i++
+	}
+	u.uploads = u.uploads[i:]
+}
@@ -0,0 +1,194 @@
+package quota
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+)
+
+const gi = int64(1) << 30
+
+func TestParseAnnotations(t *testing.T) {
+	p, err := ParseAnnotations(map[string]string{
+		AnnotationMaxCoreSize:     "2Gi",
+		AnnotationMaxCoresPerHour: " 3 ",
+		AnnotationMaxBytesPerDay:  "10737418240",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Policy{MaxCoreSize: 2 * gi, MaxCoresPerHour: 3, MaxBytesPerDay: 10 * gi}
+	if p != want {
+		t.Errorf("policy = %+v, want %+v", p, want)
+	}
+
+	p, err = ParseAnnotations(map[string]string{
+		AnnotationMaxCoreSize:     "big",
+		AnnotationMaxCoresPerHour: "0",
+		AnnotationMaxBytesPerDay:  "1Gi",
+	})
+	if err == nil {
+		t.Fatal("expected error for invalid annotations")
+	}
+	for _, key := range []string{AnnotationMaxCoreSize, AnnotationMaxCoresPerHour} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q does not mention %s", err, key)
+		}
+	}
+	if p.MaxBytesPerDay != gi || p.MaxCoreSize != 0 {
+		t.Errorf("valid fields should still be parsed: %+v", p)
+	}
+
+	if err := ValidateAnnotations(map[string]string{AnnotationMaxBytesPerDay: "-1Gi"}); err == nil {
+		t.Error("negative size should be rejected")
+	}
+	if err := ValidateAnnotations(map[string]string{"app": "x"}); err != nil {
+		t.Errorf("pods without quota annotations should be valid: %v", err)
+	}
+}
+
+func TestLimiterPolicy(t *testing.T) {
+	cfg := cfgpkg.QuotaConfig{
+		Default: cfgpkg.QuotaPolicy{MaxCoreSize: "8Gi", MaxCoresPerHour: 10},
+		Policies: []cfgpkg.QuotaRule{
+			{Namespace: "batch-*", Workload: "java-*", MaxCoreSize: "1Gi"},
+			{Namespace: "batch-*", MaxBytesPerDay: "50Gi"},
+		},
+	}
+	l, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		namespace, workload string
+		annotations         map[string]string
+		want                Policy
+	}{
+		{"default", "web", nil, Policy{MaxCoreSize: 8 * gi, MaxCoresPerHour: 10}},
+		{"batch-1", "java-etl", nil, Policy{MaxCoreSize: gi, MaxCoresPerHour: 10}},
+		{"batch-1", "cpp", nil, Policy{MaxCoreSize: 8 * gi, MaxCoresPerHour: 10, MaxBytesPerDay: 50 * gi}},
+		// annotation 只能收紧限制
+		{"default", "web", map[string]string{AnnotationMaxCoreSize: "16Gi", AnnotationMaxCoresPerHour: "2"},
+			Policy{MaxCoreSize: 8 * gi, MaxCoresPerHour: 2}},
+		{"default", "web", map[string]string{AnnotationMaxBytesPerDay: "20Gi"},
+			Policy{MaxCoreSize: 8 * gi, MaxCoresPerHour: 10, MaxBytesPerDay: 20 * gi}},
+	}
+	for _, tt := range tests {
+		got, err := l.Policy(tt.namespace, tt.workload, tt.annotations)
+		if err != nil {
+			t.Errorf("%s/%s: %v", tt.namespace, tt.workload, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s/%s %v: policy = %+v, want %+v", tt.namespace, tt.workload, tt.annotations, got, tt.want)
+		}
+	}
+
+	cfg.AllowAnnotationIncrease = true
+	l, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := l.Policy("default", "web", map[string]string{AnnotationMaxCoreSize: "16Gi"})
+	if got.MaxCoreSize != 16*gi {
+		t.Errorf("allowAnnotationIncrease: max core size = %d, want %d", got.MaxCoreSize, 16*gi)
+	}
+
+	// 无效的 annotation 被忽略
+	got, err = l.Policy("default", "web", map[string]string{AnnotationMaxCoreSize: "huge"})
+	if err == nil || got.MaxCoreSize != 8*gi {
+		t.Errorf("invalid annotation: policy = %+v, err = %v", got, err)
+	}
+
+	if _, err := New(cfgpkg.QuotaConfig{Default: cfgpkg.QuotaPolicy{MaxBytesPerDay: "lots"}}); err == nil {
+		t.Error("expected error for invalid size in config")
+	}
+}
+
+func TestLimiterAdmit(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l, err := New(cfgpkg.QuotaConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Now = func() time.Time { return now }
+	policy := Policy{MaxCoreSize: 4 * gi, MaxCoresPerHour: 2, MaxBytesPerDay: 6 * gi}
+
+	// 超出大小：每次都告警，不计入上传量
+	for i := 1; i <= 2; i++ {
+		d := l.Admit("prod", "java", policy, 32*gi)
+		if d.Allowed || d.Reason != ReasonCoreSize || !d.Notify || d.Limit != 4*gi || d.Used != 32*gi {
+			t.Fatalf("oversize core #%d: %+v", i, d)
+		}
+		if d.Rejected != int64(i) || d.RejectedBytes != int64(i)*32*gi {
+			t.Errorf("oversize core #%d: rejected %d/%d", i, d.Rejected, d.RejectedBytes)
+		}
+	}
+
+	if v := rejectedCores.Get(ReasonCoreSize); v == nil || v.String() == "0" {
+		t.Errorf("rejected cores metric = %v", v)
+	}
+
+	// 允许的 core 上传成功后才计入上传量，上传失败（未 Record）不消耗配额
+	admit := func(namespace, workload string, size int64) Decision {
+		d := l.Admit(namespace, workload, policy, size)
+		if d.Allowed {
+			l.Record(namespace, workload, size)
+		}
+		return d
+	}
+	for i := 0; i < 3; i++ {
+		if d := l.Admit("prod", "java", policy, gi); !d.Allowed {
+			t.Fatalf("failed uploads should not count: %+v", d)
+		}
+	}
+	uploaded := uploadedCores.Value()
+
+	if d := admit("prod", "java", gi); !d.Allowed {
+		t.Fatalf("first core should be allowed: %+v", d)
+	}
+	now = now.Add(10 * time.Minute)
+	if d := admit("prod", "java", gi); !d.Allowed {
+		t.Fatalf("second core should be allowed: %+v", d)
+	}
+
+	// 一小时内第三个：超出频率，只在窗口内第一次告警
+	d := l.Admit("prod", "java", policy, gi)
+	if d.Allowed || d.Reason != ReasonCoresPerHour || d.Used != 2 || !d.Notify {
+		t.Fatalf("third core in an hour: %+v", d)
+	}
+	if d := l.Admit("prod", "java", policy, gi); d.Allowed || d.Notify || d.Rejected != 2 {
+		t.Fatalf("fourth core in an hour: %+v", d)
+	}
+
+	// 其他工作负载不受影响
+	if d := admit("prod", "go", gi); !d.Allowed {
+		t.Fatalf("other workload: %+v", d)
+	}
+
+	// 一小时后频率恢复，但 24 小时内的总大小超出
+	now = now.Add(time.Hour)
+	if d := admit("prod", "java", 3*gi); !d.Allowed {
+		t.Fatalf("after an hour: %+v", d)
+	}
+	d = l.Admit("prod", "java", policy, 2*gi)
+	if d.Allowed || d.Reason != ReasonBytesPerDay || d.Used != 5*gi || !d.Notify {
+		t.Fatalf("daily bytes: %+v", d)
+	}
+	now = now.Add(2 * time.Hour)
+	if d := l.Admit("prod", "java", policy, 2*gi); d.Allowed || d.Notify {
+		t.Fatalf("daily bytes, same window: %+v", d)
+	}
+
+	if got := uploadedCores.Value() - uploaded; got != 4 {
+		t.Errorf("uploaded cores metric increased by %d, want 4", got)
+	}
+
+	// 24 小时后上传记录过期
+	now = now.Add(24 * time.Hour)
+	if d := admit("prod", "java", 3*gi); !d.Allowed {
+		t.Fatalf("after a day: %+v", d)
+	}
+}
@@ -0,0 +1,215 @@
+package reporter
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// core dump 生命周期事件类型
+// 同一个 core dump 的所有事件携带相同的 coredump_id，下游可据此串联完整生命周期
+const (
+	EventTypeDetected         = "coredog.coredump.detected"          // watcher 发现新的 core 文件
+	EventTypeParseFailed      = "coredog.coredump.parse_failed"      // core 文件解析失败
+	EventTypeUploadFailed     = "coredog.coredump.upload_failed"     // 上传存储失败
+	EventTypeUploaded         = "coredog.coredump.uploaded"          // 上传成功
+	EventTypeHandlerCompleted = "coredog.coredump.handler_completed" // 自定义处理器执行结束
+	EventTypeDeletedLocal     = "coredog.coredump.deleted_local"     // 本地 core 文件已删除或清空
+	EventTypeEnriched         = "coredog.coredump.enriched"          // 上报后补全了之前未解析的元数据
+	EventTypeQuotaExceeded    = "coredog.coredump.quota_exceeded"    // 超出配额，只上报元数据，未上传
+)
+
+// SchemaVersion 事件数据 schema 的版本
+// 只允许向后兼容的修改（新增可选字段）；不兼容的修改需要提升版本
+const SchemaVersion = "v1"
+
+//go:embed schemas/*.json
+var schemaFS embed.FS
+
+// EventTypes 返回所有事件类型
+func EventTypes() []string {
+	return []string{
+		EventTypeDetected,
+		EventTypeParseFailed,
+		EventTypeUploadFailed,
+		EventTypeUploaded,
+		EventTypeHandlerCompleted,
+		EventTypeDeletedLocal,
+		EventTypeEnriched,
+		EventTypeQuotaExceeded,
+	}
+}
+
+// DataSchemaURI 返回事件类型对应的 dataschema 属性值
+func DataSchemaURI(eventType string) string {
+	return fmt.Sprintf("urn:coredog:schema:%s:%s", eventType, SchemaVersion)
+}
+
+// Schema 返回事件类型对应的 JSON Schema
+func Schema(eventType string) ([]byte, error) {
+	return schemaFS.ReadFile(fmt.Sprintf("schemas/%s.%s.json", eventType, SchemaVersion))
+}
+
+// NewEventID 生成 UUIDv7：按时间有序且全局唯一
+func NewEventID() string {
+	// 仅在系统随机数源不可用时失败，与 uuid.New 一样直接 panic
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// CoredumpRef 所有生命周期事件共有的 core dump 标识
+type CoredumpRef struct {
+	CoredumpID   string `json:"coredump_id"` // 同一个 core dump 的所有事件共享
+	FileName     string `json:"file_name"`
+	PodName      string `json:"pod_name"`
+	PodNamespace string `json:"pod_namespace"`
+	Container    string `json:"container"`
+	NodeIP       string `json:"node_ip,omitempty"` // Pod 所在节点的 IP，从 status.hostIP 获取
+	ClusterName  string `json:"cluster_name,omitempty"`
+	ClusterID    string `json:"cluster_id,omitempty"` // 默认为 kube-system namespace 的 UID
+	Timestamp    string `json:"timestamp"`
+}
+
+// CoredumpDetectedData coredog.coredump.detected 事件数据
+type CoredumpDetectedData struct {
+	CoredumpRef
+	FilePath string `json:"file_path"`
+	FileSize int64  `json:"file_size"`
+}
+
+// CoredumpParseFailedData coredog.coredump.parse_failed 事件数据
+type CoredumpParseFailedData struct {
+	CoredumpRef
+	Error string `json:"error"`
+}
+
+// CoredumpUploadFailedData coredog.coredump.upload_failed 事件数据
+type CoredumpUploadFailedData struct {
+	CoredumpRef
+	Protocol string `json:"protocol"`
+	Error    string `json:"error"`
+}
+
+// CoredumpHandlerCompletedData coredog.coredump.handler_completed 事件数据
+type CoredumpHandlerCompletedData struct {
+	CoredumpRef
+	Success    bool   `json:"success"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// CoredumpDeletedLocalData coredog.coredump.deleted_local 事件数据
+type CoredumpDeletedLocalData struct {
+	CoredumpRef
+	FilePath string `json:"file_path"`
+	Method   string `json:"method"` // rm 或 truncate
+}
+
+// CoredumpQuotaExceededData coredog.coredump.quota_exceeded 事件数据
+// core 文件未上传，只携带元数据；rejected/rejected_bytes 为该工作负载因同一配额累计被拒绝的 core 数和大小
+type CoredumpQuotaExceededData struct {
+	CoredumpRef
+	FilePath       string       `json:"file_path"`
+	FileSize       int64        `json:"file_size"`
+	Reason         string       `json:"reason"` // max-core-size、max-cores-per-hour 或 max-bytes-per-day
+	Limit          int64        `json:"limit"`
+	Used           int64        `json:"used"`
+	Rejected       int64        `json:"rejected"`
+	RejectedBytes  int64        `json:"rejected_bytes"`
+	ExecutablePath string       `json:"executable_path,omitempty"`
+	Signal         string       `json:"signal,omitempty"`
+	Image          string       `json:"image"`
+	Pod            *PodMetadata `json:"pod,omitempty"`
+}
+
+// PodMetadata 崩溃 Pod 的扩展元数据，用于排障
+type PodMetadata struct {
+	UID                   string            `json:"uid,omitempty"`
+	NodeName              string            `json:"node_name,omitempty"`
+	ServiceAccount        string            `json:"service_account,omitempty"`
+	WorkloadKind          string            `json:"workload_kind,omitempty"` // Deployment、StatefulSet、DaemonSet、CronJob 等
+	WorkloadName          string            `json:"workload_name,omitempty"`
+	ImageDigest           string            `json:"image_digest,omitempty"` // containerStatuses[].imageID
+	RestartCount          int32             `json:"restart_count"`
+	LastTerminationReason string            `json:"last_termination_reason,omitempty"`
+	LastExitCode          int32             `json:"last_exit_code,omitempty"`
+	Labels                map[string]string `json:"labels,omitempty"`
+	Annotations           map[string]string `json:"annotations,omitempty"` // 只包含配置中选择的 annotation
+	ContainerMatch        *ContainerMatch   `json:"container_match,omitempty"`
+}
+
+// ContainerMatch 崩溃进程与 Pod 中容器的匹配结果
+type ContainerMatch struct {
+	Method     string   `json:"method,omitempty"` // path、cgroup、build-id、single-container、executable、name
+	Confidence float64  `json:"confidence"`
+	Ambiguous  bool     `json:"ambiguous"`            // 无法唯一确定容器，此时 container 和 image 为空
+	Candidates []string `json:"candidates,omitempty"` // 无法唯一确定时的候选容器名
+}
+
+// CoredumpEnrichedData coredog.coredump.enriched 事件数据
+// 携带最新的 Pod 信息，resolved 为相对上一次上报新解析出的字段
+type CoredumpEnrichedData struct {
+	CoredumpRef
+	Image        string       `json:"image"`
+	Resolved     []string     `json:"resolved"`
+	Unresolved   []string     `json:"unresolved"`
+	Completeness float64      `json:"completeness"`
+	Pod          *PodMetadata `json:"pod,omitempty"`
+}
+
+// toData 将事件数据结构转换为 CloudEvent data
+func toData(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Report 上报一个 core dump 生命周期事件，subject 为 core 文件名
+func (r *Reporter) Report(ctx context.Context, eventType, subject string, data interface{}) error {
+	if r == nil || r.transport == nil {
+		return nil // 如果 reporter 未配置，则忽略
+	}
+	if r.eventTypes != nil && !r.eventTypes[eventType] {
+		return nil
+	}
+
+	payload, err := toData(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event data: %w", eventType, err)
+	}
+
+	event := &CloudEvent{
+		SpecVersion:     "1.0",
+		Type:            eventType,
+		Source:          r.source,
+		ID:              NewEventID(),
+		Subject:         subject,
+		Time:            time.Now().UTC().Format(time.RFC3339Nano),
+		DataContentType: "application/json",
+		DataSchema:      DataSchemaURI(eventType),
+		Data:            payload,
+	}
+
+	if r.live != nil {
+		if err := r.enqueue(ctx, event); err != nil {
+			return err
+		}
+		logrus.Infof("[%s] queued %s event for %s (event_id: %s)", r.name, eventType, subject, event.ID)
+		return nil
+	}
+	if err := r.send(ctx, event); err != nil {
+		return err
+	}
+	logrus.Infof("[%s] reported %s event for %s (event_id: %s)", r.name, eventType, subject, event.ID)
+	return nil
+}
@@ -0,0 +1,339 @@
+package reporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/sirupsen/logrus"
+	"github.com/xdg-go/scram"
+)
+
+const (
+	defaultKafkaTopic   = "coredog.events"
+	defaultKafkaKey     = "{coredump_id}"
+	defaultKafkaVersion = "2.1.0"
+)
+
+// KafkaOptions Kafka sink 配置
+type KafkaOptions struct {
+	Brokers    []string
+	Topic      string // 默认 coredog.events
+	Key        string // 消息 key 模板，{字段} 替换为事件 data 中的字段，另支持 {type}、{id}；默认 {coredump_id}
+	ClientID   string
+	Version    string // Kafka 协议版本，默认 2.1.0
+	Idempotent bool   // 幂等生产者：broker 按 producer ID + 序号去重，重试不会产生重复消息
+
+	SASLMechanism string // PLAIN、SCRAM-SHA-256、SCRAM-SHA-512，为空表示不启用 SASL
+	SASLUsername  string
+	SASLPassword  string
+
+	TLSEnabled            bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+}
+
+// KafkaTransport 把事件发布到 Kafka
+// 按 CloudEvents Kafka binding 的二进制模式编码：消息体为事件 data，事件属性放在 ce_* 消息头中
+type KafkaTransport struct {
+	brokers []string
+	config  *sarama.Config
+	topic   string
+	key     string
+
+	mu       sync.Mutex
+	producer sarama.SyncProducer // 首次连接成功后创建，broker 不可用时为空
+	closed   bool
+
+	// sending 同一时间只有一个后台 goroutine 在连接和发布，
+	// ctx 结束后该 goroutine 仍会等 sarama 返回，broker 不可用期间不能为每次重试都新起一个
+	sending chan struct{}
+}
+
+// NewKafkaTransport 创建 Kafka sink
+// broker 不可用时不返回错误：生产者在之后发布时再创建，期间发布失败的事件保存在 outbox 中重试
+func NewKafkaTransport(opts KafkaOptions) (*KafkaTransport, error) {
+	if len(opts.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka brokers are not configured")
+	}
+	cfg, err := newSaramaConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	topic := opts.Topic
+	if topic == "" {
+		topic = defaultKafkaTopic
+	}
+	key := opts.Key
+	if key == "" {
+		key = defaultKafkaKey
+	}
+
+	logrus.Infof("[Kafka] Configured kafka sink: brokers=%v, topic=%s, idempotent=%v, sasl=%s, tls=%v",
+		opts.Brokers, topic, opts.Idempotent, opts.SASLMechanism, opts.TLSEnabled)
+	t := &KafkaTransport{brokers: opts.Brokers, config: cfg, topic: topic, key: key, sending: make(chan struct{}, 1)}
+	if _, err := t.connect(); err != nil {
+		logrus.Warnf("[Kafka] %v, will retry on next publish", err)
+	}
+	return t, nil
+}
+
+// connect 返回生产者，尚未创建时连接 broker 创建
+func (t *KafkaTransport) connect() (sarama.SyncProducer, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, fmt.Errorf("kafka sink is closed")
+	}
+	if t.producer != nil {
+		return t.producer, nil
+	}
+	producer, err := sarama.NewSyncProducer(t.brokers, t.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka producer for %v: %w", t.brokers, err)
+	}
+	t.producer = producer
+	return producer, nil
+}
+
+func newSaramaConfig(opts KafkaOptions) (*sarama.Config, error) {
+	cfg := sarama.NewConfig()
+	version := opts.Version
+	if version == "" {
+		version = defaultKafkaVersion
+	}
+	v, err := sarama.ParseKafkaVersion(version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kafka version %q: %w", version, err)
+	}
+	cfg.Version = v
+	if opts.ClientID != "" {
+		cfg.ClientID = opts.ClientID
+	}
+
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Retry.Max = 5
+	cfg.Producer.Retry.Backoff = 500 * time.Millisecond
+	if opts.Idempotent {
+		// 幂等生产者要求 acks=all 且同一连接上最多一个未完成的请求
+		cfg.Producer.Idempotent = true
+		cfg.Net.MaxOpenRequests = 1
+	}
+
+	if opts.SASLMechanism != "" {
+		cfg.Net.SASL.Enable = true
+		cfg.Net.SASL.User = opts.SASLUsername
+		cfg.Net.SASL.Password = opts.SASLPassword
+		switch strings.ToUpper(opts.SASLMechanism) {
+		case sarama.SASLTypePlaintext:
+			cfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		case sarama.SASLTypeSCRAMSHA256:
+			cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+			cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &scramClient{HashGeneratorFcn: scram.HashGeneratorFcn(sha256.New)}
+			}
+		case sarama.SASLTypeSCRAMSHA512:
+			cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+			cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &scramClient{HashGeneratorFcn: scram.HashGeneratorFcn(sha512.New)}
+			}
+		default:
+			return nil, fmt.Errorf("unsupported kafka SASL mechanism %q (expect PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512)", opts.SASLMechanism)
+		}
+	}
+
+	if opts.TLSEnabled {
+		tlsCfg, err := newKafkaTLSConfig(opts)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Net.TLS.Enable = true
+		cfg.Net.TLS.Config = tlsCfg
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kafka config: %w", err)
+	}
+	return cfg, nil
+}
+
+func newKafkaTLSConfig(opts KafkaOptions) (*tls.Config, error) {
+	tlsCfg := &tls.Config{InsecureSkipVerify: opts.TLSInsecureSkipVerify}
+	if opts.TLSCAFile != "" {
+		ca, err := os.ReadFile(opts.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kafka CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in kafka CA file %s", opts.TLSCAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if opts.TLSCertFile != "" || opts.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kafka client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+// scramClient 基于 xdg-go/scram 实现 sarama.SCRAMClient
+type scramClient struct {
+	*scram.ClientConversation
+	scram.HashGeneratorFcn
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.HashGeneratorFcn.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.ClientConversation = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.ClientConversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.ClientConversation.Done()
+}
+
+var keyPlaceholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// messageKey 渲染消息 key，相同 key 的事件进入同一分区，保证顺序
+func (t *KafkaTransport) messageKey(event *CloudEvent) string {
+	return keyPlaceholder.ReplaceAllStringFunc(t.key, func(m string) string {
+		field := m[1 : len(m)-1]
+		switch field {
+		case "type":
+			return event.Type
+		case "id":
+			return event.ID
+		}
+		if v, ok := event.Data[field]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	})
+}
+
+func (t *KafkaTransport) message(event *CloudEvent) (*sarama.ProducerMessage, error) {
+	value, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	headers := []sarama.RecordHeader{
+		{Key: []byte("ce_specversion"), Value: []byte(event.SpecVersion)},
+		{Key: []byte("ce_type"), Value: []byte(event.Type)},
+		{Key: []byte("ce_source"), Value: []byte(event.Source)},
+		{Key: []byte("ce_id"), Value: []byte(event.ID)},
+		{Key: []byte("ce_time"), Value: []byte(event.Time)},
+		{Key: []byte("content-type"), Value: []byte(event.DataContentType)},
+	}
+	if event.Subject != "" {
+		headers = append(headers, sarama.RecordHeader{Key: []byte("ce_subject"), Value: []byte(event.Subject)})
+	}
+	if event.DataSchema != "" {
+		headers = append(headers, sarama.RecordHeader{Key: []byte("ce_dataschema"), Value: []byte(event.DataSchema)})
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic:   t.topic,
+		Value:   sarama.ByteEncoder(value),
+		Headers: headers,
+	}
+	if key := t.messageKey(event); key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	return msg, nil
+}
+
+// Send 同步发布事件，等待所有 ISR 副本确认
+func (t *KafkaTransport) Send(ctx context.Context, event *CloudEvent) error {
+	return t.SendBatch(ctx, []*CloudEvent{event})
+}
+
+// SendBatch 批量发布事件
+// sarama 不支持 context，ctx 结束时不再等待发布结果直接返回错误，事件留在 outbox 中重试（ID 不变，下游可去重）；
+// 上一次发布尚未返回时等待它结束，ctx 先结束则直接返回错误
+func (t *KafkaTransport) SendBatch(ctx context.Context, events []*CloudEvent) error {
+	msgs := make([]*sarama.ProducerMessage, 0, len(events))
+	for _, event := range events {
+		msg, err := t.message(event)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case t.sending <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to publish to kafka topic %s: %w", t.topic, ctx.Err())
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer func() { <-t.sending }()
+		producer, err := t.connect()
+		if err != nil {
+			done <- err
+			return
+		}
+		// 连接期间 ctx 已结束时不再发布，事件由 outbox 重试
+		if err := ctx.Err(); err != nil {
+			done <- err
+			return
+		}
+		done <- producer.SendMessages(msgs)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to publish to kafka topic %s: %w", t.topic, err)
+		}
+	case <-ctx.Done():
+		return fmt.Errorf("failed to publish to kafka topic %s: %w", t.topic, ctx.Err())
+	}
+	logrus.Debugf("[Kafka] %d events published to %s", len(events), t.topic)
+	return nil
+}
+
+// BatchSize 单次发布的事件数上限
+func (t *KafkaTransport) BatchSize() int {
+	return 100
+}
+
+// Close 关闭生产者，等待未完成的消息发送结束
+func (t *KafkaTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	logrus.Info("[Kafka] kafka sink closed")
+	if t.producer == nil {
+		return nil
+	}
+	return t.producer.Close()
+}
@@ -0,0 +1,192 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// runKafkaBroker 启动一个单节点的 Kafka 替身
+func runKafkaBroker(t *testing.T, topic string) *sarama.MockBroker {
+	t.Helper()
+	return runKafkaBrokerAddr(t, topic, "127.0.0.1:0")
+}
+
+// runKafkaBrokerAddr 在指定地址启动 Kafka 替身
+func runKafkaBrokerAddr(t *testing.T, topic, addr string) *sarama.MockBroker {
+	t.Helper()
+	broker := sarama.NewMockBrokerAddr(t, 1, addr)
+	t.Cleanup(broker.Close)
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(topic, 0, broker.BrokerID()),
+		"InitProducerIDRequest": sarama.NewMockInitProducerIDResponse(t).
+			SetProducerID(1000).SetProducerEpoch(0),
+		"ProduceRequest": sarama.NewMockProduceResponse(t).
+			SetVersion(7).SetError(topic, 0, sarama.ErrNoError),
+	})
+	return broker
+}
+
+// countRequests 统计替身收到的各类请求数
+func countRequests(broker *sarama.MockBroker) (initProducerID, produce int) {
+	for _, rr := range broker.History() {
+		switch rr.Request.(type) {
+		case *sarama.InitProducerIDRequest:
+			initProducerID++
+		case *sarama.ProduceRequest:
+			produce++
+		}
+	}
+	return
+}
+
+func TestKafkaTransport(t *testing.T) {
+	const topic = "coredog.events"
+	broker := runKafkaBroker(t, topic)
+
+	transport, err := NewKafkaTransport(KafkaOptions{
+		Brokers:    []string{broker.Addr()},
+		Topic:      topic,
+		Key:        "{pod_namespace}/{pod_name}",
+		Idempotent: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewWithTransport(transport, Options{Name: "Kafka", EventTypes: []string{EventTypeUploaded}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不在 EventTypes 中的事件不发布
+	detected := &CoredumpDetectedData{CoredumpRef: testData().CoredumpRef, FilePath: "/corefile/core.app.1"}
+	if err := r.Report(context.Background(), EventTypeDetected, detected.FileName, detected); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReportCoredumpUploaded(context.Background(), testData()); err != nil {
+		t.Fatal(err)
+	}
+	// 实时事件攒批发送，Close 时发送队列中剩余的事件
+	r.Close()
+
+	initProducerID, produce := countRequests(broker)
+	if initProducerID != 1 {
+		t.Errorf("expected idempotent producer to request a producer ID, got %d requests", initProducerID)
+	}
+	if produce != 1 {
+		t.Fatalf("expected 1 produce request, got %d", produce)
+	}
+}
+
+func TestKafkaBrokerUnavailable(t *testing.T) {
+	const topic = "coredog.events"
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	// broker 不可用时不影响启动
+	transport, err := NewKafkaTransport(KafkaOptions{Brokers: []string{addr}, Topic: topic})
+	if err != nil {
+		t.Fatalf("broker outage should not fail startup: %v", err)
+	}
+	defer transport.Close()
+
+	event := &CloudEvent{ID: NewEventID(), Type: EventTypeUploaded, Data: map[string]interface{}{}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := transport.Send(ctx, event); err == nil {
+		t.Fatal("expected error while broker is unavailable")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("send ignored context deadline, took %v", elapsed)
+	}
+
+	// broker 恢复后创建生产者并发布
+	broker := runKafkaBrokerAddr(t, topic, addr)
+	if err := transport.Send(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if _, produce := countRequests(broker); produce != 1 {
+		t.Errorf("expected 1 produce request, got %d", produce)
+	}
+}
+
+// 上一次发布未返回时不再起新的 goroutine，ctx 结束即返回
+func TestKafkaSendBounded(t *testing.T) {
+	transport := &KafkaTransport{topic: "coredog.events", sending: make(chan struct{}, 1)}
+	transport.sending <- struct{}{}
+
+	event := &CloudEvent{ID: NewEventID(), Type: EventTypeUploaded, Data: map[string]interface{}{}}
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := transport.Send(ctx, event)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected send to wait for the running publish until deadline, got %v", err)
+		}
+	}
+	if n := len(transport.sending); n != 1 {
+		t.Errorf("expected the running publish to keep the only slot, got %d", n)
+	}
+}
+
+func TestKafkaMessage(t *testing.T) {
+	transport := &KafkaTransport{topic: "coredog.events", key: "{pod_namespace}/{pod_name}"}
+	data, _ := toData(testData())
+	event := &CloudEvent{
+		SpecVersion:     "1.0",
+		Type:            EventTypeUploaded,
+		Source:          "coredog-agent",
+		ID:              NewEventID(),
+		Subject:         "core.app.1",
+		DataContentType: "application/json",
+		DataSchema:      DataSchemaURI(EventTypeUploaded),
+		Data:            data,
+	}
+
+	msg, err := transport.message(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, _ := msg.Key.Encode(); string(key) != "default/app-0" {
+		t.Errorf("unexpected message key %q", key)
+	}
+
+	value, _ := msg.Value.Encode()
+	var payload CoredumpUploadedData
+	if err := json.Unmarshal(value, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.MD5 != testData().MD5 || payload.FileURL != testData().FileURL {
+		t.Errorf("unexpected payload: %s", value)
+	}
+
+	headers := make(map[string]string)
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	if headers["ce_type"] != EventTypeUploaded || headers["ce_specversion"] != "1.0" || headers["ce_id"] != event.ID ||
+		headers["ce_subject"] != "core.app.1" || headers["ce_dataschema"] != DataSchemaURI(EventTypeUploaded) ||
+		headers["content-type"] != "application/json" {
+		t.Errorf("unexpected headers: %v", headers)
+	}
+}
+
+func TestKafkaInvalidSASLMechanism(t *testing.T) {
+	_, err := NewKafkaTransport(KafkaOptions{Brokers: []string{"127.0.0.1:9092"}, SASLMechanism: "GSSAPI-X"})
+	if err == nil {
+		t.Fatal("expected error for unsupported SASL mechanism")
+	}
+}
@@ -0,0 +1,139 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultNATSSubject = "coredog.events"
+
+// NATSTransport 通过 NATS 投递事件
+// JetStream 模式下等待服务端 PubAck，保证事件已持久化；否则为 core NATS 发布 + Flush
+type NATSTransport struct {
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	subject string
+}
+
+// NewNATSTransport 连接 NATS 服务器
+// 支持 token 认证和 nkey 认证（seed 或 seed 文件）
+func NewNATSTransport(opts Options) (*NATSTransport, error) {
+	subject := opts.Subject
+	if subject == "" {
+		subject = defaultNATSSubject
+	}
+
+	natsOpts := []nats.Option{
+		nats.Name("coredog-agent"),
+		// 启动时 NATS 不可用不应导致 agent 退出，连接会在后台重试
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2 * time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				logrus.Warnf("[CoreSight] NATS disconnected: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			logrus.Infof("[CoreSight] NATS reconnected to %s", nc.ConnectedUrl())
+		}),
+	}
+	if opts.Token != "" {
+		natsOpts = append(natsOpts, nats.Token(opts.Token))
+	}
+	switch {
+	case opts.NKeySeedFile != "":
+		opt, err := nats.NkeyOptionFromSeed(opts.NKeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load NATS nkey seed file: %w", err)
+		}
+		natsOpts = append(natsOpts, opt)
+	case opts.NKeySeed != "":
+		opt, err := nkeyOptionFromSeed(opts.NKeySeed)
+		if err != nil {
+			return nil, err
+		}
+		natsOpts = append(natsOpts, opt)
+	}
+
+	conn, err := nats.Connect(opts.URL, natsOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS %s: %w", opts.URL, err)
+	}
+
+	t := &NATSTransport{conn: conn, subject: subject}
+	if opts.JetStream {
+		js, err := conn.JetStream()
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+		}
+		t.js = js
+	}
+
+	logrus.Infof("[CoreSight] Configured NATS reporter: %s (subject: %s, jetstream: %v)", opts.URL, subject, opts.JetStream)
+	return t, nil
+}
+
+// nkeyOptionFromSeed 使用 seed 字符串（通常来自 Secret 环境变量）构造 nkey 认证选项
+func nkeyOptionFromSeed(seed string) (nats.Option, error) {
+	kp, err := nkeys.FromSeed([]byte(seed))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS nkey seed: %w", err)
+	}
+	pub, err := kp.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS nkey seed: %w", err)
+	}
+	return nats.Nkey(pub, kp.Sign), nil
+}
+
+// Send 发布事件，subject 为 <subject>.<event type>，便于下游按类型订阅
+func (t *NATSTransport) Send(ctx context.Context, event *CloudEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	msg := nats.NewMsg(t.subject + "." + event.Type)
+	msg.Data = body
+	msg.Header.Set("Content-Type", "application/cloudevents+json")
+	// JetStream 根据 Nats-Msg-Id 去重，重试不会产生重复事件
+	msg.Header.Set(nats.MsgIdHdr, event.ID)
+
+	if t.js != nil {
+		ack, err := t.js.PublishMsg(msg, nats.Context(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to publish event to JetStream subject %s: %w", msg.Subject, err)
+		}
+		logrus.Debugf("[CoreSight] event %s acked by stream %s (seq: %d, duplicate: %v)", event.ID, ack.Stream, ack.Sequence, ack.Duplicate)
+		return nil
+	}
+
+	if err := t.conn.PublishMsg(msg); err != nil {
+		return fmt.Errorf("failed to publish event to NATS subject %s: %w", msg.Subject, err)
+	}
+	if err := t.conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush NATS connection: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭 NATS 连接，等待缓冲区中的消息发送完毕
+func (t *NATSTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	if err := t.conn.Drain(); err != nil {
+		t.conn.Close()
+		return err
+	}
+	logrus.Info("[CoreSight] NATS reporter closed")
+	return nil
+}
@@ -0,0 +1,340 @@
+package reporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	outboxOpPending   = "pending"
+	outboxOpDelivered = "delivered"
+
+	defaultOutboxRetention = 7 * 24 * time.Hour
+	outboxMinBackoff       = 5 * time.Second
+	outboxMaxBackoff       = 10 * time.Minute
+	// outboxCompactInterval agent 运行期间压缩 outbox 的周期
+	outboxCompactInterval = time.Hour
+)
+
+// outboxRecord outbox 文件中的一行
+// 事件发送前写入 pending 记录（包含完整事件），投递成功后追加 delivered 记录
+type outboxRecord struct {
+	Op    string      `json:"op"`
+	ID    string      `json:"id"`
+	Time  time.Time   `json:"time"`
+	Event *CloudEvent `json:"event,omitempty"`
+}
+
+// pendingEvent 尚未投递成功的事件及其重试状态
+type pendingEvent struct {
+	event       *CloudEvent
+	recordedAt  time.Time
+	attempts    int
+	nextAttempt time.Time
+	// inflight 刚记录的事件正在实时发送，发送返回（MarkDelivered/MarkFailed）之前不参与重试，避免重复投递
+	inflight bool
+}
+
+// Outbox 本地持久化的事件发件箱（append-only JSON Lines 文件）
+// 保证 CoreSight 不可用期间的事件不丢失，并支持按时间范围重放。
+// 同一时间只有一个进程（agent）可以写入，由 <path>.lock 上的 flock 保证；其他进程只能只读打开
+type Outbox struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File // 只读打开时为空
+	lock      *os.File
+	pending   map[string]*pendingEvent
+	retention time.Duration
+}
+
+// OpenOutbox 打开（或创建）outbox 文件用于写入
+// 打开时会恢复未投递的事件，并清理超过 retention 的记录；其他进程正在写入时返回错误
+func OpenOutbox(path string, retention time.Duration) (*Outbox, error) {
+	if retention <= 0 {
+		retention = defaultOutboxRetention
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox lock: %w", err)
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("outbox %s is in use by another process: %w", path, err)
+	}
+
+	o := &Outbox{
+		path:      path,
+		lock:      lock,
+		pending:   make(map[string]*pendingEvent),
+		retention: retention,
+	}
+	if err := o.compactLocked(); err != nil {
+		o.Close()
+		return nil, err
+	}
+
+	if len(o.pending) > 0 {
+		logrus.Infof("[CoreSight] outbox %s has %d undelivered events, will retry", path, len(o.pending))
+	}
+	return o, nil
+}
+
+// ReadOutbox 只读打开 outbox，用于 events replay 等与 agent 同时运行的命令
+// 不压缩文件、不加锁，也不记录投递结果
+func ReadOutbox(path string) *Outbox {
+	return &Outbox{path: path, pending: make(map[string]*pendingEvent)}
+}
+
+// ReadOnly outbox 是否为只读打开
+func (o *Outbox) ReadOnly() bool {
+	return o.lock == nil
+}
+
+// readRecords 读取 outbox 中的所有记录，忽略损坏的行（例如写入过程中节点掉电）
+func readRecords(path string) ([]outboxRecord, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var records []outboxRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec outboxRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			logrus.Warnf("[CoreSight] skip corrupted outbox record: %v", err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox %s: %w", path, err)
+	}
+	return records, nil
+}
+
+// Compact 压缩 outbox 文件，agent 运行期间定期执行，避免文件无限增长
+func (o *Outbox) Compact() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.ReadOnly() {
+		return nil
+	}
+	return o.compactLocked()
+}
+
+// compactLocked 重写 outbox 文件：丢弃超过 retention 的已投递事件，恢复未投递事件，然后重新打开写入的文件
+// 未投递的事件无论多旧都会保留；已在内存中的未投递事件保留其重试状态
+func (o *Outbox) compactLocked() error {
+	records, err := readRecords(o.path)
+	if err != nil {
+		return err
+	}
+
+	delivered := make(map[string]outboxRecord)
+	for _, rec := range records {
+		if rec.Op == outboxOpDelivered {
+			delivered[rec.ID] = rec
+		}
+	}
+
+	cutoff := time.Now().Add(-o.retention)
+	var kept []outboxRecord
+	for _, rec := range records {
+		if rec.Op != outboxOpPending || rec.Event == nil {
+			continue
+		}
+		d, ok := delivered[rec.ID]
+		if !ok {
+			if _, known := o.pending[rec.ID]; !known {
+				o.pending[rec.ID] = &pendingEvent{event: rec.Event, recordedAt: rec.Time}
+			}
+			kept = append(kept, rec)
+			continue
+		}
+		if rec.Time.After(cutoff) {
+			kept = append(kept, rec, d)
+		}
+	}
+
+	tmp := o.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for i := range kept {
+		if err := enc.Encode(&kept[i]); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to compact outbox: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	f.Close()
+	if err := os.Rename(tmp, o.path); err != nil {
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+
+	// 重命名后旧的文件描述符指向已被替换的文件，必须重新打开
+	file, err := os.OpenFile(o.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox %s: %w", o.path, err)
+	}
+	if o.file != nil {
+		o.file.Close()
+	}
+	o.file = file
+	return nil
+}
+
+func (o *Outbox) write(rec outboxRecord) error {
+	if o.file == nil {
+		return fmt.Errorf("outbox %s is read-only", o.path)
+	}
+	data, err := json.Marshal(&rec)
+	if err != nil {
+		return err
+	}
+	if _, err := o.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return o.file.Sync()
+}
+
+// Append 在发送前记录事件
+func (o *Outbox) Append(event *CloudEvent) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now().UTC()
+	if err := o.write(outboxRecord{Op: outboxOpPending, ID: event.ID, Time: now, Event: event}); err != nil {
+		return fmt.Errorf("failed to append event %s to outbox: %w", event.ID, err)
+	}
+	// 刚记录的事件正在发送，无论发送耗时多久，都要等发送返回后才参与重试
+	o.pending[event.ID] = &pendingEvent{event: event, recordedAt: now, inflight: true}
+	return nil
+}
+
+// MarkDelivered 标记事件已投递成功
+func (o *Outbox) MarkDelivered(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.write(outboxRecord{Op: outboxOpDelivered, ID: id, Time: time.Now().UTC()}); err != nil {
+		return fmt.Errorf("failed to mark event %s delivered: %w", id, err)
+	}
+	delete(o.pending, id)
+	return nil
+}
+
+// MarkFailed 记录一次投递失败，按指数退避计算下次重试时间
+func (o *Outbox) MarkFailed(id string) time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	p, ok := o.pending[id]
+	if !ok {
+		return 0
+	}
+	p.inflight = false
+	p.attempts++
+	backoff := outboxMinBackoff << uint(min(p.attempts-1, 10))
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	p.nextAttempt = time.Now().Add(backoff)
+	return backoff
+}
+
+// Due 返回已到重试时间的未投递事件，按记录时间排序，正在实时发送的事件除外
+func (o *Outbox) Due(now time.Time) []*CloudEvent {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var due []*pendingEvent
+	for _, p := range o.pending {
+		if !p.inflight && !p.nextAttempt.After(now) {
+			due = append(due, p)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].recordedAt.Before(due[j].recordedAt) })
+
+	events := make([]*CloudEvent, 0, len(due))
+	for _, p := range due {
+		events = append(events, p.event)
+	}
+	return events
+}
+
+// PendingCount 返回未投递事件数
+func (o *Outbox) PendingCount() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.pending)
+}
+
+// Events 返回记录时间在 [since, until) 范围内的所有事件（无论是否已投递），用于重放
+// until 为零值表示不限制结束时间
+func (o *Outbox) Events(since, until time.Time) ([]*CloudEvent, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	records, err := readRecords(o.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*CloudEvent
+	for _, rec := range records {
+		if rec.Op != outboxOpPending || rec.Event == nil {
+			continue
+		}
+		if rec.Time.Before(since) || (!until.IsZero() && !rec.Time.Before(until)) {
+			continue
+		}
+		events = append(events, rec.Event)
+	}
+	return events, nil
+}
+
+// Close 关闭 outbox 文件并释放写入锁
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var err error
+	if o.file != nil {
+		err = o.file.Close()
+		o.file = nil
+	}
+	if o.lock != nil {
+		o.lock.Close()
+		o.lock = nil
+	}
+	return err
+}
@@ -1,48 +1,91 @@
 package reporter
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
-	"os"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// DefaultSource 未配置集群时事件的 source 属性
+const DefaultSource = "coredog-agent"
+
+const (
+	defaultBatchLinger = time.Second // 实时事件攒批的默认等待时间
+	liveQueueSize      = 1024        // 等待攒批发送的实时事件队列长度
+)
+
 // CloudEvent 遵循 CloudEvents 1.0 规范
 type CloudEvent struct {
 	SpecVersion     string                 `json:"specversion"`
 	Type            string                 `json:"type"`
 	Source          string                 `json:"source"`
 	ID              string                 `json:"id"`
+	Subject         string                 `json:"subject,omitempty"`
 	Time            string                 `json:"time"`
 	DataContentType string                 `json:"datacontenttype"`
+	DataSchema      string                 `json:"dataschema,omitempty"`
 	Token           string                 `json:"token,omitempty"`
 	Data            map[string]interface{} `json:"data"`
 }
 
-// CoredumpUploadedData 上传成功的 core dump 数据
+// CoredumpUploadedData coredog.coredump.uploaded 事件数据
+// 元数据不完整时仍然上报：unresolved 列出未能解析的字段，completeness 为已解析字段的比例（0~1）
 type CoredumpUploadedData struct {
-	FileURL        string `json:"file_url"`
-	FileName       string `json:"file_name"`
-	ExecutablePath string `json:"executable_path"`
-	FileSize       int64  `json:"file_size"`
-	MD5            string `json:"md5"`
-	Image          string `json:"image"`
-	Timestamp      string `json:"timestamp"`
-	PodName        string `json:"pod_name"`
-	PodNamespace   string `json:"pod_namespace"`
-	NodeIP         string `json:"node_ip,omitempty"` // Pod 所在节点的 IP，从 status.hostIP 获取
-}
-
-// Reporter 负责向 CoreSight 上报事件（通过 HTTP API）
+	CoredumpRef
+	FileURL        string       `json:"file_url"`
+	ExecutablePath string       `json:"executable_path"`
+	FileSize       int64        `json:"file_size"`
+	MD5            string       `json:"md5"`
+	Image          string       `json:"image"`
+	Unresolved     []string     `json:"unresolved"`
+	Completeness   float64      `json:"completeness"`
+	LegacyPath     bool         `json:"legacy_path,omitempty"` // 来自已废弃的 admission-uid 路径格式
+	Pod            *PodMetadata `json:"pod,omitempty"`         // Pod 未解析时为空
+}
+
+// Reporter 负责向 CoreSight 上报事件
+// 具体投递方式由 Transport 决定：http(s):// 使用 HTTP API，nats:// 使用 NATS/JetStream
 type Reporter struct {
-	httpClient *http.Client
-	apiURL     string
-	token      string
+	name       string // 日志前缀，默认 CoreSight
+	source     string // CloudEvent source 属性
+	transport  Transport
+	eventTypes map[string]bool // 只上报这些类型的事件，为空表示全部
+	outbox     *Outbox         // 为空时不做持久化，发送失败即丢弃
+	stop       chan struct{}
+	done       chan struct{}
+
+	// 传输支持批量投递时，实时事件先进入 live 队列，攒满一批或等待 linger 后一次发送
+	live     chan *CloudEvent
+	liveDone chan struct{}
+	linger   time.Duration
+}
+
+// Options reporter 配置
+type Options struct {
+	URL          string // CoreSight 地址，按 scheme 选择传输方式
+	Token        string // HTTP: Bearer token；NATS: token 认证
+	Subject      string // NATS subject，默认 coredog.events
+	NKeySeed     string // NATS nkey seed（SU...），与 NKeySeedFile 二选一
+	NKeySeedFile string // NATS nkey seed 文件路径
+	JetStream    bool   // NATS 是否使用 JetStream 发布并等待 ack
+
+	HTTPMode      string        // HTTP 内容模式：legacy（默认）、structured、binary、batch
+	SigningSecret string        // HTTP 请求 HMAC-SHA256 签名密钥，为空时不签名
+	BatchSize     int           // batch 模式下单个请求最多包含的事件数，默认 20
+	BatchLinger   time.Duration // 实时事件攒批的最长等待时间，默认 1s
+
+	OutboxPath      string        // outbox 文件路径，为空时不启用 outbox
+	OutboxRetention time.Duration // 已投递事件在 outbox 中的保留时间，默认 7 天
+	DisableRetry    bool          // 不启动后台重试（用于 events replay 等一次性命令）
+	OutboxReadOnly  bool          // 只读打开 outbox，不压缩、不记录投递结果（用于与 agent 同时运行的命令）
+
+	Name       string   // 日志中的 sink 名称，默认 CoreSight
+	Source     string   // CloudEvent source 属性，默认 coredog-agent
+	EventTypes []string // 只上报这些类型的事件，为空表示全部
 }
 
 // NewReporter 创建新的 reporter（使用 HTTP API）
@@ -52,99 +95,332 @@ func NewReporter(apiURL string) *Reporter {
 
 // NewReporterWithToken 创建新的 reporter（使用 HTTP API，并设置 token）
 func NewReporterWithToken(apiURL string, token string) *Reporter {
-	if apiURL == "" {
-		logrus.Warn("CoreSight API URL is not configured, event reporting disabled")
+	r, err := New(Options{URL: apiURL, Token: token})
+	if err != nil {
+		logrus.Warnf("failed to create CoreSight reporter: %v", err)
 		return nil
 	}
+	return r
+}
+
+// New 根据 URL scheme 创建 reporter
+// URL 为空时返回 nil（上报关闭）
+func New(opts Options) (*Reporter, error) {
+	if opts.URL == "" {
+		logrus.Warn("CoreSight API URL is not configured, event reporting disabled")
+		return nil, nil
+	}
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CoreSight URL %q: %w", opts.URL, err)
 	}
 
-	logrus.Infof("[CoreSight] Configured HTTP reporter: %s", apiURL)
-	return &Reporter{
-		httpClient: client,
-		apiURL:     apiURL,
-		token:      token,
+	var transport Transport
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		transport, err = NewHTTPTransport(opts)
+		if err != nil {
+			return nil, err
+		}
+	case "nats", "tls":
+		transport, err = NewNATSTransport(opts)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported CoreSight URL scheme %q (expect http, https or nats)", u.Scheme)
 	}
+
+	r, err := NewWithTransport(transport, opts)
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewWithTransport 使用指定的传输创建 reporter，用于 Kafka 等不通过 URL 配置的 sink
+func NewWithTransport(transport Transport, opts Options) (*Reporter, error) {
+	r := &Reporter{name: opts.Name, source: opts.Source, transport: transport}
+	if r.name == "" {
+		r.name = "CoreSight"
+	}
+	if r.source == "" {
+		r.source = DefaultSource
+	}
+	if len(opts.EventTypes) > 0 {
+		r.eventTypes = make(map[string]bool)
+		for _, t := range opts.EventTypes {
+			r.eventTypes[t] = true
+		}
+	}
+	if opts.OutboxPath != "" && opts.OutboxReadOnly {
+		r.outbox = ReadOutbox(opts.OutboxPath)
+	} else if opts.OutboxPath != "" {
+		var err error
+		r.outbox, err = OpenOutbox(opts.OutboxPath, opts.OutboxRetention)
+		if err != nil {
+			return nil, err
+		}
+		if !opts.DisableRetry {
+			r.stop = make(chan struct{})
+			r.done = make(chan struct{})
+			go r.retryLoop()
+		}
+	}
+	if r.batchSize() > 1 && !opts.DisableRetry {
+		r.linger = opts.BatchLinger
+		if r.linger <= 0 {
+			r.linger = defaultBatchLinger
+		}
+		r.live = make(chan *CloudEvent, liveQueueSize)
+		r.liveDone = make(chan struct{})
+		go r.batchLoop()
+	}
+	return r, nil
 }
 
-// Close 关闭 reporter 连接
+// Close 停止重试并关闭 reporter 连接
 func (r *Reporter) Close() error {
-	if r != nil && r.httpClient != nil {
-		logrus.Info("[CoreSight] HTTP reporter closed")
+	if r == nil || r.transport == nil {
+		return nil
+	}
+	// 先发送队列中剩余的实时事件，再停止重试
+	if r.live != nil {
+		close(r.live)
+		<-r.liveDone
+	}
+	if r.stop != nil {
+		close(r.stop)
+		<-r.done
+	}
+	if r.outbox != nil {
+		r.outbox.Close()
+	}
+	return r.transport.Close()
+}
+
+// send 发送事件
+// 启用 outbox 时先落盘再发送，发送成功后标记为已投递；发送失败的事件由后台按指数退避重试
+func (r *Reporter) send(ctx context.Context, event *CloudEvent) error {
+	if r.outbox == nil {
+		return r.transport.Send(ctx, event)
+	}
+
+	if err := r.outbox.Append(event); err != nil {
+		// outbox 不可写时仍然尝试直接发送
+		logrus.Errorf("[%s] %v", r.name, err)
+		return r.transport.Send(ctx, event)
+	}
+	if err := r.transport.Send(ctx, event); err != nil {
+		backoff := r.outbox.MarkFailed(event.ID)
+		return fmt.Errorf("%w (event %s kept in outbox, retry in %v)", err, event.ID, backoff)
+	}
+	if err := r.outbox.MarkDelivered(event.ID); err != nil {
+		logrus.Warnf("[%s] %v", r.name, err)
 	}
 	return nil
 }
 
-// ReportCoredumpUploaded 上报 coredump 上传事件到 CoreSight
-func (r *Reporter) ReportCoredumpUploaded(ctx context.Context, data *CoredumpUploadedData) error {
-	if r == nil || r.httpClient == nil {
-		return nil // 如果 reporter 未配置，则忽略
-	}
-
-	// 生成事件 ID
-	eventID := fmt.Sprintf("coredog-%d-%d", time.Now().Unix(), os.Getpid())
-
-	// 构建 CloudEvents 事件
-	event := &CloudEvent{
-		SpecVersion:     "1.0",
-		Type:            "coredog.coredump.uploaded",
-		Source:          "coredog-agent",
-		ID:              eventID,
-		Time:            time.Now().UTC().Format(time.RFC3339),
-		DataContentType: "application/json",
-		Token:           r.token,
-		Data: map[string]interface{}{
-			"file_url":        data.FileURL,
-			"file_name":       data.FileName,
-			"executable_path": data.ExecutablePath,
-			"file_size":       data.FileSize,
-			"md5":             data.MD5,
-			"image":           data.Image,
-			"timestamp":       data.Timestamp,
-			"pod_name":        data.PodName,
-			"pod_namespace":   data.PodNamespace,
-			"node_ip":         data.NodeIP,
-		},
-	}
-
-	// 序列化事件
-	body, err := json.Marshal(event)
-	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+// enqueue 把实时事件放入攒批队列，启用 outbox 时先落盘
+// 队列已满时直接发送，避免阻塞调用方
+func (r *Reporter) enqueue(ctx context.Context, event *CloudEvent) error {
+	if r.outbox != nil {
+		if err := r.outbox.Append(event); err != nil {
+			logrus.Errorf("[%s] %v", r.name, err)
+		}
+	}
+	select {
+	case r.live <- event:
+		return nil
+	default:
 	}
+	logrus.Warnf("[%s] live event queue is full, sending event %s directly", r.name, event.ID)
+	return r.deliver(ctx, []*CloudEvent{event})
+}
 
-	// 创建 HTTP 请求
-	req, err := http.NewRequestWithContext(ctx, "POST", r.apiURL, bytes.NewBuffer(body))
-	if err != nil {
-		return fmt.Errorf("failed to create HTTP request: %w", err)
+// batchLoop 收集实时事件，攒满 batchSize 个或第一个事件等待超过 linger 后批量发送
+func (r *Reporter) batchLoop() {
+	defer close(r.liveDone)
+	var (
+		pending []*CloudEvent
+		timer   = time.NewTimer(r.linger)
+	)
+	timer.Stop()
+	flush := func() {
+		timer.Stop()
+		if len(pending) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := r.deliver(ctx, pending); err != nil {
+			logrus.Warnf("[%s] %v", r.name, err)
+		}
+		cancel()
+		pending = nil
 	}
+	for {
+		select {
+		case event, ok := <-r.live:
+			if !ok {
+				flush()
+				return
+			}
+			pending = append(pending, event)
+			if len(pending) == 1 {
+				timer.Reset(r.linger)
+			}
+			if len(pending) >= r.batchSize() {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
 
-	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
-	if r.token != "" {
-		req.Header.Set("Authorization", "Bearer "+r.token)
+// deliver 批量发送实时事件并在 outbox 中记录结果；发送失败的事件由后台按指数退避重试
+func (r *Reporter) deliver(ctx context.Context, events []*CloudEvent) error {
+	if err := r.sendBatch(ctx, events); err != nil {
+		if r.outbox == nil {
+			return fmt.Errorf("failed to deliver %d events: %w", len(events), err)
+		}
+		var backoff time.Duration
+		for _, event := range events {
+			backoff = r.outbox.MarkFailed(event.ID)
+		}
+		return fmt.Errorf("failed to deliver %d events: %w (kept in outbox, retry in %v)", len(events), err, backoff)
 	}
+	for _, event := range events {
+		if r.outbox != nil {
+			if err := r.outbox.MarkDelivered(event.ID); err != nil {
+				logrus.Warnf("[%s] %v", r.name, err)
+			}
+		}
+		logrus.Debugf("[%s] delivered event %s (type: %s)", r.name, event.ID, event.Type)
+	}
+	return nil
+}
 
-	// 发送请求
-	resp, err := r.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send HTTP request to %s: %w", r.apiURL, err)
+// retryLoop 定期重发 outbox 中到期的未投递事件，并定期压缩 outbox
+func (r *Reporter) retryLoop() {
+	defer close(r.done)
+	ticker := time.NewTicker(outboxMinBackoff)
+	defer ticker.Stop()
+	compact := time.NewTicker(outboxCompactInterval)
+	defer compact.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.retryDue()
+		case <-compact.C:
+			if err := r.outbox.Compact(); err != nil {
+				logrus.Warnf("[%s] %v", r.name, err)
+			}
+		case <-r.stop:
+			return
+		}
 	}
-	defer resp.Body.Close()
+}
 
-	// 检查响应状态
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("HTTP request failed with status %d: %s", resp.StatusCode, resp.Status)
+func (r *Reporter) retryDue() {
+	due := r.outbox.Due(time.Now())
+	for len(due) > 0 {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+
+		batch := due[:min(r.batchSize(), len(due))]
+		due = due[len(batch):]
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := r.sendBatch(ctx, batch)
+		cancel()
+		if err != nil {
+			for _, event := range batch {
+				backoff := r.outbox.MarkFailed(event.ID)
+				logrus.Warnf("[%s] retry event %s failed: %v, next retry in %v", r.name, event.ID, err, backoff)
+			}
+			// CoreSight 仍不可用，本轮不再尝试其余事件
+			return
+		}
+		for _, event := range batch {
+			if err := r.outbox.MarkDelivered(event.ID); err != nil {
+				logrus.Warnf("[%s] %v", r.name, err)
+			}
+			logrus.Infof("[%s] redelivered event %s (type: %s)", r.name, event.ID, event.Type)
+		}
 	}
+}
 
-	logrus.Infof(
-		"[CoreSight] Successfully reported coredump uploaded event: %s (event_id: %s, status: %d)",
-		data.FileURL,
-		eventID,
-		resp.StatusCode,
-	)
+// batchSize 传输支持批量投递时返回单个请求的事件数上限，否则为 1
+func (r *Reporter) batchSize() int {
+	if bt, ok := r.transport.(BatchTransport); ok && bt.BatchSize() > 0 {
+		return bt.BatchSize()
+	}
+	return 1
+}
 
+// sendBatch 批量投递事件，传输不支持批量时逐个发送
+func (r *Reporter) sendBatch(ctx context.Context, events []*CloudEvent) error {
+	if bt, ok := r.transport.(BatchTransport); ok {
+		return bt.SendBatch(ctx, events)
+	}
+	for _, event := range events {
+		if err := r.transport.Send(ctx, event); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// Replay 重新发送 outbox 中记录时间在 [since, until) 范围内的事件（包括已投递的）
+// eventType 非空时只重放该类型的事件；dryRun 为 true 时只返回匹配的事件不发送。
+// 事件 ID 保持不变，接收端可据此去重
+func (r *Reporter) Replay(ctx context.Context, since, until time.Time, eventType string, dryRun bool) ([]*CloudEvent, error) {
+	if r == nil || r.outbox == nil {
+		return nil, fmt.Errorf("outbox is not enabled")
+	}
+
+	events, err := r.outbox.Events(since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*CloudEvent
+	for _, event := range events {
+		if eventType != "" && event.Type != eventType {
+			continue
+		}
+		matched = append(matched, event)
+	}
+	if dryRun {
+		return matched, nil
+	}
+
+	size := r.batchSize()
+	for i := 0; i < len(matched); i += size {
+		batch := matched[i:min(i+size, len(matched))]
+		if err := r.sendBatch(ctx, batch); err != nil {
+			return matched[:i], fmt.Errorf("failed to replay event %s: %w", batch[0].ID, err)
+		}
+		// 只读打开时不记录投递结果，未投递的事件仍由 agent 重试（事件 ID 不变，接收端可去重）
+		if r.outbox.ReadOnly() {
+			continue
+		}
+		for _, event := range batch {
+			if err := r.outbox.MarkDelivered(event.ID); err != nil {
+				logrus.Warnf("[%s] %v", r.name, err)
+			}
+		}
+	}
+	return matched, nil
+}
+
+// ReportCoredumpUploaded 上报 coredump 上传事件到 CoreSight
+func (r *Reporter) ReportCoredumpUploaded(ctx context.Context, data *CoredumpUploadedData) error {
+	return r.Report(ctx, EventTypeUploaded, data.FileName, data)
+}
@@ -0,0 +1,614 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// runNATSServer 启动一个内嵌的 NATS 服务器（开启 JetStream 和 token 认证）
+func runNATSServer(t *testing.T, token string) *server.Server {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{
+		Host:          "127.0.0.1",
+		Port:          -1,
+		JetStream:     true,
+		StoreDir:      t.TempDir(),
+		Authorization: token,
+		NoLog:         true,
+		NoSigs:        true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create nats server: %v", err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func testData() *CoredumpUploadedData {
+	return &CoredumpUploadedData{
+		CoredumpRef: CoredumpRef{
+			CoredumpID:   NewEventID(),
+			FileName:     "core.app.1",
+			PodName:      "app-0",
+			PodNamespace: "default",
+			Container:    "app",
+			NodeIP:       "10.0.0.1",
+			Timestamp:    time.Now().UTC().Format(time.RFC3339),
+		},
+		FileURL:        "https://bucket/corefiles/core.app.1",
+		ExecutablePath: "/app/bin/app",
+		FileSize:       1024,
+		MD5:            "d41d8cd98f00b204e9800998ecf8427e",
+		Image:          "registry/app:v1",
+		Unresolved:     []string{},
+		Completeness:   1,
+		LegacyPath:     true,
+		Pod: &PodMetadata{
+			UID:                   "9f8e7d6c-0000-0000-0000-000000000001",
+			NodeName:              "node-1",
+			WorkloadKind:          "Deployment",
+			WorkloadName:          "app",
+			ImageDigest:           "registry/app@sha256:0123456789abcdef",
+			RestartCount:          3,
+			LastTerminationReason: "Error",
+			LastExitCode:          139,
+			Labels:                map[string]string{"app": "app"},
+		},
+	}
+}
+
+func TestReporterNATSJetStream(t *testing.T) {
+	const token = "s3cr3t"
+	s := runNATSServer(t, token)
+
+	nc, err := nats.Connect(s.ClientURL(), nats.Token(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.AddStream(&nats.StreamConfig{Name: "COREDOG", Subjects: []string{"coredog.>"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := New(Options{URL: s.ClientURL(), Token: token, Subject: "coredog.events", JetStream: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+
+	if err := r.ReportCoredumpUploaded(context.Background(), testData()); err != nil {
+		t.Fatalf("report failed: %v", err)
+	}
+
+	sub, err := js.SubscribeSync("coredog.events.coredog.coredump.uploaded", nats.DeliverAll())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("no event received: %v", err)
+	}
+
+	var event CloudEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != "coredog.coredump.uploaded" || event.Data["md5"] != testData().MD5 {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if event.Token != "" {
+		t.Error("token must not be embedded in NATS events")
+	}
+	if msg.Header.Get(nats.MsgIdHdr) != event.ID {
+		t.Errorf("expected Nats-Msg-Id %q, got %q", event.ID, msg.Header.Get(nats.MsgIdHdr))
+	}
+}
+
+func TestReporterNATSJetStreamNoStream(t *testing.T) {
+	s := runNATSServer(t, "")
+
+	r, err := New(Options{URL: s.ClientURL(), JetStream: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := r.ReportCoredumpUploaded(ctx, testData()); err == nil {
+		t.Fatal("expected error when no stream acks the publish")
+	}
+}
+
+func TestReporterHTTP(t *testing.T) {
+	var gotAuth string
+	var gotEvent CloudEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &gotEvent)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	r, err := New(Options{URL: srv.URL, Token: "tok"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.ReportCoredumpUploaded(context.Background(), testData()); err != nil {
+		t.Fatalf("report failed: %v", err)
+	}
+	if gotAuth != "Bearer tok" {
+		t.Errorf("expected bearer token, got %q", gotAuth)
+	}
+	if gotEvent.Data["pod_name"] != "app-0" {
+		t.Errorf("unexpected event data: %+v", gotEvent.Data)
+	}
+}
+
+func TestNewUnsupportedScheme(t *testing.T) {
+	if _, err := New(Options{URL: "kafka://broker:9092"}); err == nil {
+		t.Fatal("expected error for unsupported scheme")
+	}
+	if r, err := New(Options{}); r != nil || err != nil {
+		t.Fatalf("expected nil reporter for empty URL, got %v, %v", r, err)
+	}
+}
+
+func TestReporterOutbox(t *testing.T) {
+	var fail bool
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event CloudEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		received = append(received, event.ID)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	outboxPath := filepath.Join(t.TempDir(), "outbox.jsonl")
+	opts := Options{URL: srv.URL, OutboxPath: outboxPath, DisableRetry: true}
+
+	r, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReportCoredumpUploaded(context.Background(), testData()); err != nil {
+		t.Fatal(err)
+	}
+
+	// CoreSight 不可用：事件保留在 outbox 中
+	fail = true
+	if err := r.ReportCoredumpUploaded(context.Background(), testData()); err == nil {
+		t.Fatal("expected error when CoreSight is down")
+	}
+	if n := r.outbox.PendingCount(); n != 1 {
+		t.Fatalf("expected 1 pending event, got %d", n)
+	}
+	r.Close()
+
+	// 重启后恢复未投递的事件并重试成功
+	fail = false
+	r, err = New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if n := r.outbox.PendingCount(); n != 1 {
+		t.Fatalf("expected 1 pending event after reopen, got %d", n)
+	}
+	r.retryDue()
+	if n := r.outbox.PendingCount(); n != 0 {
+		t.Fatalf("expected no pending events after retry, got %d", n)
+	}
+	if len(received) != 2 {
+		t.Fatalf("expected 2 delivered events, got %d", len(received))
+	}
+
+	// 重放包括已投递的事件，事件 ID 保持不变
+	events, err := r.Replay(context.Background(), time.Now().Add(-time.Hour), time.Time{}, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || received[2] != received[0] || received[3] != received[1] {
+		t.Fatalf("unexpected replay result: %d events, received %v", len(events), received)
+	}
+
+	events, err = r.Replay(context.Background(), time.Now().Add(time.Minute), time.Time{}, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no events in future range, got %d", len(events))
+	}
+}
+
+func TestOutboxSingleWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	o, err := OpenOutbox(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if _, err := OpenOutbox(path, time.Hour); err == nil {
+		t.Fatal("expected error when the outbox is already open for writing")
+	}
+
+	event := &CloudEvent{ID: "e1", Type: EventTypeUploaded}
+	if err := o.Append(event); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(path)
+
+	// 只读打开（events replay）：可以读取事件，不修改文件
+	ro := ReadOutbox(path)
+	events, err := ro.Events(time.Now().Add(-time.Hour), time.Time{})
+	if err != nil || len(events) != 1 || events[0].ID != "e1" {
+		t.Fatalf("read-only events = %v, %v", events, err)
+	}
+	if err := ro.MarkDelivered("e1"); err == nil {
+		t.Error("read-only outbox should not be writable")
+	}
+	if err := ro.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Error("read-only outbox modified the file")
+	}
+}
+
+func TestOutboxCompactWhileRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	o, err := OpenOutbox(path, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"delivered", "pending"} {
+		if err := o.Append(&CloudEvent{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := o.MarkDelivered("delivered"); err != nil {
+		t.Fatal(err)
+	}
+	o.MarkFailed("pending")
+	o.MarkFailed("pending") // 下次重试在 10s 后
+
+	if err := o.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	// 压缩后写入的事件必须写入新文件，而不是被替换掉的旧文件
+	if err := o.Append(&CloudEvent{ID: "after"}); err != nil {
+		t.Fatal(err)
+	}
+	o.MarkFailed("after")
+	if due := o.Due(time.Now().Add(outboxMinBackoff + time.Second)); len(due) != 1 || due[0].ID != "after" {
+		t.Errorf("retry state of pending events should survive compaction, due = %v", due)
+	}
+	o.Close()
+
+	records, err := readRecords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, rec := range records {
+		ids = append(ids, rec.Op+":"+rec.ID)
+	}
+	if strings.Join(ids, ",") != "pending:pending,pending:after" {
+		t.Errorf("records after compaction = %v", ids)
+	}
+}
+
+// 实时发送耗时超过最小退避时间时，retryLoop 不能同时重发同一个事件
+func TestOutboxInflight(t *testing.T) {
+	o, err := OpenOutbox(filepath.Join(t.TempDir(), "outbox.jsonl"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if err := o.Append(&CloudEvent{ID: "slow"}); err != nil {
+		t.Fatal(err)
+	}
+	if due := o.Due(time.Now().Add(time.Hour)); len(due) != 0 {
+		t.Errorf("in-flight event is due for retry: %v", due)
+	}
+	o.MarkFailed("slow")
+	if due := o.Due(time.Now().Add(time.Hour)); len(due) != 1 {
+		t.Errorf("failed event should be retried, due = %v", due)
+	}
+}
+
+func TestNewEventIDUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := NewEventID()
+		u, err := uuid.Parse(id)
+		if err != nil {
+			t.Fatalf("invalid event id %q: %v", id, err)
+		}
+		if u.Version() != 7 {
+			t.Fatalf("expected UUIDv7, got version %d", u.Version())
+		}
+		if seen[id] {
+			t.Fatalf("duplicate event id %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+// TestEventSchemas 确保每个事件类型都有 schema，且与事件数据结构保持一致
+func TestEventSchemas(t *testing.T) {
+	ref := testData().CoredumpRef
+	samples := map[string]interface{}{
+		EventTypeDetected:         &CoredumpDetectedData{CoredumpRef: ref, FilePath: "/corefile/core.app.1", FileSize: 1024},
+		EventTypeParseFailed:      &CoredumpParseFailedData{CoredumpRef: ref, Error: "not an ELF file"},
+		EventTypeUploadFailed:     &CoredumpUploadFailedData{CoredumpRef: ref, Protocol: "s3", Error: "timeout"},
+		EventTypeUploaded:         testData(),
+		EventTypeHandlerCompleted: &CoredumpHandlerCompletedData{CoredumpRef: ref, Success: false, DurationMs: 12, Error: "exit status 1"},
+		EventTypeDeletedLocal:     &CoredumpDeletedLocalData{CoredumpRef: ref, FilePath: "/corefile/core.app.1", Method: "rm"},
+		EventTypeEnriched:         &CoredumpEnrichedData{CoredumpRef: ref, Image: "registry/app:v1", Resolved: []string{"image"}, Unresolved: []string{}, Completeness: 1},
+		EventTypeQuotaExceeded:    &CoredumpQuotaExceededData{CoredumpRef: ref, FilePath: "/corefile/core.app.1", FileSize: 4096, Reason: "max-core-size", Limit: 1024, Used: 4096, Rejected: 1, RejectedBytes: 4096, ExecutablePath: "/app/bin", Signal: "SIGSEGV", Image: "registry/app:v1", Pod: &PodMetadata{WorkloadName: "app"}},
+	}
+
+	for _, eventType := range EventTypes() {
+		t.Run(eventType, func(t *testing.T) {
+			raw, err := Schema(eventType)
+			if err != nil {
+				t.Fatalf("missing schema: %v", err)
+			}
+			var schema struct {
+				ID         string                     `json:"$id"`
+				Properties map[string]json.RawMessage `json:"properties"`
+				Required   []string                   `json:"required"`
+			}
+			if err := json.Unmarshal(raw, &schema); err != nil {
+				t.Fatalf("invalid schema: %v", err)
+			}
+			if schema.ID != DataSchemaURI(eventType) {
+				t.Errorf("schema $id = %q, want %q", schema.ID, DataSchemaURI(eventType))
+			}
+
+			data, err := toData(samples[eventType])
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key := range data {
+				if _, ok := schema.Properties[key]; !ok {
+					t.Errorf("field %q is not declared in schema", key)
+				}
+			}
+			for _, key := range schema.Required {
+				if _, ok := data[key]; !ok {
+					t.Errorf("required field %q is missing from event data", key)
+				}
+			}
+		})
+	}
+}
+
+func TestReportLifecycleEvent(t *testing.T) {
+	var gotEvent CloudEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gotEvent)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	r := NewReporter(srv.URL)
+	defer r.Close()
+
+	data := &CoredumpParseFailedData{CoredumpRef: testData().CoredumpRef, Error: "not an ELF file"}
+	if err := r.Report(context.Background(), EventTypeParseFailed, data.FileName, data); err != nil {
+		t.Fatal(err)
+	}
+	if gotEvent.Type != EventTypeParseFailed || gotEvent.Subject != "core.app.1" {
+		t.Errorf("unexpected event type/subject: %s/%s", gotEvent.Type, gotEvent.Subject)
+	}
+	if gotEvent.DataSchema != DataSchemaURI(EventTypeParseFailed) {
+		t.Errorf("unexpected dataschema %q", gotEvent.DataSchema)
+	}
+	if gotEvent.Data["coredump_id"] != data.CoredumpID || gotEvent.Data["error"] != "not an ELF file" {
+		t.Errorf("unexpected data: %v", gotEvent.Data)
+	}
+}
+
+func TestHTTPModes(t *testing.T) {
+	const secret = "s3cret"
+	var req *http.Request
+	var body []byte
+	var requests []int // batch 模式下每个请求包含的事件数
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = io.ReadAll(r.Body)
+		var events []CloudEvent
+		if json.Unmarshal(body, &events) == nil {
+			requests = append(requests, len(events))
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	t.Run("structured", func(t *testing.T) {
+		r, err := New(Options{URL: srv.URL, Token: "tok", HTTPMode: HTTPModeStructured, SigningSecret: secret})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		if err := r.ReportCoredumpUploaded(context.Background(), testData()); err != nil {
+			t.Fatal(err)
+		}
+		if ct := req.Header.Get("Content-Type"); ct != "application/cloudevents+json; charset=utf-8" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		var event map[string]interface{}
+		json.Unmarshal(body, &event)
+		if _, ok := event["token"]; ok {
+			t.Error("token must not be embedded in structured events")
+		}
+		if req.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("expected bearer token, got %q", req.Header.Get("Authorization"))
+		}
+		if err := VerifySignature(secret, req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderSignature), body, time.Minute); err != nil {
+			t.Errorf("signature verification failed: %v", err)
+		}
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		r, err := New(Options{URL: srv.URL, HTTPMode: HTTPModeBinary})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		if err := r.ReportCoredumpUploaded(context.Background(), testData()); err != nil {
+			t.Fatal(err)
+		}
+		if req.Header.Get("ce-type") != EventTypeUploaded || req.Header.Get("ce-id") == "" ||
+			req.Header.Get("ce-specversion") != "1.0" || req.Header.Get("ce-subject") != "core.app.1" {
+			t.Errorf("unexpected ce headers: %v", req.Header)
+		}
+		if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		var data map[string]interface{}
+		json.Unmarshal(body, &data)
+		if data["md5"] != testData().MD5 {
+			t.Errorf("expected event data as body, got %s", body)
+		}
+		if req.Header.Get(HeaderSignature) != "" {
+			t.Error("unexpected signature header without signing secret")
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		r, err := New(Options{
+			URL:          srv.URL,
+			HTTPMode:     HTTPModeBatch,
+			BatchSize:    2,
+			OutboxPath:   filepath.Join(t.TempDir(), "outbox.jsonl"),
+			DisableRetry: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		for i := 0; i < 3; i++ {
+			if err := r.ReportCoredumpUploaded(context.Background(), testData()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if ct := req.Header.Get("Content-Type"); ct != "application/cloudevents-batch+json; charset=utf-8" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+
+		requests = nil
+		if _, err := r.Replay(context.Background(), time.Now().Add(-time.Hour), time.Time{}, "", false); err != nil {
+			t.Fatal(err)
+		}
+		if len(requests) != 2 || requests[0] != 2 || requests[1] != 1 {
+			t.Errorf("expected batches of [2 1], got %v", requests)
+		}
+	})
+}
+
+func TestLiveBatching(t *testing.T) {
+	batches := make(chan int, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var events []CloudEvent
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &events)
+		batches <- len(events)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	r, err := New(Options{
+		URL:         srv.URL,
+		HTTPMode:    HTTPModeBatch,
+		BatchSize:   2,
+		BatchLinger: 50 * time.Millisecond,
+		OutboxPath:  path,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := r.ReportCoredumpUploaded(context.Background(), testData()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 攒满 batchSize 立即发送，剩余的事件等待 linger 后发送
+	for _, want := range []int{2, 1} {
+		select {
+		case n := <-batches:
+			if n != want {
+				t.Errorf("batch of %d events, want %d", n, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("batch not sent")
+		}
+	}
+	r.Close()
+
+	o, err := OpenOutbox(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if n := o.PendingCount(); n != 0 {
+		t.Errorf("%d events still pending after batch delivery", n)
+	}
+}
+
+func TestNewUnsupportedHTTPMode(t *testing.T) {
+	if _, err := New(Options{URL: "http://localhost", HTTPMode: "multipart"}); err == nil {
+		t.Fatal("expected error for unsupported HTTP mode")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	now := time.Now().Unix()
+	sig := Sign("secret", now, body)
+	ts := strconv.FormatInt(now, 10)
+
+	if err := VerifySignature("secret", ts, sig, body, time.Minute); err != nil {
+		t.Errorf("expected valid signature, got %v", err)
+	}
+	if err := VerifySignature("other", ts, sig, body, time.Minute); err == nil {
+		t.Error("expected mismatch with wrong secret")
+	}
+	if err := VerifySignature("secret", ts, sig, []byte(`{"id":"2"}`), time.Minute); err == nil {
+		t.Error("expected mismatch with tampered body")
+	}
+	old := now - 3600
+	if err := VerifySignature("secret", strconv.FormatInt(old, 10), Sign("secret", old, body), body, time.Minute); err == nil {
+		t.Error("expected replayed request to be rejected")
+	}
+}
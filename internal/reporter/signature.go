@@ -0,0 +1,52 @@
+package reporter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP 请求签名头
+// 签名内容为 "<timestamp>.<请求体>"，接收端应校验签名并拒绝时间戳偏差过大的请求以防重放
+const (
+	HeaderTimestamp = "X-Coredog-Timestamp"
+	HeaderSignature = "X-Coredog-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+// Sign 计算请求签名，返回 sha256=<hex>
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature 校验请求签名，供接收端使用
+// tolerance 为允许的时间戳偏差，超出视为重放请求
+func VerifySignature(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s header %q", HeaderTimestamp, timestamp)
+	}
+	skew := time.Since(time.Unix(ts, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > tolerance {
+		return fmt.Errorf("request timestamp is %v away from now, exceeds tolerance %v", skew.Round(time.Second), tolerance)
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return fmt.Errorf("unsupported signature format")
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
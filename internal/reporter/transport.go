@@ -0,0 +1,203 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Transport 负责把 CloudEvent 投递到 CoreSight
+type Transport interface {
+	Send(ctx context.Context, event *CloudEvent) error
+	Close() error
+}
+
+// BatchTransport 支持一次投递多个事件的传输，用于 outbox 重试和重放
+type BatchTransport interface {
+	Transport
+	SendBatch(ctx context.Context, events []*CloudEvent) error
+	BatchSize() int
+}
+
+// CloudEvents HTTP binding 的内容模式
+const (
+	// HTTPModeLegacy 旧格式：application/json 请求体，token 同时写入事件 JSON 和 Bearer 请求头
+	// 仅用于兼容旧版本 CoreSight
+	HTTPModeLegacy = "legacy"
+	// HTTPModeStructured 结构化模式：请求体为完整事件，Content-Type: application/cloudevents+json
+	HTTPModeStructured = "structured"
+	// HTTPModeBinary 二进制模式：事件属性放在 ce-* 请求头中，请求体为 data
+	HTTPModeBinary = "binary"
+	// HTTPModeBatch 批量模式：请求体为事件数组，Content-Type: application/cloudevents-batch+json
+	HTTPModeBatch = "batch"
+
+	defaultHTTPBatchSize = 20
+)
+
+// HTTPTransport 通过 HTTP POST 投递事件
+type HTTPTransport struct {
+	httpClient    *http.Client
+	apiURL        string
+	token         string
+	mode          string
+	signingSecret string
+	batchSize     int
+}
+
+// NewHTTPTransport 创建 HTTP 传输
+func NewHTTPTransport(opts Options) (*HTTPTransport, error) {
+	mode := strings.ToLower(strings.TrimSpace(opts.HTTPMode))
+	if mode == "" {
+		mode = HTTPModeLegacy
+	}
+	switch mode {
+	case HTTPModeLegacy, HTTPModeStructured, HTTPModeBinary, HTTPModeBatch:
+	default:
+		return nil, fmt.Errorf("unsupported CoreSight HTTP mode %q (expect legacy, structured, binary or batch)", opts.HTTPMode)
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultHTTPBatchSize
+	}
+
+	logrus.Infof("[CoreSight] Configured HTTP reporter: %s (mode: %s, signed: %v)", opts.URL, mode, opts.SigningSecret != "")
+	return &HTTPTransport{
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		apiURL:        opts.URL,
+		token:         opts.Token,
+		mode:          mode,
+		signingSecret: opts.SigningSecret,
+		batchSize:     batchSize,
+	}, nil
+}
+
+// Send 发送事件，非 2xx 响应视为失败
+func (t *HTTPTransport) Send(ctx context.Context, event *CloudEvent) error {
+	var (
+		body        []byte
+		contentType string
+		headers     = make(http.Header)
+		err         error
+	)
+
+	switch t.mode {
+	case HTTPModeLegacy:
+		e := *event
+		e.Token = t.token
+		body, err = json.Marshal(&e)
+		contentType = "application/json"
+	case HTTPModeStructured:
+		body, err = json.Marshal(event)
+		contentType = "application/cloudevents+json; charset=utf-8"
+	case HTTPModeBinary:
+		body, err = json.Marshal(event.Data)
+		contentType = event.DataContentType
+		setBinaryHeaders(headers, event)
+	case HTTPModeBatch:
+		return t.SendBatch(ctx, []*CloudEvent{event})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	if err := t.post(ctx, body, contentType, headers); err != nil {
+		return err
+	}
+	logrus.Debugf("[CoreSight] event %s delivered over HTTP (mode: %s)", event.ID, t.mode)
+	return nil
+}
+
+// SendBatch 批量模式下一次请求发送多个事件，其他模式逐个发送
+func (t *HTTPTransport) SendBatch(ctx context.Context, events []*CloudEvent) error {
+	if t.mode != HTTPModeBatch {
+		for _, event := range events {
+			if err := t.Send(ctx, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event batch: %w", err)
+	}
+	if err := t.post(ctx, body, "application/cloudevents-batch+json; charset=utf-8", nil); err != nil {
+		return err
+	}
+	logrus.Debugf("[CoreSight] batch of %d events delivered over HTTP", len(events))
+	return nil
+}
+
+// BatchSize 批量模式下单个请求最多包含的事件数
+func (t *HTTPTransport) BatchSize() int {
+	if t.mode != HTTPModeBatch {
+		return 1
+	}
+	return t.batchSize
+}
+
+// setBinaryHeaders 按 CloudEvents HTTP binding 二进制模式设置 ce-* 请求头
+func setBinaryHeaders(h http.Header, event *CloudEvent) {
+	h.Set("ce-specversion", event.SpecVersion)
+	h.Set("ce-type", event.Type)
+	h.Set("ce-source", event.Source)
+	h.Set("ce-id", event.ID)
+	h.Set("ce-time", event.Time)
+	if event.Subject != "" {
+		h.Set("ce-subject", event.Subject)
+	}
+	if event.DataSchema != "" {
+		h.Set("ce-dataschema", event.DataSchema)
+	}
+}
+
+func (t *HTTPTransport) post(ctx context.Context, body []byte, contentType string, headers http.Header) error {
+	// 创建 HTTP 请求
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.apiURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	// 设置请求头
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	if t.signingSecret != "" {
+		ts := time.Now().Unix()
+		req.Header.Set(HeaderTimestamp, fmt.Sprintf("%d", ts))
+		req.Header.Set(HeaderSignature, Sign(t.signingSecret, ts, body))
+	}
+
+	// 发送请求
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request to %s: %w", t.apiURL, err)
+	}
+	defer resp.Body.Close()
+
+	// 检查响应状态
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP request failed with status %d: %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+// Close 关闭 HTTP 传输
+func (t *HTTPTransport) Close() error {
+	logrus.Info("[CoreSight] HTTP reporter closed")
+	return nil
+}
@@ -0,0 +1,245 @@
+package retention
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/DomineCore/coredog/internal/podresolver"
+	"github.com/DomineCore/coredog/internal/store"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// 删除原因
+const (
+	ReasonAge            = "age"             // 超过 maxAgeDays
+	ReasonCount          = "count"           // 超过工作负载的 maxPerWorkload
+	ReasonNamespaceBytes = "namespace-bytes" // 超过 namespace 的 maxTotalBytes
+	ReasonTotalBytes     = "total-bytes"     // 超过全局 maxTotalBytes
+)
+
+// Item 一个已存储的 core 文件及从 key 中解析出的归属
+type Item struct {
+	store.Object
+	Namespace string
+	Workload  string
+}
+
+// Deletion 过期的对象及原因
+type Deletion struct {
+	Item
+	Reason string
+}
+
+// Result 一次回收的结果，dry-run 时 Deleted 为将要删除的对象
+type Result struct {
+	Scanned      int
+	ScannedBytes int64
+	Deleted      []Deletion
+	DeletedBytes int64
+	Held         []string // 因 legal hold 保留的过期对象
+	Failed       int      // 删除失败的对象数
+}
+
+// ErrUnscoped Store 的前缀不区分集群，多个集群共用 bucket 时会删除其它集群的对象
+var ErrUnscoped = errors.New("storage prefix is not scoped to this cluster")
+
+// Collector 按保留策略删除过期的 core 文件
+type Collector struct {
+	Store    store.Manager
+	Template *store.KeyTemplate
+	Config   cfgpkg.RetentionConfig
+	DryRun   bool
+	Now      func() time.Time // 为 nil 时使用 time.Now
+	// Scoped Store 只包含本集群的对象（Cluster.storagePrefix），为 false 时需要 AllowUnscoped 才会执行
+	Scoped        bool
+	AllowUnscoped bool // 明确确认 bucket 只由本集群使用
+}
+
+// Run 列出所有对象，计算过期的对象并删除
+// 只对过期的对象查询 legal hold，查询失败时保守地保留该对象
+func (c *Collector) Run(ctx context.Context) (*Result, error) {
+	if !c.Scoped && !c.AllowUnscoped {
+		return nil, ErrUnscoped
+	}
+	objects, err := c.Store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if c.Now != nil {
+		now = c.Now()
+	}
+
+	items := make([]Item, 0, len(objects))
+	result := &Result{}
+	for _, o := range objects {
+		items = append(items, c.item(o))
+		result.Scanned++
+		result.ScannedBytes += o.Size
+	}
+
+	held := make(map[string]bool)
+	isHeld := func(key string) bool {
+		if h, ok := held[key]; ok {
+			return h
+		}
+		h, err := c.Store.LegalHold(ctx, key, c.Config.LegalHoldTag)
+		if err != nil {
+			logrus.Warnf("retention: keeping %s, failed to check legal hold: %v", key, err)
+			h = true
+		}
+		held[key] = h
+		if h {
+			result.Held = append(result.Held, key)
+		}
+		return h
+	}
+
+	for _, d := range c.plan(items, now, isHeld) {
+		if c.DryRun {
+			logrus.Infof("retention: would delete %s (%d bytes, %s, reason %s)", d.Key, d.Size, d.LastModified.Format(time.RFC3339), d.Reason)
+		} else {
+			if err := c.Store.Delete(ctx, d.Key); err != nil {
+				logrus.Errorf("retention: %v", err)
+				result.Failed++
+				continue
+			}
+			logrus.Infof("retention: deleted %s (%d bytes, %s, reason %s)", d.Key, d.Size, d.LastModified.Format(time.RFC3339), d.Reason)
+		}
+		result.Deleted = append(result.Deleted, d)
+		result.DeletedBytes += d.Size
+	}
+	if result.Failed > 0 {
+		return result, errors.Errorf("failed to delete %d objects", result.Failed)
+	}
+	return result, nil
+}
+
+// item 从 key 中解析对象所属的 namespace 和工作负载
+func (c *Collector) item(o store.Object) Item {
+	it := Item{Object: o}
+	if c.Template == nil {
+		return it
+	}
+	vars, ok := c.Template.Parse(o.Key)
+	if !ok {
+		return it
+	}
+	it.Namespace = vars["namespace"]
+	it.Workload = vars["workload"]
+	if it.Workload == "" {
+		it.Workload = podresolver.GuessWorkloadName(vars["pod"])
+	}
+	return it
+}
+
+// policy namespace 生效的保留策略以及该 namespace 自身的总大小上限
+func (c *Collector) policy(namespace string) (cfgpkg.RetentionPolicy, int64) {
+	p := c.Config.Default
+	if namespace == "" {
+		return p, 0
+	}
+	for _, ns := range c.Config.Namespaces {
+		if ok, _ := filepath.Match(ns.Namespace, namespace); !ok {
+			continue
+		}
+		if ns.MaxAgeDays > 0 {
+			p.MaxAgeDays = ns.MaxAgeDays
+		}
+		if ns.MaxPerWorkload > 0 {
+			p.MaxPerWorkload = ns.MaxPerWorkload
+		}
+		return p, ns.MaxTotalBytes
+	}
+	return p, 0
+}
+
+// plan 依次按存活时间、工作负载数量、namespace 总大小和全局总大小计算过期的对象，结果按从旧到新排序
+// 带 legal hold 的对象不会被删除，但仍计入数量和总大小
+func (c *Collector) plan(items []Item, now time.Time, isHeld func(key string) bool) []Deletion {
+	// 从新到旧
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].LastModified.Equal(items[j].LastModified) {
+			return items[i].LastModified.After(items[j].LastModified)
+		}
+		return items[i].Key > items[j].Key
+	})
+
+	expired := make(map[string]string)
+	expire := func(it Item, reason string) bool {
+		if _, ok := expired[it.Key]; ok {
+			return true
+		}
+		if isHeld(it.Key) {
+			return false
+		}
+		expired[it.Key] = reason
+		return true
+	}
+
+	for _, it := range items {
+		p, _ := c.policy(it.Namespace)
+		if p.MaxAgeDays > 0 && now.Sub(it.LastModified) > time.Duration(p.MaxAgeDays)*24*time.Hour {
+			expire(it, ReasonAge)
+		}
+	}
+
+	kept := make(map[string]int)
+	for _, it := range items {
+		if _, ok := expired[it.Key]; ok || it.Workload == "" {
+			continue
+		}
+		p, _ := c.policy(it.Namespace)
+		group := it.Namespace + "/" + it.Workload
+		if p.MaxPerWorkload <= 0 || kept[group] < p.MaxPerWorkload {
+			kept[group]++
+			continue
+		}
+		expire(it, ReasonCount)
+	}
+
+	// 总大小：从最旧的开始删除，直到不超过上限
+	trim := func(limit int64, reason string, in func(Item) bool) {
+		var total int64
+		for _, it := range items {
+			if _, ok := expired[it.Key]; !ok && in(it) {
+				total += it.Size
+			}
+		}
+		for i := len(items) - 1; i >= 0 && total > limit; i-- {
+			it := items[i]
+			if _, ok := expired[it.Key]; ok || !in(it) {
+				continue
+			}
+			if expire(it, reason) {
+				total -= it.Size
+			}
+		}
+	}
+	namespaces := make(map[string]bool)
+	for _, it := range items {
+		if it.Namespace == "" || namespaces[it.Namespace] {
+			continue
+		}
+		namespaces[it.Namespace] = true
+		if _, limit := c.policy(it.Namespace); limit > 0 {
+			ns := it.Namespace
+			trim(limit, ReasonNamespaceBytes, func(it Item) bool { return it.Namespace == ns })
+		}
+	}
+	if limit := c.Config.Default.MaxTotalBytes; limit > 0 {
+		trim(limit, ReasonTotalBytes, func(Item) bool { return true })
+	}
+
+	var deletions []Deletion
+	for i := len(items) - 1; i >= 0; i-- {
+		if reason, ok := expired[items[i].Key]; ok {
+			deletions = append(deletions, Deletion{Item: items[i], Reason: reason})
+		}
+	}
+	return deletions
+}
@@ -0,0 +1,164 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/DomineCore/coredog/internal/store"
+)
+
+var now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+// fakeStore 内存中的 store.Manager
+type fakeStore struct {
+	objects []store.Object
+	held    map[string]bool
+	deleted []string
+}
+
+func (f *fakeStore) List(context.Context) ([]store.Object, error) {
+	return append([]store.Object{}, f.objects...), nil
+}
+
+func (f *fakeStore) Delete(_ context.Context, key string) error {
+	f.deleted = append(f.deleted, key)
+	return nil
+}
+
+func (f *fakeStore) LegalHold(_ context.Context, key, tag string) (bool, error) {
+	return tag == "legal-hold" && f.held[key], nil
+}
+
+func object(key string, ageDays int, size int64) store.Object {
+	return store.Object{Key: key, Size: size, LastModified: now.Add(-time.Duration(ageDays) * 24 * time.Hour)}
+}
+
+func run(t *testing.T, f *fakeStore, cfg cfgpkg.RetentionConfig, dryRun bool) (*Result, map[string]string) {
+	t.Helper()
+	tmpl, err := store.ParseKeyTemplate("{namespace}/{pod}/{filename}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LegalHoldTag == "" {
+		cfg.LegalHoldTag = "legal-hold"
+	}
+	c := &Collector{Store: f, Template: tmpl, Config: cfg, DryRun: dryRun, Now: func() time.Time { return now }, Scoped: true}
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := make(map[string]string)
+	for _, d := range result.Deleted {
+		reasons[d.Key] = d.Reason
+	}
+	return result, reasons
+}
+
+func TestRetentionAgeAndCount(t *testing.T) {
+	f := &fakeStore{
+		objects: []store.Object{
+			object("default/api-7d9f8c6b5-x2k4p/core.api.1", 1, 10),
+			object("default/api-7d9f8c6b5-q8w7z/core.api.2", 2, 10),
+			object("default/api-7d9f8c6b5-x2k4p/core.api.3", 3, 10),
+			object("default/db-0/core.db.1", 40, 10),
+			object("payments/ledger-0/core.ledger.1", 40, 10),
+			object("payments/ledger-1/core.ledger.2", 60, 10),
+			object("core.old.1", 100, 10), // 旧的 key 布局
+		},
+		held: map[string]bool{"payments/ledger-1/core.ledger.2": true},
+	}
+	cfg := cfgpkg.RetentionConfig{
+		Default: cfgpkg.RetentionPolicy{MaxAgeDays: 30, MaxPerWorkload: 2},
+		Namespaces: []cfgpkg.NamespaceRetention{
+			{Namespace: "pay*", MaxAgeDays: 50},
+		},
+	}
+	result, reasons := run(t, f, cfg, false)
+
+	want := map[string]string{
+		"default/api-7d9f8c6b5-x2k4p/core.api.3": ReasonCount,
+		"default/db-0/core.db.1":                 ReasonAge,
+		"core.old.1":                             ReasonAge,
+	}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("got %v, want %v", reasons, want)
+	}
+	if !reflect.DeepEqual(result.Held, []string{"payments/ledger-1/core.ledger.2"}) {
+		t.Errorf("unexpected held objects %v", result.Held)
+	}
+	// 从旧到新删除
+	if want := []string{"core.old.1", "default/db-0/core.db.1", "default/api-7d9f8c6b5-x2k4p/core.api.3"}; !reflect.DeepEqual(f.deleted, want) {
+		t.Errorf("deleted %v, want %v", f.deleted, want)
+	}
+	if result.Scanned != 7 || result.DeletedBytes != 30 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestRetentionTotalBytes(t *testing.T) {
+	f := &fakeStore{
+		objects: []store.Object{
+			object("default/a-0/core.a.1", 1, 100),
+			object("default/a-0/core.a.2", 2, 100),
+			object("default/a-0/core.a.3", 3, 100),
+			object("payments/b-0/core.b.1", 1, 100),
+			object("payments/b-0/core.b.2", 4, 100),
+			object("payments/b-0/core.b.3", 5, 100),
+		},
+		held: map[string]bool{"payments/b-0/core.b.3": true},
+	}
+	cfg := cfgpkg.RetentionConfig{
+		Default: cfgpkg.RetentionPolicy{MaxTotalBytes: 300},
+		Namespaces: []cfgpkg.NamespaceRetention{
+			{Namespace: "payments", MaxTotalBytes: 200},
+		},
+	}
+	result, reasons := run(t, f, cfg, true)
+
+	// payments 超出 100：最旧的 b.3 被 legal hold 保留，删除 b.2
+	// 剩余 500 超出全局上限 200：跳过 b.3，删除 a.3 和 a.2
+	want := map[string]string{
+		"payments/b-0/core.b.2": ReasonNamespaceBytes,
+		"default/a-0/core.a.3":  ReasonTotalBytes,
+		"default/a-0/core.a.2":  ReasonTotalBytes,
+	}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("got %v, want %v", reasons, want)
+	}
+	if result.DeletedBytes != 300 || len(f.deleted) != 0 {
+		t.Errorf("dry run deleted %v", f.deleted)
+	}
+	sort.Strings(result.Held)
+	if !reflect.DeepEqual(result.Held, []string{"payments/b-0/core.b.3"}) {
+		t.Errorf("unexpected held objects %v", result.Held)
+	}
+}
+
+func TestRetentionUnscoped(t *testing.T) {
+	f := &fakeStore{objects: []store.Object{object("default/app-0/core.1", 100, 1)}}
+	tmpl, _ := store.ParseKeyTemplate("{namespace}/{pod}/{filename}")
+	cfg := cfgpkg.RetentionConfig{}
+	cfg.Default.MaxAgeDays = 30
+
+	// 前缀不区分集群时拒绝执行，避免删除共用 bucket 中其它集群的对象
+	c := &Collector{Store: f, Template: tmpl, Config: cfg, Now: func() time.Time { return now }}
+	if _, err := c.Run(context.Background()); !errors.Is(err, ErrUnscoped) {
+		t.Fatalf("expected ErrUnscoped, got %v", err)
+	}
+	if len(f.deleted) != 0 {
+		t.Errorf("unexpected deletions %v", f.deleted)
+	}
+
+	c.AllowUnscoped = true
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.deleted, []string{"default/app-0/core.1"}) {
+		t.Errorf("deleted %v", f.deleted)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -13,13 +15,16 @@ import (
 
 // CFSStore implements the Store interface for CFS (Cloud File System)
 type CFSStore struct {
-	MountPath string
-	StoreDir  string
+	MountPath   string
+	StoreDir    string
+	IfNoneMatch bool         // 目标文件已存在时不覆盖
+	Limiter     *RateLimiter // 带宽限制，nil 表示不限制
 }
 
 // Upload uploads the corefile to the CFS mount point
 // Returns the local file path (since CFS is a mounted filesystem)
-func (cs *CFSStore) Upload(ctx context.Context, path string) (downloadurl string, err error) {
+// 先写入同目录下的临时文件再链接或重命名到目标路径，已存在的目标文件不会被截断或写到一半
+func (cs *CFSStore) Upload(ctx context.Context, path, key string) (downloadurl string, err error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to open corefile")
@@ -27,42 +32,133 @@ func (cs *CFSStore) Upload(ctx context.Context, path string) (downloadurl string
 	defer f.Close()
 
 	// Create the destination path
-	_, filename := filepath.Split(path)
-	destDir := filepath.Join(cs.MountPath, cs.StoreDir)
+	destPath := filepath.Join(cs.MountPath, cs.StoreDir, key)
+	destDir := filepath.Dir(destPath)
 
 	// Ensure destination directory exists
 	if err := os.MkdirAll(destDir, 0755); err != nil {
 		return "", errors.Wrap(err, "failed to create destination directory")
 	}
 
-	destPath := filepath.Join(destDir, filename)
+	// Return the CFS path as download URL
+	// Typically: cfs://mount-id/storeDir/filename or file path
+	downloadurl = fmt.Sprintf("cfs://%s", destPath)
 
-	// Create the destination file
-	destFile, err := os.Create(destPath)
+	if cs.IfNoneMatch {
+		if _, err := os.Lstat(destPath); err == nil {
+			return downloadurl, errors.Wrap(ErrObjectExists, destPath)
+		}
+	}
+
+	// Create a temporary file next to the destination
+	tmpFile, err := os.CreateTemp(destDir, "."+filepath.Base(destPath)+".*.tmp")
 	if err != nil {
-		return "", errors.Wrap(err, "failed to create destination file")
+		return "", errors.Wrap(err, "failed to create temporary file")
 	}
-	defer destFile.Close()
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath) // 链接或重命名成功后删除会失败，忽略即可
 
 	// Copy the file content
-	if _, err := io.Copy(destFile, f); err != nil {
+	if _, err := io.Copy(tmpFile, cs.Limiter.Reader(ctx, f)); err != nil {
+		tmpFile.Close()
 		return "", errors.Wrap(err, "failed to copy file to CFS")
 	}
 
 	// Ensure file is synced to disk
-	if err := destFile.Sync(); err != nil {
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
 		return "", errors.Wrap(err, "failed to sync file to CFS")
 	}
+	if err := tmpFile.Close(); err != nil {
+		return "", errors.Wrap(err, "failed to close file on CFS")
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return "", errors.Wrap(err, "failed to set file mode")
+	}
 
-	// Return the CFS path as download URL
-	// Typically: cfs://mount-id/storeDir/filename or file path
-	downloadurl = fmt.Sprintf("cfs://%s/%s", destDir, filename)
+	if cs.IfNoneMatch {
+		// link 在目标已存在时失败，不会覆盖并发写入的同名文件
+		if err := os.Link(tmpPath, destPath); err != nil {
+			if os.IsExist(err) {
+				return downloadurl, errors.Wrap(ErrObjectExists, destPath)
+			}
+			return "", errors.Wrap(err, "failed to link file into place")
+		}
+		return downloadurl, nil
+	}
 
+	// rename 原子地替换目标文件，已存在的文件不会被截断
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		return "", errors.Wrap(err, "failed to rename file into place")
+	}
 	return downloadurl, nil
 }
 
+// List 遍历 StoreDir 下的文件，跳过以 . 开头的文件（上传中的临时文件和 legal hold 标记文件）
+func (cs *CFSStore) List(ctx context.Context) ([]Object, error) {
+	root := filepath.Join(cs.MountPath, cs.StoreDir)
+	var objects []Object
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() || !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		key, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{
+			Key:          filepath.ToSlash(key),
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list %s", root)
+	}
+	return objects, nil
+}
+
+// Delete 删除文件，文件不存在时不返回错误
+func (cs *CFSStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(filepath.Join(cs.MountPath, cs.StoreDir, key))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to delete %s", key)
+	}
+	return nil
+}
+
+// LegalHold 同目录下存在 .<文件名>.<tag> 标记文件时视为 legal hold
+func (cs *CFSStore) LegalHold(ctx context.Context, key, tag string) (bool, error) {
+	path := filepath.Join(cs.MountPath, cs.StoreDir, key)
+	marker := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+"."+tag)
+	if _, err := os.Stat(marker); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "failed to check legal hold of %s", key)
+	}
+	return true, nil
+}
+
 // NewCFSStore creates a new CFS store instance
-func NewCFSStore(mountPath, storedir string) (Store, error) {
+func NewCFSStore(mountPath, storedir string, ifNoneMatch bool, limiter *RateLimiter) (Store, error) {
 	// Validate mount path exists and is accessible
 	info, err := os.Stat(mountPath)
 	if err != nil {
@@ -80,7 +176,9 @@ func NewCFSStore(mountPath, storedir string) (Store, error) {
 	os.Remove(testFile)
 
 	return &CFSStore{
-		MountPath: mountPath,
-		StoreDir:  storedir,
+		MountPath:   mountPath,
+		StoreDir:    storedir,
+		IfNoneMatch: ifNoneMatch,
+		Limiter:     limiter,
 	}, nil
 }
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeCore(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "core.app.1")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCFSStoreUpload(t *testing.T) {
+	mount := t.TempDir()
+	s := &CFSStore{MountPath: mount, StoreDir: "corefiles"}
+
+	url, err := s.Upload(context.Background(), writeCore(t, "first"), "default/app-0/core.app.1")
+	dest := filepath.Join(mount, "corefiles/default/app-0/core.app.1")
+	if err != nil || url != "cfs://"+dest {
+		t.Fatalf("got (%q, %v)", url, err)
+	}
+
+	// 覆盖时替换为新文件，原文件的内容（已打开的句柄）不会被截断
+	old, err := os.Open(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	if _, err := s.Upload(context.Background(), writeCore(t, "second"), "default/app-0/core.app.1"); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(dest); string(b) != "second" {
+		t.Errorf("unexpected content %q", b)
+	}
+	buf := make([]byte, 16)
+	if n, _ := old.Read(buf); string(buf[:n]) != "first" {
+		t.Errorf("existing file was modified: %q", buf[:n])
+	}
+
+	// 开启 IfNoneMatch 时不覆盖
+	s.IfNoneMatch = true
+	url, err = s.Upload(context.Background(), writeCore(t, "third"), "default/app-0/core.app.1")
+	if !errors.Is(err, ErrObjectExists) || url != "cfs://"+dest {
+		t.Errorf("expected ErrObjectExists, got (%q, %v)", url, err)
+	}
+	if b, _ := os.ReadFile(dest); string(b) != "second" {
+		t.Errorf("existing file was overwritten: %q", b)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(dest))
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestCFSStoreManage(t *testing.T) {
+	ctx := context.Background()
+	mount := t.TempDir()
+	s := &CFSStore{MountPath: mount, StoreDir: "corefiles"}
+
+	// StoreDir 尚不存在
+	if objects, err := s.List(ctx); err != nil || len(objects) != 0 {
+		t.Fatalf("got (%v, %v)", objects, err)
+	}
+
+	for _, key := range []string{"default/core.app.1", "payments/core.api.2"} {
+		if _, err := s.Upload(ctx, writeCore(t, key), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	marker := filepath.Join(mount, "corefiles/payments/.core.api.2.legal-hold")
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[0].Key != "default/core.app.1" || objects[0].Size != int64(len("default/core.app.1")) {
+		t.Fatalf("unexpected objects %+v", objects)
+	}
+
+	if held, err := s.LegalHold(ctx, "payments/core.api.2", "legal-hold"); err != nil || !held {
+		t.Errorf("expected legal hold, got (%v, %v)", held, err)
+	}
+	if held, err := s.LegalHold(ctx, "default/core.app.1", "legal-hold"); err != nil || held {
+		t.Errorf("expected no legal hold, got (%v, %v)", held, err)
+	}
+
+	if err := s.Delete(ctx, "default/core.app.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "default/core.app.1"); err != nil {
+		t.Errorf("deleting a missing file should succeed, got %v", err)
+	}
+	if objects, _ := s.List(ctx); len(objects) != 1 {
+		t.Errorf("expected one object left, got %+v", objects)
+	}
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultKeyTemplate 默认的对象 key 模板，与旧版本的 StoreDir/<文件名> 布局一致
+const DefaultKeyTemplate = "{filename}"
+
+// keyVariables key 模板支持的变量
+var keyVariables = map[string]bool{
+	"cluster":     true, // 集群名称，未设置时为集群 ID
+	"namespace":   true,
+	"pod":         true,
+	"workload":    true, // Pod 所属的顶层工作负载名称
+	"container":   true,
+	"node":        true,
+	"date":        true, // 上传日期（UTC），2006-01-02
+	"year":        true,
+	"month":       true,
+	"day":         true,
+	"md5":         true,
+	"sha256":      true,
+	"coredump_id": true,
+	"executable":  true, // 可执行文件名（不含目录）
+	"filename":    true, // core 文件名
+}
+
+// uniqueVariables 每个 core 文件都不同的变量
+// core 文件名（如 core.app.1.host.1700000000）在不同节点或 PID 复用时可能重复，不在其中
+var uniqueVariables = []string{"coredump_id", "md5", "sha256"}
+
+var keyPlaceholder = regexp.MustCompile(`\{([a-z0-9_]+)\}`)
+
+// unsafeKeyChars 变量值中需要替换的字符：只保留字母、数字和 . _ -
+var unsafeKeyChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// KeyTemplate 对象 key 模板，如 {cluster}/{namespace}/{pod}/{container}/{date}/{md5}-{filename}
+// 生成的 key 位于 StoreDir 下
+type KeyTemplate struct {
+	template string
+	vars     map[string]bool // 模板中使用的变量
+	pattern  *regexp.Regexp  // 从 key 反解变量值，见 Parse
+	names    []string        // pattern 中各分组对应的变量
+}
+
+// ParseKeyTemplate 解析并校验 key 模板，为空时使用 DefaultKeyTemplate
+func ParseKeyTemplate(template string) (*KeyTemplate, error) {
+	template = strings.Trim(strings.TrimSpace(template), "/")
+	if template == "" {
+		template = DefaultKeyTemplate
+	}
+
+	t := &KeyTemplate{template: template, vars: make(map[string]bool)}
+	for _, m := range keyPlaceholder.FindAllStringSubmatch(template, -1) {
+		if !keyVariables[m[1]] {
+			return nil, errors.Errorf("unknown variable {%s} in key template %q", m[1], template)
+		}
+		t.vars[m[1]] = true
+	}
+	for _, segment := range strings.Split(template, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, errors.Errorf("invalid path segment %q in key template %q", segment, template)
+		}
+	}
+
+	t.compilePattern()
+
+	// 只有 {filename} 的模板（包括默认模板）保持旧版本的布局，同名 core 会互相覆盖，见 Unique
+	if t.vars["filename"] || t.Unique() {
+		return t, nil
+	}
+	return nil, errors.Errorf("key template %q must contain {filename} or one of {%s}",
+		template, strings.Join(uniqueVariables, "}, {"))
+}
+
+// Unique 模板是否包含每个 core 文件都不同的变量，否则不同的 core 可能生成相同的 key
+func (t *KeyTemplate) Unique() bool {
+	for _, v := range uniqueVariables {
+		if t.vars[v] {
+			return true
+		}
+	}
+	return false
+}
+
+// ContentAddressed 模板是否包含文件摘要，此时相同的 key 意味着相同的内容
+func (t *KeyTemplate) ContentAddressed() bool {
+	return t.vars["md5"] || t.vars["sha256"]
+}
+
+// Uses 模板是否使用了变量 name
+func (t *KeyTemplate) Uses(name string) bool {
+	return t.vars[name]
+}
+
+// Render 渲染对象 key
+// 变量值中除字母、数字和 . _ - 以外的字符替换为 _，空值替换为 unknown，因此变量不会引入额外的目录层级
+func (t *KeyTemplate) Render(vars map[string]string) string {
+	return keyPlaceholder.ReplaceAllStringFunc(t.template, func(placeholder string) string {
+		return escapeKeyValue(vars[placeholder[1:len(placeholder)-1]])
+	})
+}
+
+// compilePattern 把模板转换为匹配 key 的正则，每个变量匹配一个不含 / 的非空值
+func (t *KeyTemplate) compilePattern() {
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, loc := range keyPlaceholder.FindAllStringSubmatchIndex(t.template, -1) {
+		b.WriteString(regexp.QuoteMeta(t.template[last:loc[0]]))
+		b.WriteString(`([^/]+?)`)
+		t.names = append(t.names, t.template[loc[2]:loc[3]])
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(t.template[last:]))
+	b.WriteString("$")
+	t.pattern = regexp.MustCompile(b.String())
+}
+
+// Parse 从按模板生成的 key 中解析变量值，key 与模板不匹配（如修改模板前上传的对象）时返回 false
+// 渲染时被替换为 unknown 的空值解析为空字符串；相邻变量之间没有分隔符时解析结果可能不准确
+func (t *KeyTemplate) Parse(key string) (map[string]string, bool) {
+	m := t.pattern.FindStringSubmatch(key)
+	if m == nil {
+		return nil, false
+	}
+	vars := make(map[string]string, len(t.names))
+	for i, name := range t.names {
+		if m[i+1] == "unknown" {
+			m[i+1] = ""
+		}
+		vars[name] = m[i+1]
+	}
+	return vars, true
+}
+
+// escapeKeyValue 转义 key 中的单个变量值
+func escapeKeyValue(value string) string {
+	value = unsafeKeyChars.ReplaceAllString(value, "_")
+	if strings.Trim(value, ".") == "" {
+		// 空值以及 . 和 .. 不能作为路径段
+		return "unknown"
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyTemplateRender(t *testing.T) {
+	tmpl, err := ParseKeyTemplate("{cluster}/{namespace}/{pod}/{container}/{date}/{md5}-{filename}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := tmpl.Render(map[string]string{
+		"cluster":   "prod eu/1",
+		"namespace": "payments",
+		"pod":       "..",
+		"date":      "2026-10-18",
+		"md5":       "d41d8cd98f00b204e9800998ecf8427e",
+		"filename":  "core.app.1.host.1700000000",
+	})
+	want := "prod_eu_1/payments/unknown/unknown/2026-10-18/d41d8cd98f00b204e9800998ecf8427e-core.app.1.host.1700000000"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if !tmpl.ContentAddressed() {
+		t.Error("expected template with {md5} to be content addressed")
+	}
+
+	def, err := ParseKeyTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := def.Render(map[string]string{"filename": "core.app.1"}); got != "core.app.1" {
+		t.Errorf("default template rendered %q", got)
+	}
+	if def.ContentAddressed() || def.Unique() {
+		t.Error("default template is neither content addressed nor unique")
+	}
+	if !tmpl.Unique() {
+		t.Error("expected template with {md5} to be unique")
+	}
+}
+
+func TestParseKeyTemplateErrors(t *testing.T) {
+	for tmpl, want := range map[string]string{
+		"{namespace}/{hostname}/{filename}": "unknown variable",
+		"{namespace}/{pod}":                 "must contain",
+		"{namespace}/../{filename}":         "invalid path segment",
+		"{namespace}//{filename}":           "invalid path segment",
+	} {
+		if _, err := ParseKeyTemplate(tmpl); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected error containing %q, got %v", tmpl, want, err)
+		}
+	}
+}
+
+func TestKeyTemplateParse(t *testing.T) {
+	tmpl, err := ParseKeyTemplate("{namespace}/{workload}/{date}/{md5}-{filename}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars, ok := tmpl.Parse("payments/api/2026-10-18/d41d8cd98f00b204e9800998ecf8427e-core.app.1")
+	if !ok {
+		t.Fatal("expected key to match template")
+	}
+	want := map[string]string{
+		"namespace": "payments",
+		"workload":  "api",
+		"date":      "2026-10-18",
+		"md5":       "d41d8cd98f00b204e9800998ecf8427e",
+		"filename":  "core.app.1",
+	}
+	for k, v := range want {
+		if vars[k] != v {
+			t.Errorf("%s: got %q, want %q", k, vars[k], v)
+		}
+	}
+
+	// 渲染时的空值
+	vars, ok = tmpl.Parse("unknown/unknown/2026-10-18/unknown-core.app.1")
+	if !ok || vars["namespace"] != "" || vars["workload"] != "" {
+		t.Errorf("unexpected vars %v", vars)
+	}
+
+	// 修改模板前上传的对象
+	if _, ok := tmpl.Parse("core.app.1"); ok {
+		t.Error("expected key of the old layout not to match")
+	}
+}
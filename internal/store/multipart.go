@@ -0,0 +1,435 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Resumer 支持断点续传的 Store
+type Resumer interface {
+	// PendingUploads 返回上次未完成的上传，供 agent 启动后重新上传
+	// 本地文件已删除或已修改的上传会被放弃
+	PendingUploads(ctx context.Context) []PendingUpload
+	// AbortStaleUploads 放弃 StoreDir 下 before 之前开始、仍未完成且本地没有续传状态的分片上传，返回放弃的数量
+	AbortStaleUploads(ctx context.Context, before time.Time) (int, error)
+}
+
+// PendingUpload 一个上次未完成的上传
+type PendingUpload struct {
+	Path       string // 本地 core 文件
+	CoredumpID string // 第一次上传时的 coredump ID（见 WithCoredumpID），未记录时为空
+}
+
+type coredumpIDKey struct{}
+
+// WithCoredumpID 在 ctx 中携带正在上传的 core 的 coredump ID，分片上传时记录在续传状态中，
+// agent 重启后续传时沿用同一个 ID，不作为新的 core dump 上报
+func WithCoredumpID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, coredumpIDKey{}, id)
+}
+
+// CoredumpIDFrom 返回 ctx 中携带的 coredump ID，未设置时为空
+func CoredumpIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(coredumpIDKey{}).(string)
+	return id
+}
+
+// uploadState 一个未完成的分片上传，每个分片完成后写入 StateDir/uploads/ 下的状态文件
+type uploadState struct {
+	Path    string    `json:"path"` // 本地 core 文件
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Bucket  string    `json:"bucket"`
+	Key     string    `json:"key"` // 包含 StoreDir 的完整 key
+	// CoredumpID 第一次上传时的 coredump ID，旧版本写入的状态中为空
+	CoredumpID string         `json:"coredump_id,omitempty"`
+	UploadID   string         `json:"upload_id"`
+	PartSize   int64          `json:"part_size"`
+	Parts      []uploadedPart `json:"parts"`
+	Created    time.Time      `json:"created"`
+}
+
+type uploadedPart struct {
+	Number int64  `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// matches 状态是否仍对应同一个未修改的本地文件
+func (st *uploadState) matches(fi os.FileInfo, bucket string) bool {
+	return st.Size == fi.Size() && st.ModTime.Equal(fi.ModTime()) && st.Bucket == bucket
+}
+
+// partCount 文件的分片数
+func (st *uploadState) partCount() int64 {
+	return (st.Size + st.PartSize - 1) / st.PartSize
+}
+
+// partLength 第 n 个分片（从 1 开始）的大小
+func (st *uploadState) partLength(n int64) int64 {
+	if rest := st.Size - (n-1)*st.PartSize; rest < st.PartSize {
+		return rest
+	}
+	return st.PartSize
+}
+
+// stateDir 本地保存分片上传状态的目录，每个本地文件一个状态文件
+type stateDir string
+
+func (d stateDir) file(localPath string) string {
+	sum := sha256.Sum256([]byte(localPath))
+	return filepath.Join(string(d), hex.EncodeToString(sum[:8])+".json")
+}
+
+func (d stateDir) load(localPath string) *uploadState {
+	data, err := os.ReadFile(d.file(localPath))
+	if err != nil {
+		return nil
+	}
+	st := &uploadState{}
+	if err := json.Unmarshal(data, st); err != nil || st.Path != localPath || st.PartSize <= 0 {
+		logrus.Warnf("ignoring invalid upload state for %s", localPath)
+		return nil
+	}
+	return st
+}
+
+// save 先写临时文件再重命名，写入过程中掉电不会留下损坏的状态
+func (d stateDir) save(st *uploadState) error {
+	if err := os.MkdirAll(string(d), 0755); err != nil {
+		return errors.Wrap(err, "failed to create upload state directory")
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	path := d.file(st.Path)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrap(err, "failed to write upload state")
+	}
+	return errors.Wrap(os.Rename(tmp, path), "failed to write upload state")
+}
+
+func (d stateDir) remove(localPath string) {
+	if err := os.Remove(d.file(localPath)); err != nil && !os.IsNotExist(err) {
+		logrus.Warnf("failed to remove upload state of %s: %v", localPath, err)
+	}
+}
+
+func (d stateDir) list() []*uploadState {
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil
+	}
+	var states []*uploadState
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(string(d), e.Name()))
+		if err != nil {
+			continue
+		}
+		st := &uploadState{}
+		if err := json.Unmarshal(data, st); err != nil || st.Path == "" {
+			continue
+		}
+		states = append(states, st)
+	}
+	return states
+}
+
+// isNoSuchUpload 分片上传已完成、已放弃或已被生命周期规则清理
+func isNoSuchUpload(err error) bool {
+	var ae awserr.Error
+	return errors.As(err, &ae) && ae.Code() == s3.ErrCodeNoSuchUpload
+}
+
+// uploadResumable 分片上传，已上传的分片记录在本地状态中
+// 失败时保留已上传的分片和状态，下次上传同一个文件时通过 ListParts 确认已上传的分片并跳过；
+// 状态中的 key 与本次不同（例如 key 模板包含 {coredump_id}）时续传到原来的 key。返回实际使用的 key
+func (ss *S3Store) uploadResumable(ctx context.Context, f *os.File, fi os.FileInfo, localPath, key string, partSize int64) (string, error) {
+	states := stateDir(ss.Options.StateDir)
+	st := states.load(localPath)
+	if st != nil && !st.matches(fi, ss.Bucket) {
+		logrus.Infof("%s changed since its last upload attempt, starting over", localPath)
+		ss.abort(ctx, st)
+		states.remove(localPath)
+		st = nil
+	}
+	if st != nil {
+		parts, err := ss.listParts(ctx, st)
+		switch {
+		case isNoSuchUpload(err):
+			logrus.Infof("multipart upload of %s no longer exists, starting over", localPath)
+			states.remove(localPath)
+			st = nil
+		case err != nil:
+			return st.Key, errors.Wrap(err, "failed to list uploaded parts")
+		default:
+			st.Parts = parts
+			if st.Key != key {
+				logrus.Infof("resuming upload of %s to %s", localPath, st.Key)
+			}
+			logrus.Infof("resuming upload of %s: %d of %d parts already uploaded", localPath, len(parts), st.partCount())
+		}
+	}
+	if st == nil {
+		out, err := ss.s3.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(ss.Bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return key, err
+		}
+		st = &uploadState{
+			Path:       localPath,
+			Size:       fi.Size(),
+			ModTime:    fi.ModTime(),
+			Bucket:     ss.Bucket,
+			Key:        key,
+			UploadID:   aws.StringValue(out.UploadId),
+			CoredumpID: CoredumpIDFrom(ctx),
+			PartSize:   partSize,
+			Created:    time.Now().UTC(),
+		}
+		if err := states.save(st); err != nil {
+			return key, err
+		}
+	}
+
+	if err := ss.uploadParts(ctx, f, st, states); err != nil {
+		return st.Key, err
+	}
+
+	sort.Slice(st.Parts, func(i, j int) bool { return st.Parts[i].Number < st.Parts[j].Number })
+	completed := make([]*s3.CompletedPart, 0, len(st.Parts))
+	for _, p := range st.Parts {
+		completed = append(completed, &s3.CompletedPart{PartNumber: aws.Int64(p.Number), ETag: aws.String(p.ETag)})
+	}
+	var opts []request.Option
+	if ss.IfNoneMatch {
+		opts = append(opts, ifNoneMatch)
+	}
+	_, err := ss.s3.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(st.Bucket),
+		Key:             aws.String(st.Key),
+		UploadId:        aws.String(st.UploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	}, opts...)
+	switch {
+	case err == nil:
+	case isPreconditionFailed(err):
+		// 对象已存在，已上传的分片不再需要
+		ss.abort(ctx, st)
+	case isNoSuchUpload(err):
+	default:
+		return st.Key, err
+	}
+	states.remove(localPath)
+	return st.Key, err
+}
+
+// listParts 列出服务端已上传的分片，只保留大小与本地分片一致的
+func (ss *S3Store) listParts(ctx context.Context, st *uploadState) ([]uploadedPart, error) {
+	var parts []uploadedPart
+	err := ss.s3.ListPartsPagesWithContext(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String(st.Bucket),
+		Key:      aws.String(st.Key),
+		UploadId: aws.String(st.UploadID),
+	}, func(page *s3.ListPartsOutput, last bool) bool {
+		for _, p := range page.Parts {
+			n := aws.Int64Value(p.PartNumber)
+			if n < 1 || n > st.partCount() || aws.Int64Value(p.Size) != st.partLength(n) {
+				continue
+			}
+			parts = append(parts, uploadedPart{Number: n, ETag: aws.StringValue(p.ETag), Size: aws.Int64Value(p.Size)})
+		}
+		return true
+	})
+	return parts, err
+}
+
+// uploadParts 并发上传尚未上传的分片，每个分片完成后保存状态
+// 分片经过带宽限制读入内存，内存占用约为 PartSize × Concurrency
+func (ss *S3Store) uploadParts(ctx context.Context, f *os.File, st *uploadState, states stateDir) error {
+	done := make(map[int64]bool, len(st.Parts))
+	for _, p := range st.Parts {
+		done[p.Number] = true
+	}
+	todo := make(chan int64)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := ss.Options.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range todo {
+				part, err := ss.uploadPart(ctx, f, st, n)
+				if err != nil {
+					fail(errors.Wrapf(err, "failed to upload part %d of %d", n, st.partCount()))
+					continue
+				}
+				mu.Lock()
+				st.Parts = append(st.Parts, part)
+				err = states.save(st)
+				mu.Unlock()
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+	for n := int64(1); n <= st.partCount(); n++ {
+		if done[n] {
+			continue
+		}
+		select {
+		case todo <- n:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(todo)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+func (ss *S3Store) uploadPart(ctx context.Context, f *os.File, st *uploadState, n int64) (uploadedPart, error) {
+	size := st.partLength(n)
+	buf := make([]byte, size)
+	section := io.NewSectionReader(f, (n-1)*st.PartSize, size)
+	if _, err := io.ReadFull(ss.Options.Limiter.Reader(ctx, section), buf); err != nil {
+		return uploadedPart{}, err
+	}
+	out, err := ss.s3.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(st.Bucket),
+		Key:        aws.String(st.Key),
+		UploadId:   aws.String(st.UploadID),
+		PartNumber: aws.Int64(n),
+		Body:       bytes.NewReader(buf),
+	})
+	if err != nil {
+		return uploadedPart{}, err
+	}
+	return uploadedPart{Number: n, ETag: aws.StringValue(out.ETag), Size: size}, nil
+}
+
+// abort 放弃分片上传，失败时只记录日志，由 AbortStaleUploads 兜底
+func (ss *S3Store) abort(ctx context.Context, st *uploadState) {
+	_, err := ss.s3.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(st.Bucket),
+		Key:      aws.String(st.Key),
+		UploadId: aws.String(st.UploadID),
+	})
+	if err != nil && !isNoSuchUpload(err) {
+		logrus.Warnf("failed to abort multipart upload of %s: %v", st.Key, err)
+	}
+}
+
+// PendingUploads 本地文件仍存在且未修改的未完成上传，其余的上传被放弃
+func (ss *S3Store) PendingUploads(ctx context.Context) []PendingUpload {
+	if ss.Options.StateDir == "" {
+		return nil
+	}
+	states := stateDir(ss.Options.StateDir)
+	var pending []PendingUpload
+	for _, st := range states.list() {
+		if fi, err := os.Stat(st.Path); err == nil && st.matches(fi, ss.Bucket) {
+			pending = append(pending, PendingUpload{Path: st.Path, CoredumpID: st.CoredumpID})
+			continue
+		}
+		logrus.Infof("abandoning upload of %s: local file was removed or changed", st.Path)
+		ss.abort(ctx, st)
+		states.remove(st.Path)
+	}
+	return pending
+}
+
+// AbortStaleUploads 放弃 StoreDir 下 before 之前开始的未完成分片上传
+// 本地状态中记录的上传会被续传，不会被放弃；没有本地状态时（coredog gc）只依靠时间判断
+func (ss *S3Store) AbortStaleUploads(ctx context.Context, before time.Time) (int, error) {
+	keep := make(map[string]bool)
+	if ss.Options.StateDir != "" {
+		for _, st := range stateDir(ss.Options.StateDir).list() {
+			keep[st.UploadID] = true
+		}
+	}
+	prefix := ""
+	if ss.StoreDir != "" {
+		prefix = strings.TrimSuffix(ss.StoreDir, "/") + "/"
+	}
+
+	var stale []*s3.MultipartUpload
+	err := ss.s3.ListMultipartUploadsPagesWithContext(ctx, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(ss.Bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListMultipartUploadsOutput, last bool) bool {
+		for _, u := range page.Uploads {
+			if !keep[aws.StringValue(u.UploadId)] && aws.TimeValue(u.Initiated).Before(before) {
+				stale = append(stale, u)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list multipart uploads")
+	}
+
+	aborted := 0
+	for _, u := range stale {
+		_, err := ss.s3.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(ss.Bucket),
+			Key:      u.Key,
+			UploadId: u.UploadId,
+		})
+		if err != nil && !isNoSuchUpload(err) {
+			logrus.Warnf("failed to abort multipart upload of %s: %v", aws.StringValue(u.Key), err)
+			continue
+		}
+		logrus.Infof("aborted stale multipart upload of %s started at %s", aws.StringValue(u.Key), aws.TimeValue(u.Initiated).Format(time.RFC3339))
+		aborted++
+	}
+	return aborted, nil
+}
@@ -0,0 +1,274 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// fakeUpload fakeS3 中未完成的分片上传
+type fakeUpload struct {
+	path      string // /<bucket>/<key>
+	parts     map[int64][]byte
+	initiated time.Time
+	partPuts  int // 收到的 UploadPart 请求数
+}
+
+// multipart 处理分片上传相关的请求，不是分片上传请求时返回 false
+func (f *fakeS3) multipart(w http.ResponseWriter, r *http.Request) bool {
+	q := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		if f.uploads == nil {
+			f.uploads = make(map[string]*fakeUpload)
+		}
+		id := fmt.Sprintf("upload-%d", time.Now().UnixNano())
+		f.uploads[id] = &fakeUpload{path: r.URL.Path, parts: make(map[int64][]byte), initiated: time.Now()}
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, id)
+	case r.Method == http.MethodGet && q.Has("uploads"):
+		bucket := "/" + strings.Trim(r.URL.Path, "/") + "/"
+		var ids []string
+		for id, u := range f.uploads {
+			if key := strings.TrimPrefix(u.path, bucket); key != u.path && strings.HasPrefix(key, q.Get("prefix")) {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		io.WriteString(w, `<ListMultipartUploadsResult><IsTruncated>false</IsTruncated>`)
+		for _, id := range ids {
+			u := f.uploads[id]
+			fmt.Fprintf(w, `<Upload><Key>%s</Key><UploadId>%s</UploadId><Initiated>%s</Initiated></Upload>`,
+				strings.TrimPrefix(u.path, bucket), id, u.initiated.UTC().Format(time.RFC3339))
+		}
+		io.WriteString(w, `</ListMultipartUploadsResult>`)
+	case q.Has("uploadId"):
+		u, ok := f.uploads[q.Get("uploadId")]
+		if !ok || u.path != r.URL.Path {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchUpload</Code><Message>The specified upload does not exist</Message></Error>`)
+			return true
+		}
+		f.uploadRequest(w, r, q.Get("uploadId"), u)
+	default:
+		return false
+	}
+	return true
+}
+
+func (f *fakeS3) uploadRequest(w http.ResponseWriter, r *http.Request, id string, u *fakeUpload) {
+	switch r.Method {
+	case http.MethodPut:
+		n, _ := strconv.ParseInt(r.URL.Query().Get("partNumber"), 10, 64)
+		body, _ := io.ReadAll(r.Body)
+		u.partPuts++
+		if n == f.failPart {
+			http.Error(w, "injected failure", http.StatusInternalServerError)
+			return
+		}
+		u.parts[n] = body
+		w.Header().Set("ETag", partETag(n, body))
+	case http.MethodGet:
+		var numbers []int64
+		for n := range u.parts {
+			numbers = append(numbers, n)
+		}
+		sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+		io.WriteString(w, `<ListPartsResult><IsTruncated>false</IsTruncated>`)
+		for _, n := range numbers {
+			fmt.Fprintf(w, `<Part><PartNumber>%d</PartNumber><ETag>%s</ETag><Size>%d</Size></Part>`, n, partETag(n, u.parts[n]), len(u.parts[n]))
+		}
+		io.WriteString(w, `</ListPartsResult>`)
+	case http.MethodPost:
+		var req struct {
+			Parts []struct {
+				PartNumber int64
+				ETag       string
+			} `xml:"Part"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := xml.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, ok := f.objects[u.path]; ok && r.Header.Get("If-None-Match") == "*" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			io.WriteString(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+			return
+		}
+		var object []byte
+		for i, p := range req.Parts {
+			data, ok := u.parts[p.PartNumber]
+			if !ok || p.PartNumber != int64(i+1) || p.ETag != partETag(p.PartNumber, data) {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `<Error><Code>InvalidPart</Code><Message>invalid part</Message></Error>`)
+				return
+			}
+			object = append(object, data...)
+		}
+		f.objects[u.path] = object
+		delete(f.uploads, id)
+		io.WriteString(w, `<CompleteMultipartUploadResult></CompleteMultipartUploadResult>`)
+	case http.MethodDelete:
+		delete(f.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "not implemented", http.StatusNotImplemented)
+	}
+}
+
+func partETag(n int64, data []byte) string {
+	return fmt.Sprintf(`"part-%d-%d"`, n, len(data))
+}
+
+// writeLargeCore 写入按最小分片大小分为 4 个分片的 core 文件，每个分片内容不同
+func writeLargeCore(t *testing.T) (string, []byte) {
+	t.Helper()
+	var data []byte
+	for i := 0; i < 3; i++ {
+		data = append(data, bytes.Repeat([]byte{byte('a' + i)}, int(s3manager.MinUploadPartSize))...)
+	}
+	data = append(data, "tail"...)
+	path := filepath.Join(t.TempDir(), "core.app.1")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func TestS3StoreResumeUpload(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{objects: make(map[string][]byte), uploads: make(map[string]*fakeUpload)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	dir := t.TempDir()
+	corePath, data := writeLargeCore(t)
+
+	s := newTestS3Store(t, server, true)
+	s.Options = UploadOptions{PartSize: s3manager.MinUploadPartSize, Concurrency: 1, StateDir: dir}
+
+	// 第三个分片失败：前两个分片保留，状态（包括 coredump ID）写入本地
+	fake.failPart = 3
+	if _, err := s.Upload(WithCoredumpID(ctx, "dump-1"), corePath, "default/core.app.1"); err == nil {
+		t.Fatal("expected upload to fail")
+	}
+	if len(fake.uploads) != 1 {
+		t.Fatalf("expected the incomplete upload to be kept, got %d", len(fake.uploads))
+	}
+	st := stateDir(dir).load(corePath)
+	if st == nil || len(st.Parts) != 2 {
+		t.Fatalf("expected state with 2 parts, got %+v", st)
+	}
+
+	// agent 重启后：重新上传同一个文件，只上传剩余的分片
+	s = newTestS3Store(t, server, true)
+	s.Options = UploadOptions{PartSize: s3manager.MinUploadPartSize, Concurrency: 2, StateDir: dir}
+	if pending := s.PendingUploads(ctx); len(pending) != 1 || pending[0] != (PendingUpload{Path: corePath, CoredumpID: "dump-1"}) {
+		t.Fatalf("pending uploads = %v", pending)
+	}
+	fake.failPart = 0
+	upload := fake.uploads[st.UploadID]
+	puts := upload.partPuts
+	url, err := s.Upload(ctx, corePath, "default/core.app.1")
+	if err != nil || url == "" {
+		t.Fatalf("resume failed: (%q, %v)", url, err)
+	}
+	if n := upload.partPuts - puts; n != 2 {
+		t.Errorf("expected only the 2 missing parts to be uploaded, got %d part uploads", n)
+	}
+	if !bytes.Equal(fake.objects["/corefiles/dumps/default/core.app.1"], data) {
+		t.Error("uploaded object does not match the core file")
+	}
+	if len(fake.uploads) != 0 || stateDir(dir).load(corePath) != nil || len(s.PendingUploads(ctx)) != 0 {
+		t.Error("expected upload and local state to be cleaned up")
+	}
+
+	// 开启 IfNoneMatch 时对象已存在：放弃分片上传
+	_, err = s.Upload(ctx, corePath, "default/core.app.1")
+	if !errors.Is(err, ErrObjectExists) {
+		t.Fatalf("expected ErrObjectExists, got %v", err)
+	}
+	if len(fake.uploads) != 0 || stateDir(dir).load(corePath) != nil {
+		t.Error("expected the upload to be aborted")
+	}
+}
+
+func TestS3StorePendingUploadsChangedFile(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{objects: make(map[string][]byte), uploads: make(map[string]*fakeUpload)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	dir := t.TempDir()
+	corePath, _ := writeLargeCore(t)
+
+	s := newTestS3Store(t, server, false)
+	s.Options = UploadOptions{Concurrency: 1, StateDir: dir}
+	fake.failPart = 2
+	if _, err := s.Upload(ctx, corePath, "default/core.app.1"); err == nil {
+		t.Fatal("expected upload to fail")
+	}
+
+	// 本地文件已删除：放弃上传
+	if err := os.Remove(corePath); err != nil {
+		t.Fatal(err)
+	}
+	if pending := s.PendingUploads(ctx); len(pending) != 0 {
+		t.Errorf("pending uploads = %v", pending)
+	}
+	if len(fake.uploads) != 0 {
+		t.Error("expected the upload to be aborted")
+	}
+}
+
+func TestS3StoreAbortStaleUploads(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	fake := &fakeS3{
+		objects: make(map[string][]byte),
+		uploads: map[string]*fakeUpload{
+			"stale":   {path: "/corefiles/dumps/default/core.app.1", initiated: now.Add(-96 * time.Hour)},
+			"recent":  {path: "/corefiles/dumps/default/core.app.2", initiated: now.Add(-time.Hour)},
+			"other":   {path: "/corefiles/other/core.app.3", initiated: now.Add(-96 * time.Hour)},
+			"resumed": {path: "/corefiles/dumps/default/core.app.4", initiated: now.Add(-96 * time.Hour)},
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	// 本地状态中记录的上传会被续传，不放弃
+	dir := t.TempDir()
+	if err := stateDir(dir).save(&uploadState{Path: "/cores/core.app.4", UploadID: "resumed", PartSize: DefaultPartSize}); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestS3Store(t, server, false)
+	s.Options = UploadOptions{StateDir: dir}
+
+	n, err := s.AbortStaleUploads(ctx, now.Add(-72*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("aborted %d uploads, want 1", n)
+	}
+	var left []string
+	for id := range fake.uploads {
+		left = append(left, id)
+	}
+	sort.Strings(left)
+	if strings.Join(left, ",") != "other,recent,resumed" {
+		t.Errorf("remaining uploads = %v", left)
+	}
+}
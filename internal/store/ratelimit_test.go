@@ -0,0 +1,53 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReader(t *testing.T) {
+	if r := (*RateLimiter)(nil).Reader(context.Background(), bytes.NewReader(nil)); r == nil {
+		t.Fatal("nil limiter should return the reader itself")
+	}
+	if NewRateLimiter(0) != nil {
+		t.Error("zero bandwidth should not be limited")
+	}
+
+	// 64KiB/s，突发 32KiB：读取 96KiB 至少需要 1 秒
+	l := NewRateLimiter(64 * 1024)
+	data := make([]byte, 96*1024)
+	started := time.Now()
+	n, err := io.Copy(io.Discard, l.Reader(context.Background(), bytes.NewReader(data)))
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("copy = %d, %v", n, err)
+	}
+	if elapsed := time.Since(started); elapsed < 900*time.Millisecond {
+		t.Errorf("read 96KiB in %v, expected at least ~1s", elapsed)
+	}
+
+	// context 取消时停止等待
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := io.Copy(io.Discard, l.Reader(ctx, bytes.NewReader(data))); err == nil {
+		t.Error("expected error after context is cancelled")
+	}
+}
+
+func TestPartSize(t *testing.T) {
+	tests := []struct {
+		configured, size, want int64
+	}{
+		{0, 1 << 20, DefaultPartSize},
+		{1 << 20, 1 << 20, 5 << 20},            // 不小于 S3 最小分片
+		{64 << 20, 1 << 30, 64 << 20},          // 配置值
+		{DefaultPartSize, 200 << 30, 21474837}, // 200GiB 需要增大分片，保证不超过 10000 个
+	}
+	for _, tt := range tests {
+		if got := partSize(tt.configured, tt.size); got != tt.want {
+			t.Errorf("partSize(%d, %d) = %d, want %d", tt.configured, tt.size, got, tt.want)
+		}
+	}
+}
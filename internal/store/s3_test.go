@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// fakeS3 只支持 PutObject、ListObjectsV2、DeleteObject、GetObjectTagging 和分片上传的 S3 服务，
+// PutObject 和 CompleteMultipartUpload 遵循 If-None-Match: * 语义
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte            // /<bucket>/<key> -> 内容
+	tags     map[string]map[string]string // /<bucket>/<key> -> 标签
+	uploads  map[string]*fakeUpload       // upload id -> 未完成的分片上传
+	failPart int64                        // 上传该分片时返回 500，模拟上传中断
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.multipart(w, r) {
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		f.list(w, r)
+		return
+	case r.Method == http.MethodGet && r.URL.Query().Has("tagging"):
+		io.WriteString(w, `<Tagging><TagSet>`)
+		for k, v := range f.tags[r.URL.Path] {
+			fmt.Fprintf(w, `<Tag><Key>%s</Key><Value>%s</Value></Tag>`, k, v)
+		}
+		io.WriteString(w, `</TagSet></Tagging>`)
+		return
+	case r.Method == http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case r.Method != http.MethodPut:
+		http.Error(w, "not implemented", http.StatusNotImplemented)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+
+	if _, ok := f.objects[r.URL.Path]; ok && r.Header.Get("If-None-Match") == "*" {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+		return
+	}
+	f.objects[r.URL.Path] = body
+	w.Header().Set("ETag", `"etag"`)
+}
+
+// list 返回 bucket 中带 prefix 的全部对象（不分页）
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	bucket := "/" + strings.Trim(r.URL.Path, "/") + "/"
+	prefix := r.URL.Query().Get("prefix")
+	var keys []string
+	for p := range f.objects {
+		if key := strings.TrimPrefix(p, bucket); key != p && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	io.WriteString(w, `<ListBucketResult><IsTruncated>false</IsTruncated>`)
+	for _, key := range keys {
+		fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size><LastModified>2026-10-18T08:00:00.000Z</LastModified></Contents>`,
+			key, len(f.objects[bucket+key]))
+	}
+	io.WriteString(w, `</ListBucketResult>`)
+}
+
+// newTestS3Store 创建指向 fake S3 的 S3Store（path-style）
+func newTestS3Store(t *testing.T, server *httptest.Server, ifNoneMatch bool) *S3Store {
+	t.Helper()
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Credentials:      credentials.NewStaticCredentials("akid", "secret", ""),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+	}))
+	return &S3Store{
+		Bucket:        "corefiles",
+		StoreDir:      "dumps",
+		IfNoneMatch:   ifNoneMatch,
+		s3:            s3.New(sess),
+		uploader:      s3manager.NewUploader(sess),
+		PresignExpire: 3600,
+	}
+}
+
+func TestS3StoreIfNoneMatch(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s := newTestS3Store(t, server, true)
+	if _, err := s.Upload(context.Background(), writeCore(t, "first"), "default/core.app.1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	url, err := s.Upload(context.Background(), writeCore(t, "second"), "default/core.app.1")
+	if !errors.Is(err, ErrObjectExists) || url == "" {
+		t.Fatalf("expected ErrObjectExists with url, got (%q, %v)", url, err)
+	}
+	if got := string(fake.objects["/corefiles/dumps/default/core.app.1"]); got != "first" {
+		t.Errorf("object was overwritten: %q", got)
+	}
+
+	// 未开启时覆盖
+	s = newTestS3Store(t, server, false)
+	if _, err := s.Upload(context.Background(), writeCore(t, "third"), "default/core.app.1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(fake.objects["/corefiles/dumps/default/core.app.1"]); got != "third" {
+		t.Errorf("expected object to be overwritten, got %q", got)
+	}
+}
+
+func TestS3StoreIfNoneMatchMultipart(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{"/corefiles/dumps/default/core.app.1": []byte("first")}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	// CompleteMultipartUpload 返回 412 时放弃分片上传，不留下分片
+	s := newTestS3Store(t, server, true)
+	s.Options = UploadOptions{PartSize: s3manager.MinUploadPartSize}
+	path, _ := writeLargeCore(t)
+	if _, err := s.Upload(context.Background(), path, "default/core.app.1"); !errors.Is(err, ErrObjectExists) {
+		t.Fatalf("expected ErrObjectExists, got %v", err)
+	}
+	if len(fake.uploads) != 0 {
+		t.Errorf("expected multipart upload to be aborted, %d left", len(fake.uploads))
+	}
+}
+
+func TestS3StoreRateLimited(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s := newTestS3Store(t, server, false)
+	s.Options = UploadOptions{Concurrency: 1, Limiter: NewRateLimiter(1 << 20)}
+	if _, err := s.Upload(context.Background(), writeCore(t, "throttled"), "default/core.app.1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(fake.objects["/corefiles/dumps/default/core.app.1"]); got != "throttled" {
+		t.Errorf("object = %q", got)
+	}
+}
+
+func TestS3StoreManage(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{
+		objects: map[string][]byte{
+			"/corefiles/dumps/default/core.app.1":  []byte("core"),
+			"/corefiles/dumps/payments/core.api.2": []byte("core2"),
+			"/corefiles/other/core.app.3":          []byte("x"),
+		},
+		tags: map[string]map[string]string{
+			"/corefiles/dumps/payments/core.api.2": {"legal-hold": "true"},
+			"/corefiles/dumps/default/core.app.1":  {"legal-hold": "false"},
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	s := newTestS3Store(t, server, false)
+
+	objects, err := s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Object{
+		{Key: "default/core.app.1", Size: 4, LastModified: time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)},
+		{Key: "payments/core.api.2", Size: 5, LastModified: time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)},
+	}
+	if len(objects) != len(want) || objects[0] != want[0] || objects[1] != want[1] {
+		t.Fatalf("got %+v, want %+v", objects, want)
+	}
+
+	if held, err := s.LegalHold(ctx, "payments/core.api.2", "legal-hold"); err != nil || !held {
+		t.Errorf("expected legal hold, got (%v, %v)", held, err)
+	}
+	if held, err := s.LegalHold(ctx, "default/core.app.1", "legal-hold"); err != nil || held {
+		t.Errorf("legal-hold=false should not hold, got (%v, %v)", held, err)
+	}
+
+	if err := s.Delete(ctx, "default/core.app.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects["/corefiles/dumps/default/core.app.1"]; ok {
+		t.Error("expected object to be deleted")
+	}
+}
@@ -2,21 +2,62 @@ package store
 
 import (
 	"context"
+	"net/http"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/pkg/errors"
 )
 
+// ErrObjectExists 开启 IfNoneMatch 时目标对象已存在，未覆盖
+var ErrObjectExists = errors.New("object already exists")
+
+// S3 分片上传的默认参数
+const (
+	DefaultPartSize    = 10 * 1024 * 1024
+	DefaultConcurrency = 3
+)
+
+// UploadOptions 上传参数，零值使用默认值
+type UploadOptions struct {
+	PartSize    int64        // S3 分片大小，不小于 5MiB；文件超过 10000 个分片时自动增大
+	Concurrency int          // S3 同时上传的分片数
+	Limiter     *RateLimiter // 带宽限制，同一个 agent 的所有上传共享
+	// StateDir 保存分片上传状态的本地目录，为空时不支持断点续传
+	// 设置后超过一个分片的文件在失败时保留已上传的分片，再次上传同一个文件时跳过已上传的分片
+	StateDir string
+}
+
 type Store interface {
-	Upload(ctx context.Context, filepath string) (downloadurl string, err error)
+	// Upload 上传文件，key 为 StoreDir 下的相对路径（见 KeyTemplate）
+	// 开启 IfNoneMatch 且目标已存在时返回已存在对象的下载地址以及 ErrObjectExists
+	Upload(ctx context.Context, filepath, key string) (downloadurl string, err error)
+}
+
+// Object StoreDir 下的一个对象
+type Object struct {
+	Key          string // StoreDir 下的相对路径
+	Size         int64
+	LastModified time.Time
+}
+
+// Manager 支持列出和删除对象的 Store，供保留策略（coredog gc）使用
+type Manager interface {
+	// List 列出 StoreDir 下的所有对象
+	List(ctx context.Context) ([]Object, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// LegalHold 对象是否带有 legal hold 标记：S3 为对象标签 tag（值不为 false），CFS 为同目录下的 .<文件名>.<tag> 标记文件
+	LegalHold(ctx context.Context, key, tag string) (bool, error)
 }
 
 type S3Store struct {
@@ -26,30 +67,55 @@ type S3Store struct {
 	Bucket          string
 	StoreDir        string
 	Endpoint        string
+	IfNoneMatch     bool // 上传时带 If-None-Match: *，不覆盖已存在的对象
+	Options         UploadOptions
 	s3              *s3.S3
 	uploader        *s3manager.Uploader
 	PresignExpire   time.Duration
 }
 
-func (ss *S3Store) Upload(ctx context.Context, path string) (downloadurl string, err error) {
-	f, err := os.Open(path)
+func (ss *S3Store) Upload(ctx context.Context, filepath, key string) (downloadurl string, err error) {
+	f, err := os.Open(filepath)
 	if err != nil {
 		return
 	}
-	_, filename := filepath.Split(path)
-	key := filepath.Join(ss.StoreDir, filename)
-	_, err = ss.uploader.Upload(&s3manager.UploadInput{
-		Bucket: &ss.Bucket,
-		Key:    &key,
-		Body:   f,
-	}, func(u *s3manager.Uploader) {
-		u.PartSize = 10 * 1024 * 1024 // Multipart upload
-		u.LeavePartsOnError = true
-		u.Concurrency = 3
-	})
+	defer f.Close()
+	fi, err := f.Stat()
 	if err != nil {
 		return
 	}
+	key = path.Join(ss.StoreDir, key)
+	part := partSize(ss.Options.PartSize, fi.Size())
+	if ss.Options.StateDir != "" && fi.Size() > part {
+		key, err = ss.uploadResumable(ctx, f, fi, filepath, key, part)
+	} else {
+		_, err = ss.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+			Bucket: &ss.Bucket,
+			Key:    &key,
+			Body:   ss.Options.Limiter.Reader(ctx, f),
+		}, func(u *s3manager.Uploader) {
+			u.PartSize = part // Multipart upload
+			u.LeavePartsOnError = true
+			u.Concurrency = ss.Options.Concurrency
+			if u.Concurrency <= 0 {
+				u.Concurrency = DefaultConcurrency
+			}
+			if ss.IfNoneMatch {
+				u.RequestOptions = append(u.RequestOptions, ifNoneMatch)
+			}
+		})
+	}
+	var exists bool
+	if err != nil {
+		if !isPreconditionFailed(err) {
+			return
+		}
+		// LeavePartsOnError 时 CompleteMultipartUpload 返回 412 后分片仍保留在服务端，对象已存在，不再需要
+		if mf, ok := err.(s3manager.MultiUploadFailure); ok {
+			ss.abort(ctx, &uploadState{Bucket: ss.Bucket, Key: key, UploadID: mf.UploadID()})
+		}
+		exists = true
+	}
 	req, _ := ss.s3.GetObjectRequest(&s3.GetObjectInput{
 		Bucket: aws.String(ss.Bucket),
 		Key:    aws.String(key),
@@ -61,10 +127,111 @@ func (ss *S3Store) Upload(ctx context.Context, path string) (downloadurl string,
 	} else {
 		downloadurl = urlStr
 	}
+	if exists {
+		err = errors.Wrapf(ErrObjectExists, "s3://%s/%s", ss.Bucket, key)
+	}
 	return
 }
 
-func NewS3Store(region, akid, aksecret, bucket, endpoint, storedir string, presignExpire int) (Store, error) {
+// List 分页列出 StoreDir 下的对象
+func (ss *S3Store) List(ctx context.Context) ([]Object, error) {
+	prefix := ""
+	if dir := strings.Trim(ss.StoreDir, "/"); dir != "" {
+		prefix = dir + "/"
+	}
+	var objects []Object
+	err := ss.s3.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(ss.Bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, o := range page.Contents {
+			key := strings.TrimPrefix(aws.StringValue(o.Key), prefix)
+			if key == "" || strings.HasSuffix(key, "/") {
+				continue // 目录占位对象
+			}
+			objects = append(objects, Object{
+				Key:          key,
+				Size:         aws.Int64Value(o.Size),
+				LastModified: aws.TimeValue(o.LastModified),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list s3://%s/%s", ss.Bucket, prefix)
+	}
+	return objects, nil
+}
+
+// Delete 删除对象，S3 删除不存在的对象同样返回成功
+func (ss *S3Store) Delete(ctx context.Context, key string) error {
+	_, err := ss.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(ss.Bucket),
+		Key:    aws.String(path.Join(ss.StoreDir, key)),
+	})
+	return errors.Wrapf(err, "failed to delete %s", key)
+}
+
+// LegalHold 对象是否带有标签 tag，值为 false 时视为未标记
+func (ss *S3Store) LegalHold(ctx context.Context, key, tag string) (bool, error) {
+	out, err := ss.s3.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(ss.Bucket),
+		Key:    aws.String(path.Join(ss.StoreDir, key)),
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get tags of %s", key)
+	}
+	for _, t := range out.TagSet {
+		if aws.StringValue(t.Key) == tag {
+			return !strings.EqualFold(aws.StringValue(t.Value), "false"), nil
+		}
+	}
+	return false, nil
+}
+
+// partSize 分片大小：Body 不是 io.ReaderAt 时 s3manager 不知道文件大小，需要保证分片数不超过 MaxUploadParts
+func partSize(configured, size int64) int64 {
+	if configured <= 0 {
+		configured = DefaultPartSize
+	}
+	if configured < s3manager.MinUploadPartSize {
+		configured = s3manager.MinUploadPartSize
+	}
+	if least := (size + s3manager.MaxUploadParts - 1) / s3manager.MaxUploadParts; configured < least {
+		configured = least
+	}
+	return configured
+}
+
+// ifNoneMatch 在创建对象的请求（PutObject、CompleteMultipartUpload）上设置 If-None-Match: *
+// 对象已存在时 S3 返回 412 Precondition Failed
+func ifNoneMatch(r *request.Request) {
+	switch r.Operation.Name {
+	case "PutObject", "CompleteMultipartUpload":
+		r.HTTPRequest.Header.Set("If-None-Match", "*")
+	}
+}
+
+// isPreconditionFailed 判断上传是否因 If-None-Match 条件不满足（412）而失败
+// s3manager 会把底层请求错误包装在 awserr.Error 中
+func isPreconditionFailed(err error) bool {
+	for err != nil {
+		if rf, ok := err.(awserr.RequestFailure); ok && rf.StatusCode() == http.StatusPreconditionFailed {
+			return true
+		}
+		ae, ok := err.(awserr.Error)
+		if !ok {
+			return false
+		}
+		err = ae.OrigErr()
+	}
+	return false
+}
+
+func NewS3Store(region, akid, aksecret, bucket, endpoint, storedir string, presignExpire int, ifNoneMatch bool, opts UploadOptions) (Store, error) {
+	if opts.PartSize != 0 && opts.PartSize < s3manager.MinUploadPartSize {
+		return nil, errors.Errorf("part size %d is smaller than the S3 minimum %d", opts.PartSize, s3manager.MinUploadPartSize)
+	}
 	sess := session.Must(session.NewSession(&aws.Config{
 		Region:           &region,
 		Credentials:      credentials.NewStaticCredentials(akid, aksecret, ""),
@@ -82,6 +249,8 @@ func NewS3Store(region, akid, aksecret, bucket, endpoint, storedir string, presi
 		SecretAccessKey: aksecret,
 		Bucket:          bucket,
 		StoreDir:        storedir,
+		IfNoneMatch:     ifNoneMatch,
+		Options:         opts,
 		PresignExpire:   time.Duration(presignExpire * int(time.Second)),
 	}
 	store.uploader = uploader
@@ -90,7 +259,9 @@ func NewS3Store(region, akid, aksecret, bucket, endpoint, storedir string, presi
 
 // NewStore creates a Store instance based on the protocol
 // protocol: "s3" for S3/COS, "cfs" for CFS
-func NewStore(protocol, region, akid, aksecret, bucket, endpoint, cfsMountPath, storedir string, presignExpire int) (Store, error) {
+// ifNoneMatch: do not overwrite existing objects
+// opts: part size, concurrency, bandwidth limit and resume state directory of uploads
+func NewStore(protocol, region, akid, aksecret, bucket, endpoint, cfsMountPath, storedir string, presignExpire int, ifNoneMatch bool, opts UploadOptions) (Store, error) {
 	protocol = strings.ToLower(strings.TrimSpace(protocol))
 	if protocol == "" {
 		protocol = "s3"
@@ -98,9 +269,9 @@ func NewStore(protocol, region, akid, aksecret, bucket, endpoint, cfsMountPath,
 
 	switch protocol {
 	case "s3", "cos":
-		return NewS3Store(region, akid, aksecret, bucket, endpoint, storedir, presignExpire)
+		return NewS3Store(region, akid, aksecret, bucket, endpoint, storedir, presignExpire, ifNoneMatch, opts)
 	case "cfs":
-		return NewCFSStore(cfsMountPath, storedir)
+		return NewCFSStore(cfsMountPath, storedir, ifNoneMatch, opts.Limiter)
 	default:
 		return nil, errors.Errorf("unsupported storage protocol: %s", protocol)
 	}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -81,6 +82,20 @@ func (fw *FileWatcher) Watch(dir string) error {
 	return nil
 }
 
+// Unwatch 移除 dir 及其所有子目录的监听，在目录删除后调用
+// 按已监听的路径匹配，目录已不存在时同样有效（内核已移除的监听会被忽略）
+func (fw *FileWatcher) Unwatch(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	for _, path := range fw.watch.WatchList() {
+		if path == abs || strings.HasPrefix(path, abs+string(filepath.Separator)) {
+			fw.watch.Remove(path)
+		}
+	}
+}
+
 func (fw *FileWatcher) watchEvents() {
 	for {
 		select {
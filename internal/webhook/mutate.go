@@ -1,7 +1,6 @@
 package webhook
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -9,6 +8,8 @@ import (
 	"strings"
 
 	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	"github.com/DomineCore/coredog/internal/notice"
+	"github.com/DomineCore/coredog/internal/quota"
 	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -43,6 +44,8 @@ type MutateHandler struct {
 	PathBase   string
 	MountPath  string
 	VolumeName string
+	// QuotaEnabled 开启上传配额（Quota.enabled）时校验被注入 Pod 的配额 annotation
+	QuotaEnabled bool
 }
 
 func NewMutateHandler() *MutateHandler {
@@ -143,6 +146,22 @@ func (h *MutateHandler) mutatePods(ar *admissionv1.AdmissionReview) *admissionv1
 		}
 	}
 
+	// 校验配额 annotation：无效的值在 agent 中会被忽略，开启配额时拒绝被注入的 Pod 以便及时发现
+	// 未开启配额或不注入的 Pod 不受影响，webhook 的 failurePolicy 不会因此阻止无关的 Pod
+	if h.QuotaEnabled {
+		if err := quota.ValidateAnnotations(pod.Annotations); err != nil {
+			logrus.Warnf("Reject pod %s/%s - invalid quota annotations: %v", req.Namespace, req.Name, err)
+			return &admissionv1.AdmissionResponse{
+				Allowed: false,
+				Result: &metav1.Status{
+					Code:    http.StatusUnprocessableEntity,
+					Reason:  metav1.StatusReasonInvalid,
+					Message: fmt.Sprintf("invalid coredog quota annotations: %v", err),
+				},
+			}
+		}
+	}
+
 	// 生成 patch
 	patches := h.createPatch(&pod, req)
 	if len(patches) == 0 {
@@ -429,7 +448,7 @@ func (h *MutateHandler) createPatch(pod *corev1.Pod, req *admissionv1.AdmissionR
 }
 
 // sendAlert sends alert message to configured notification channels
-// It reuses the NoticeChannel configuration from config file
+// It reuses the NoticeChannel configuration and notifiers shared with the agent
 func (h *MutateHandler) sendAlert(message string) {
 	cfg := cfgpkg.Get()
 
@@ -437,47 +456,21 @@ func (h *MutateHandler) sendAlert(message string) {
 		logrus.Warnf("NoticeChannel not configured, skip sending webhook alert")
 		return
 	}
+	sendToChannels(cfg.NoticeChannel, message)
+}
 
-	// 发送到所有配置的通知渠道
-	for _, ch := range cfg.NoticeChannel {
-		var payload []byte
-		var err error
-
-		switch ch.Chan {
-		case "wechat":
-			payload, err = json.Marshal(map[string]interface{}{
-				"msgtype": "text",
-				"text": map[string]string{
-					"content": message,
-				},
-			})
-		case "slack":
-			payload, err = json.Marshal(map[string]interface{}{
-				"text": message,
-			})
-		default:
-			payload, err = json.Marshal(map[string]interface{}{
-				"content": message,
-			})
-		}
-
+// sendToChannels 发送到所有通知渠道
+// 未知的渠道类型按以往的行为 POST {"content": ...}（即 chan=webhook 的默认请求体）
+func sendToChannels(channels []cfgpkg.NoticeChannel, message string) {
+	for _, ch := range channels {
+		n, err := notice.NewNotifier(ch)
 		if err != nil {
-			logrus.Errorf("Failed to marshal alert payload: %v", err)
-			continue
+			n = notice.NewGenericWebhookMsg(ch.Webhookurl, "", nil, nil, "")
 		}
-
-		resp, err := http.Post(ch.Webhookurl, "application/json", bytes.NewBuffer(payload))
-		if err != nil {
+		if err := notice.Deliver(n, message); err != nil {
 			logrus.Errorf("Failed to send alert to %s: %v", ch.Chan, err)
 			continue
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := ioutil.ReadAll(resp.Body)
-			logrus.Errorf("Alert webhook (%s) returned non-200 status: %d, body: %s", ch.Chan, resp.StatusCode, string(body))
-		} else {
-			logrus.Infof("Alert sent successfully to %s", ch.Chan)
-		}
+		logrus.Infof("Alert sent to %s", ch.Chan)
 	}
 }
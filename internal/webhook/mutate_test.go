@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestSendToChannelsFallback(t *testing.T) {
+	bodies := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer server.Close()
+
+	// 未知的渠道类型：按以往的行为 POST {"content": ...}
+	sendToChannels([]cfgpkg.NoticeChannel{{Chan: "dingtalk", Webhookurl: server.URL}}, "inject failed")
+	select {
+	case body := <-bodies:
+		if body != `{"content": "inject failed"}` {
+			t.Errorf("body = %s", body)
+		}
+	default:
+		t.Fatal("unknown channel type should fall back to a generic webhook")
+	}
+}
+
+// podReview 生成创建 Pod 的 AdmissionReview
+func podReview(t *testing.T, annotations map[string]string) *admissionv1.AdmissionReview {
+	t.Helper()
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-0", Namespace: "default", Annotations: annotations},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app:v1"}}},
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+		UID:       "uid-1",
+		Namespace: "default",
+		Name:      "app-0",
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+}
+
+func TestMutatePodsQuotaAnnotations(t *testing.T) {
+	injected := map[string]string{
+		CoredogAnnotationInject:         "true",
+		CoredogAnnotationPath:           "/corefile",
+		"coredog.io/max-cores-per-hour": "many",
+	}
+	notInjected := map[string]string{"coredog.io/max-cores-per-hour": "many"}
+
+	tests := []struct {
+		name         string
+		quotaEnabled bool
+		annotations  map[string]string
+		allowed      bool
+	}{
+		{"invalid quota on injected pod", true, injected, false},
+		{"quota disabled", false, injected, true},
+		{"pod is not injected", true, notInjected, true},
+		{"valid quota", true, map[string]string{
+			CoredogAnnotationInject:         "true",
+			CoredogAnnotationPath:           "/corefile",
+			"coredog.io/max-cores-per-hour": "5",
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewMutateHandler()
+			h.QuotaEnabled = tt.quotaEnabled
+			resp := h.mutatePods(podReview(t, tt.annotations))
+			if resp.Allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v (%+v)", resp.Allowed, tt.allowed, resp.Result)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	cfgpkg "github.com/DomineCore/coredog/internal/config"
 	"github.com/sirupsen/logrus"
 )
 
@@ -30,6 +31,7 @@ func (s *Server) Start() error {
 
 	// Mutating webhook endpoint
 	mutateHandler := NewMutateHandler()
+	mutateHandler.QuotaEnabled = cfgpkg.Get().Quota.Enabled
 	mux.HandleFunc("/mutate", mutateHandler.ServeHTTP)
 
 	// Health check endpoint